@@ -11,9 +11,12 @@ import (
 	"io/ioutil"
 	"net/http"
 	"net/url"
+	"sort"
+	"strconv"
 	"time"
 
 	"github.com/emotionaldots/arbitrage/pkg/api/gazelle"
+	"github.com/emotionaldots/arbitrage/pkg/api/replay"
 	"github.com/emotionaldots/arbitrage/pkg/arbitrage"
 	"github.com/emotionaldots/arbitrage/pkg/model"
 )
@@ -23,6 +26,95 @@ type API interface {
 	Do(typ string, id int) (resp *arbitrage.Response, err error)
 	Download(id int) ([]byte, error)
 	ParseResponseReleases(resp arbitrage.Response) (interface{}, error)
+	Capabilities() Capabilities
+}
+
+// Capabilities describes what a tracker backend is able to do.
+type Capabilities struct {
+	// Types lists the response types supported by Do
+	Types []string
+	// Download is true if torrent files can be downloaded
+	Download bool
+	// Latest lists the response types whose newest ID can be looked up with
+	// LatestAPI
+	Latest []string
+}
+
+// Supports returns whether Do supports the given response type.
+func (c Capabilities) Supports(typ string) bool {
+	return contains(c.Types, typ)
+}
+
+// SupportsLatest returns whether the newest ID of the given response type can
+// be looked up.
+func (c Capabilities) SupportsLatest(typ string) bool {
+	return contains(c.Latest, typ)
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// Backend creates an API client for a configured tracker source. The
+// transport is nil unless tracker requests are recorded or replayed.
+type Backend func(source string, s Source, rt http.RoundTripper) (API, error)
+
+var backends = make(map[string]Backend)
+
+// RegisterBackend makes a tracker backend available for sources of the given
+// type in config.toml.
+func RegisterBackend(typ string, b Backend) {
+	if _, ok := backends[typ]; ok {
+		panic("cmd: backend registered twice: " + typ)
+	}
+	backends[typ] = b
+}
+
+// BackendTypes returns the sorted names of all registered backends.
+func BackendTypes() []string {
+	types := make([]string, 0, len(backends))
+	for typ := range backends {
+		types = append(types, typ)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// AccountAPI is implemented by backends that report the user's statistics,
+// such as ratio and notifications.
+type AccountAPI interface {
+	GetAccount() (model.Account, error)
+}
+
+// LatestAPI is implemented by backends that can look up the newest ID of a
+// response type, e.g. from the most recent uploads.
+type LatestAPI interface {
+	LatestID(typ string) (int, error)
+}
+
+// TokenAPI is implemented by backends that can spend a freeleech token when
+// downloading a torrent. DownloadWithToken returns ErrTokenFailed if the
+// tracker refused to use a token.
+type TokenAPI interface {
+	DownloadWithToken(id int) ([]byte, error)
+}
+
+// UserTorrentsAPI is implemented by backends that list the torrents the user
+// is seeding, leeching, uploaded or snatched.
+type UserTorrentsAPI interface {
+	UserTorrents(typ string) ([]model.UserTorrent, error)
+}
+
+// FixtureAPI is implemented by backends that can turn an archived response
+// back into the HTTP interaction it was crawled from, so archives can be
+// used as seed fixtures for the replay transport.
+type FixtureAPI interface {
+	ResponseFixture(resp arbitrage.Response) (replay.Fixture, error)
 }
 
 type GazelleAPI struct {
@@ -30,6 +122,47 @@ type GazelleAPI struct {
 	Source string
 }
 
+func init() {
+	RegisterBackend("gazelle", func(source string, s Source, rt http.RoundTripper) (API, error) {
+		w, err := gazelle.NewAPI(s.Url, UserAgent)
+		if err != nil {
+			return nil, err
+		}
+		if rt != nil {
+			w.SetTransport(rt)
+		}
+		return &GazelleAPI{w, source}, nil
+	})
+}
+
+func (w *GazelleAPI) Capabilities() Capabilities {
+	return Capabilities{
+		Types:    []string{"torrent", "torrentgroup", "collage", "artist"},
+		Download: true,
+		Latest:   []string{"torrent", "torrentgroup"},
+	}
+}
+
+// LatestID returns the newest torrent or group ID from the first page of
+// browse results, which are sorted by upload time.
+func (w *GazelleAPI) LatestID(typ string) (int, error) {
+	if typ != "torrent" && typ != "torrentgroup" {
+		return 0, errors.New("Unknown type: " + typ)
+	}
+	b, err := w.GetBrowse(url.Values{})
+	if err != nil {
+		return 0, err
+	}
+	id := b.MaxTorrentID()
+	if typ == "torrentgroup" {
+		id = b.MaxGroupID()
+	}
+	if id > 0 {
+		return id, nil
+	}
+	return 0, errors.New("no torrents found in browse results")
+}
+
 func (w *GazelleAPI) Do(typ string, id int) (resp *arbitrage.Response, err error) {
 	resp = &arbitrage.Response{
 		Source: w.Source,
@@ -42,8 +175,12 @@ func (w *GazelleAPI) Do(typ string, id int) (resp *arbitrage.Response, err error
 	switch typ {
 	case "torrent":
 		result, err = w.GetTorrent(id, url.Values{})
+	case "torrentgroup":
+		result, err = w.GetTorrentGroup(id, url.Values{})
 	case "collage":
 		result, err = w.GetCollage(id, url.Values{})
+	case "artist":
+		result, err = w.GetArtist(id, url.Values{})
 	default:
 		return nil, errors.New("Unknown type: " + typ)
 	}
@@ -60,20 +197,65 @@ func (w *GazelleAPI) Do(typ string, id int) (resp *arbitrage.Response, err error
 }
 
 func (w *GazelleAPI) Download(id int) ([]byte, error) {
-	u, err := w.CreateDownloadURL(id)
+	body, err := w.DownloadTorrent(id)
 	if err != nil {
 		return nil, err
 	}
+	defer body.Close()
+	return ioutil.ReadAll(body)
+}
 
-	resp, err := http.Get(u)
+// ErrTokenFailed is returned by TokenAPI.DownloadWithToken if no freeleech
+// token could be used for the download.
+var ErrTokenFailed = gazelle.ErrTokenFailed
+
+func (w *GazelleAPI) DownloadWithToken(id int) ([]byte, error) {
+	body, err := w.DownloadTorrentWithToken(id)
 	if err != nil {
 		return nil, err
 	}
-	if resp.StatusCode != 200 {
-		return nil, errors.New("unexpected status: " + resp.Status)
+	defer body.Close()
+	return ioutil.ReadAll(body)
+}
+
+// Number of entries per page of a user torrent list.
+const userTorrentsPage = 500
+
+// UserTorrents pages through a complete user torrent list.
+func (w *GazelleAPI) UserTorrents(typ string) ([]model.UserTorrent, error) {
+	list := make([]model.UserTorrent, 0)
+	for offset := 0; ; offset += userTorrentsPage {
+		page, err := w.GetUserTorrents(w.UserID(), typ, userTorrentsPage, offset)
+		if err != nil {
+			return list, err
+		}
+		list = append(list, page...)
+		if len(page) < userTorrentsPage {
+			return list, nil
+		}
+		time.Sleep(DefaultRateLimit)
 	}
-	defer resp.Body.Close()
-	return ioutil.ReadAll(resp.Body)
+}
+
+func (w *GazelleAPI) ResponseFixture(resp arbitrage.Response) (replay.Fixture, error) {
+	u, err := w.RequestURL(resp.Type, url.Values{"id": {strconv.Itoa(resp.TypeId)}})
+	if err != nil {
+		return replay.Fixture{}, err
+	}
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return replay.Fixture{}, err
+	}
+	raw, err := json.Marshal(struct {
+		Status   string          `json:"status"`
+		Response json.RawMessage `json:"response"`
+	}{"success", json.RawMessage(resp.Response)})
+	if err != nil {
+		return replay.Fixture{}, err
+	}
+
+	header := http.Header{"Content-Type": {"application/json"}}
+	return replay.NewFixture(req, nil, http.StatusOK, header, raw), nil
 }
 
 func (w *GazelleAPI) ParseResponseReleases(resp arbitrage.Response) (interface{}, error) {
@@ -97,6 +279,12 @@ func (w *GazelleAPI) ParseResponseReleases(resp arbitrage.Response) (interface{}
 			return nil, err
 		}
 		result = c
+	case "artist":
+		a := model.ArtistWithGroups{}
+		if err := json.Unmarshal([]byte(resp.Response), &a); err != nil {
+			return nil, err
+		}
+		result = a
 	default:
 		return nil, errors.New("API: unexpected response type: " + resp.Type)
 	}
@@ -8,14 +8,20 @@ package cmd
 import (
 	"errors"
 	"flag"
+	"fmt"
+	"io"
 	"log"
+	"net/http"
 	"os"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/BurntSushi/toml"
-	"github.com/emotionaldots/arbitrage/pkg/api/gazelle"
-	"github.com/emotionaldots/arbitrage/pkg/api/waffles"
+	"github.com/emotionaldots/arbitrage/pkg/announce"
+	"github.com/emotionaldots/arbitrage/pkg/api/replay"
+	"github.com/emotionaldots/arbitrage/pkg/api/scraper"
+	"github.com/emotionaldots/arbitrage/pkg/client"
 	"github.com/shibukawa/configdir"
 )
 
@@ -28,15 +34,69 @@ func must(err error) {
 }
 
 type Source struct {
+	// Type selects the tracker backend, e.g. "gazelle" (default), "waffles"
+	// or "scraper". Untyped sources named "wfl" default to "waffles".
+	Type     string `toml:"type"`
 	Url      string `toml:"url"`
 	User     string `toml:"user"`
 	Password string `toml:"password"`
+
+	// PasswordCommand is run with "sh -c", its first output line is used
+	// as password, see App.Credentials
+	PasswordCommand string `toml:"password_command,omitempty"`
+
+	// RateLimit is the minimum interval between torrent downloads,
+	// e.g. "2s" (default)
+	RateLimit string `toml:"rate_limit,omitempty"`
+
+	// Policy selects between several torrents matching a release:
+	// "seeders" (default), "freeleech" or "original", see client.Rank
+	Policy string `toml:"policy,omitempty"`
+
+	// Limits of bulk downloads, see Guard. Sizes are given as "10GiB".
+	// MaxDownload limits the estimated download of a single run,
+	// MinBuffer is the buffer that must remain after the run.
+	MaxDownload string `toml:"max_download,omitempty"`
+	MinBuffer   string `toml:"min_buffer,omitempty"`
+
+	// UseTokens spends freeleech tokens on non-free torrents larger than
+	// TokenThreshold that would need to be downloaded.
+	UseTokens      bool   `toml:"use_tokens,omitempty"`
+	TokenThreshold string `toml:"token_threshold,omitempty"`
+
+	// Scraper describes an HTML-only tracker, see scraper.Config
+	Scraper *scraper.Config `toml:"scraper,omitempty"`
+
+	// Announce describes the IRC channel new uploads are announced in,
+	// see "arbitrage-db announce"
+	Announce *announce.Config `toml:"announce,omitempty"`
+}
+
+const DefaultRateLimit = 2 * time.Second
+
+// MatchPolicy returns the configured policy to select between matches.
+func (s Source) MatchPolicy() string {
+	if s.Policy == "" {
+		return client.PolicySeeders
+	}
+	return s.Policy
+}
+
+// Interval returns the configured minimum interval between requests.
+func (s Source) Interval() time.Duration {
+	if d, err := time.ParseDuration(s.RateLimit); err == nil && d > 0 {
+		return d
+	}
+	return DefaultRateLimit
 }
 
 type Config struct {
 	Server       string            `toml:"server"`
 	DatabaseType string            `toml:"database_type,omitempty"`
 	Database     string            `toml:"database,omitempty"`
+	Replay       string            `toml:"replay,omitempty"`
+	Fixtures     string            `toml:"fixtures,omitempty"`
+	SecretsFile  string            `toml:"secrets_file,omitempty"`
 	Sources      map[string]Source `toml:"sources"`
 }
 
@@ -45,6 +105,10 @@ type App struct {
 	ConfigDir   string
 	Config      Config
 	ApiClients  map[string]API
+	Transport   *replay.Transport
+
+	secrets         map[string]string
+	passphraseCache string
 }
 
 func (app *App) Init() {
@@ -63,20 +127,61 @@ func (app *App) Init() {
 
 	f, err := os.Open(app.ConfigDir + "/config.toml")
 	if os.IsNotExist(err) {
-		app.Config.Sources["red"] = Source{Url: "https://redacted.ch"}
-		app.Config.Sources["apl"] = Source{Url: "https://apollo.rip"}
-		app.Config.Sources["wfl"] = Source{Url: "https://waffles.ch"}
-		must(os.MkdirAll(app.ConfigDir, 0755))
-		f, err = os.Create(app.ConfigDir + "/config.toml")
+		app.Config.Sources["red"] = Source{Type: "gazelle", Url: "https://redacted.ch"}
+		app.Config.Sources["apl"] = Source{Type: "gazelle", Url: "https://apollo.rip"}
+		app.Config.Sources["wfl"] = Source{Type: "waffles", Url: "https://waffles.ch"}
+		// The config may contain credentials, so it is only readable by us
+		must(os.MkdirAll(app.ConfigDir, 0700))
+		f, err = os.OpenFile(app.ConfigDir+"/config.toml", os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0600)
 		must(err)
 		must(toml.NewEncoder(f).Encode(app.Config))
 		log.Println("Created new config in " + app.ConfigDir + "/config.toml")
 	} else {
 		must(err)
-		_, err = toml.DecodeReader(f, &app.Config)
-		must(err)
+		must(DecodeConfig(f, &app.Config))
 	}
 	f.Close()
+
+	// The record/replay mode for tracker requests can be switched on either
+	// in the config or via environment, e.g. ARBITRAGE_REPLAY=record
+	if mode := os.Getenv("ARBITRAGE_REPLAY"); mode != "" {
+		app.Config.Replay = mode
+	}
+	if dir := os.Getenv("ARBITRAGE_FIXTURES"); dir != "" {
+		app.Config.Fixtures = dir
+	}
+	if app.Config.Replay != replay.ModeOff {
+		if app.Config.Fixtures == "" {
+			app.Config.Fixtures = app.ConfigDir + "/fixtures"
+		}
+		app.Transport, err = replay.New(app.Config.Replay, app.Config.Fixtures)
+		must(err)
+		log.Printf("Tracker requests: %s mode, fixtures in %s", app.Config.Replay, app.Config.Fixtures)
+	}
+}
+
+// DecodeConfig reads a config.toml and sets the type of sources that have
+// none. Configs before backend types were introduced selected the waffles
+// backend by the source name "wfl".
+func DecodeConfig(r io.Reader, c *Config) error {
+	if _, err := toml.DecodeReader(r, c); err != nil {
+		return err
+	}
+	for name, s := range c.Sources {
+		if s.Type != "" {
+			continue
+		}
+		switch {
+		case s.Scraper != nil:
+			s.Type = "scraper"
+		case name == "wfl":
+			s.Type = "waffles"
+		default:
+			s.Type = "gazelle"
+		}
+		c.Sources[name] = s
+	}
+	return nil
 }
 
 func ParseSourceId(source string) (string, int) {
@@ -103,26 +208,37 @@ func (app *App) APIForSource(source string) API {
 	}
 	s.Url = strings.TrimSuffix(s.Url, "/") + "/"
 
-	var c API
-	if source == "wfl" {
-		w, err := waffles.NewAPI(s.Url, UserAgent)
-		must(err)
-		c = &WafflesAPI{w, source}
+	backend, ok := backends[s.Type]
+	if !ok {
+		must(fmt.Errorf("Source '%s' has unknown type '%s', expected one of %v", source, s.Type, BackendTypes()))
+	}
 
-	} else {
-		w, err := gazelle.NewAPI(s.Url, UserAgent)
-		must(err)
-		c = &GazelleAPI{w, source}
+	var rt http.RoundTripper
+	if app.Transport != nil {
+		rt = app.Transport
 	}
+	c, err := backend(source, s, rt)
+	must(err)
 
 	app.ApiClients[source] = c
 	return c
 }
 
 func (app *App) DoLogin(source string) API {
+	c, err := app.TryLogin(source)
+	must(err)
+	return c
+}
+
+// TryLogin logs into a source like DoLogin, but returns errors instead of
+// exiting.
+func (app *App) TryLogin(source string) (API, error) {
 	c := app.APIForSource(source)
 	s := app.Config.Sources[source]
-	log.Printf("[%s] Logging into %s as %s", source, s.Url, s.User)
-	must(c.Login(s.User, s.Password))
-	return c
+	user, password, err := app.Credentials(source)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("[%s] Logging into %s as %s", source, s.Url, user)
+	return c, c.Login(user, password)
 }
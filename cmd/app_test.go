@@ -0,0 +1,73 @@
+// Author: EmotionalDots @ PTH
+//
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"strings"
+	"testing"
+)
+
+// config.toml as written by the first releases, without source types
+const baselineConfig = `
+server = "https://arbitrage.invariant.space"
+database_type = "sqlite3"
+database = "/home/user/.config/arbitrage"
+
+[sources]
+  [sources.apl]
+    url = "https://apollo.rip"
+    user = "user"
+    password = "secret"
+  [sources.red]
+    url = "https://redacted.ch"
+    user = ""
+    password = ""
+  [sources.wfl]
+    url = "https://waffles.ch"
+    user = "user"
+    password = "secret"
+`
+
+func TestDecodeBaselineConfig(t *testing.T) {
+	var c Config
+	if err := DecodeConfig(strings.NewReader(baselineConfig), &c); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{"apl": "gazelle", "red": "gazelle", "wfl": "waffles"}
+	if len(c.Sources) != len(expected) {
+		t.Fatalf("unexpected sources: %v", c.Sources)
+	}
+	for name, typ := range expected {
+		if c.Sources[name].Type != typ {
+			t.Errorf("source %s: expected type %s, got %q", name, typ, c.Sources[name].Type)
+		}
+		if _, ok := backends[c.Sources[name].Type]; !ok {
+			t.Errorf("source %s: no backend for type %s", name, c.Sources[name].Type)
+		}
+	}
+	if c.Sources["wfl"].User != "user" || c.DatabaseType != "sqlite3" {
+		t.Errorf("unexpected config: %+v", c)
+	}
+}
+
+func TestDecodeConfigTypes(t *testing.T) {
+	var c Config
+	err := DecodeConfig(strings.NewReader(`
+[sources.wfl]
+type = "gazelle"
+url = "https://wfl.test"
+[sources.books]
+url = "https://books.test"
+[sources.books.scraper]
+details_url = "details.php?id={id}"
+`), &c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Sources["wfl"].Type != "gazelle" || c.Sources["books"].Type != "scraper" {
+		t.Errorf("unexpected types: %+v", c.Sources)
+	}
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/emotionaldots/arbitrage/cmd"
+	"github.com/emotionaldots/arbitrage/pkg/announce"
+	"github.com/emotionaldots/arbitrage/pkg/api/apierr"
+	"github.com/emotionaldots/arbitrage/pkg/arbitrage"
+)
+
+// Command "announce" listens to the IRC announce channels of the given
+// sources, or all sources with an announce config, and immediately fetches,
+// archives and indexes each announced torrent.
+// SIGINT and SIGTERM exit after all fetched responses are archived.
+func (app *App) Announce() {
+	sources := flag.Args()[1:]
+	if len(sources) == 0 {
+		for name, s := range app.Config.Sources {
+			if s.Announce != nil {
+				sources = append(sources, name)
+			}
+		}
+		sort.Strings(sources)
+	}
+	if len(sources) == 0 {
+		log.Fatal("No sources with an announce config")
+	}
+
+	responses := make(chan *arbitrage.Response, 10)
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+
+	for _, source := range sources {
+		s, ok := app.Config.Sources[source]
+		if !ok {
+			log.Fatal("Unknown source: ", source)
+		}
+		if s.Announce == nil {
+			log.Fatalf("[%s] No announce config", source)
+		}
+		if !app.APIForSource(source).Capabilities().Supports("torrent") {
+			log.Fatalf("[%s] Fetching torrents is not supported by this tracker", source)
+		}
+		c := app.DoLogin(source)
+
+		ids := make(chan int, 100)
+		go app.listenAnnounces(source, *s.Announce, ids, stop)
+		wg.Add(1)
+		go func(source string, interval time.Duration) {
+			defer wg.Done()
+			app.fetchAnnounced(source, c, interval, &trackerPause{}, ids, responses, stop)
+		}(source, s.Interval())
+	}
+
+	stopOnSignal(stop)
+	go func() {
+		wg.Wait()
+		close(responses)
+	}()
+
+	for resp := range responses {
+		must(app.ArchiveResponse(*resp))
+		if err := app.IndexResponse(*resp); err != nil {
+			log.Printf("[%v] err: %v", resp, err)
+			continue
+		}
+		log.Printf("[%s:%d] Indexed announced torrent", resp.Source, resp.TypeId)
+	}
+	app.Close()
+}
+
+// listenAnnounces stays connected to the announce channel of a source until
+// the stop channel is closed, reconnecting with an increasing delay.
+func (app *App) listenAnnounces(source string, cfg announce.Config, ids chan<- int, stop chan struct{}) {
+	const minWait, maxWait = 10 * time.Second, 5 * time.Minute
+	wait := minWait
+	for {
+		l, err := announce.Dial(cfg)
+		if err == nil {
+			log.Printf("[%s] Listening for announces in %s on %s", source, cfg.Channel, cfg.Server)
+			done := make(chan struct{})
+			go func() {
+				select {
+				case <-stop:
+					l.Close()
+				case <-done:
+				}
+			}()
+			err = l.Listen(ids)
+			close(done)
+			l.Close()
+			wait = minWait
+		}
+
+		select {
+		case <-stop:
+			return
+		default:
+		}
+		log.Printf("[%s] Announce connection failed: %s, reconnecting in %s", source, err, wait)
+		if !sleep(stop, wait) {
+			return
+		}
+		if wait *= 2; wait > maxWait {
+			wait = maxWait
+		}
+	}
+}
+
+// announced is a torrent waiting to be requested.
+type announced struct {
+	id    int
+	tries int
+	due   time.Time
+}
+
+// announced IDs are remembered this long, so that repeated announces are
+// only requested once
+const announceMemory = 6 * time.Hour
+
+// delay before the first retry of a failed request, increasing with every try
+var announceRetry = 30 * time.Second
+
+// fetchAnnounced requests announced torrents within the rate limit of the
+// source. Failed requests are handled according to scanPolicy: torrents
+// that are not yet available or failed temporarily are requested again a
+// few times later, without holding up newer announces. Torrents announced
+// more than once are only requested once.
+func (app *App) fetchAnnounced(source string, c cmd.API, interval time.Duration, pause *trackerPause, ids <-chan int, responses chan<- *arbitrage.Response, stop chan struct{}) {
+	const maxTries = 3
+	seen := make(map[int]time.Time)
+	var queue []announced
+	next := time.Now().Add(interval)
+	for {
+		// wait for the earliest due torrent, but not for less than the
+		// rate limit or while the tracker is down
+		var due <-chan time.Time
+		first := 0
+		if len(queue) > 0 {
+			for i, a := range queue {
+				if a.due.Before(queue[first].due) {
+					first = i
+				}
+			}
+			at := queue[first].due
+			if at.Before(next) {
+				at = next
+			}
+			if until := pause.Until(); at.Before(until) {
+				at = until
+			}
+			due = time.After(at.Sub(time.Now()))
+		}
+
+		select {
+		case <-stop:
+			return
+		case id := <-ids:
+			now := time.Now()
+			if t, ok := seen[id]; ok && now.Sub(t) < announceMemory {
+				continue
+			}
+			for old, t := range seen {
+				if now.Sub(t) >= announceMemory {
+					delete(seen, old)
+				}
+			}
+			seen[id] = now
+			queue = append(queue, announced{id: id, due: now})
+			continue
+		case <-due:
+		}
+
+		a := queue[first]
+		queue = append(queue[:first], queue[first+1:]...)
+		next = time.Now().Add(interval)
+		resp, err := c.Do("torrent", a.id)
+		if err == nil {
+			pause.Resume()
+			responses <- resp
+			continue
+		}
+
+		kind := apierr.KindOf(err)
+		log.Printf("[%s:%d] %s (%s)", source, a.id, err, kind)
+		switch scanPolicy[kind] {
+		case scanSkip:
+			// announced torrents may not be available right away
+			if kind != apierr.NotFound {
+				continue
+			}
+		case scanPause:
+			// requested again once the tracker is back, without counting
+			// as a try
+			log.Printf("[%s] Tracker is down, pausing requests for %s", source, pause.Pause())
+			queue = append(queue, a)
+			continue
+		case scanLogin:
+			if _, err := app.TryLogin(source); err != nil {
+				log.Printf("[%s] Could not log in again: %s", source, err)
+			}
+		}
+		if a.tries++; a.tries < maxTries {
+			a.due = time.Now().Add(announceRetry * time.Duration(a.tries))
+			queue = append(queue, a)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/emotionaldots/arbitrage/cmd"
+	"github.com/emotionaldots/arbitrage/pkg/api/apierr"
+	"github.com/emotionaldots/arbitrage/pkg/arbitrage"
+)
+
+// fakeAPI answers torrent requests with a queue of errors per ID, and with
+// a response once the queue is empty.
+type fakeAPI struct {
+	mu       sync.Mutex
+	errs     map[int][]error
+	requests []int
+}
+
+func (f *fakeAPI) Login(username, password string) error { return nil }
+
+func (f *fakeAPI) Do(typ string, id int) (*arbitrage.Response, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.requests = append(f.requests, id)
+	if errs := f.errs[id]; len(errs) > 0 {
+		f.errs[id] = errs[1:]
+		return nil, errs[0]
+	}
+	return &arbitrage.Response{Source: "red", Type: typ, TypeId: id}, nil
+}
+
+func (f *fakeAPI) Download(id int) ([]byte, error) { return nil, errors.New("not implemented") }
+
+func (f *fakeAPI) ParseResponseReleases(resp arbitrage.Response) (interface{}, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (f *fakeAPI) Capabilities() cmd.Capabilities { return cmd.Capabilities{} }
+
+func TestFetchAnnounced(t *testing.T) {
+	defer func(d time.Duration) { announceRetry = d }(announceRetry)
+	announceRetry = 10 * time.Millisecond
+
+	notFound := apierr.New(apierr.NotFound, "not found")
+	c := &fakeAPI{errs: map[int][]error{
+		1: {notFound},
+		3: {apierr.New(apierr.Deleted, "deleted")},
+		4: {notFound, notFound, notFound},
+		5: {apierr.New(apierr.Network, "timeout")},
+	}}
+	app := &App{}
+
+	ids := make(chan int, 10)
+	responses := make(chan *arbitrage.Response, 10)
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		app.fetchAnnounced("red", c, time.Millisecond, &trackerPause{}, ids, responses, stop)
+		close(done)
+	}()
+	for _, id := range []int{1, 2, 2, 3, 4, 5, 1} {
+		ids <- id
+	}
+
+	fetched := make(map[int]bool)
+	for len(fetched) < 3 {
+		select {
+		case resp := <-responses:
+			if fetched[resp.TypeId] {
+				t.Errorf("torrent %d fetched twice", resp.TypeId)
+			}
+			fetched[resp.TypeId] = true
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timeout, fetched %v", fetched)
+		}
+	}
+	// wait for the remaining retries of torrent 4
+	time.Sleep(100 * time.Millisecond)
+	close(stop)
+	<-done
+
+	for _, id := range []int{1, 2, 5} {
+		if !fetched[id] {
+			t.Errorf("expected torrent %d to be fetched", id)
+		}
+	}
+	counts := make(map[int]int)
+	for _, id := range c.requests {
+		counts[id]++
+	}
+	expected := map[int]int{1: 2, 2: 1, 3: 1, 4: 3, 5: 2}
+	for id, n := range expected {
+		if counts[id] != n {
+			t.Errorf("torrent %d: expected %d requests, got %d", id, n, counts[id])
+		}
+	}
+}
@@ -6,17 +6,22 @@ import (
 	"flag"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"log"
 	"os"
+	"strconv"
 
 	"github.com/boltdb/bolt"
 	"github.com/emotionaldots/arbitrage/cmd"
+	"github.com/emotionaldots/arbitrage/pkg/api/replay"
 	"github.com/emotionaldots/arbitrage/pkg/arbitrage"
 )
 
 // Opens a BoltDB archive for the given source.
 // The BoltDB archives contain all crawled responses.
 func (app *App) OpenBolt(source string) *bolt.DB {
+	app.archivesMu.Lock()
+	defer app.archivesMu.Unlock()
 	if _, ok := app.Config.Sources[source]; !ok {
 		log.Fatal("Unknown source:", source)
 	}
@@ -64,6 +69,42 @@ func (app *App) Fetch() {
 	}))
 }
 
+// The "fixture" command exports the last archived response for a given
+// release type and tracker ID as a fixture for the record/replay transport,
+// so scrapers can be tested against real responses offline.
+func (app *App) Fixture() {
+	typ := flag.Arg(1)
+	source, id := cmd.ParseSourceId(flag.Arg(2))
+
+	api, ok := app.APIForSource(source).(cmd.FixtureAPI)
+	if !ok {
+		log.Fatalf("Source %s does not support fixtures", source)
+	}
+
+	resp := arbitrage.Response{Source: source, Type: typ, TypeId: id}
+	db := app.OpenBolt(source)
+	must(db.View(func(tx *bolt.Tx) error {
+		r, err := boltFetchLast(tx, typ, id)
+		if err != nil {
+			return err
+		}
+		body, err := ioutil.ReadAll(r)
+		resp.Response = string(body)
+		return err
+	}))
+
+	f, err := api.ResponseFixture(resp)
+	must(err)
+
+	dir := app.Config.Fixtures
+	if dir == "" {
+		dir = app.ConfigDir + "/fixtures"
+	}
+	t := &replay.Transport{Mode: replay.ModeRecord, Dir: dir}
+	must(t.Save(f))
+	log.Printf("Saved fixture for %s %s in %s", f.Request.Method, f.Request.URL, dir)
+}
+
 func (app *App) ArchiveResponse(resp arbitrage.Response) error {
 	db := app.OpenBolt(resp.Source)
 
@@ -83,6 +124,42 @@ func (app *App) ArchiveResponse(resp arbitrage.Response) error {
 	return nil
 }
 
+// Bucket in the archive of a source that stores the torrent IDs that were
+// fetched as part of their group.
+var bucketCovered = []byte("_covered")
+
+// MarkCovered records that torrents were fetched with their group, given a
+// map of torrent IDs to group IDs.
+func (app *App) MarkCovered(source string, covered map[int]int) error {
+	if len(covered) == 0 {
+		return nil
+	}
+	return app.OpenBolt(source).Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists(bucketCovered)
+		if err != nil {
+			return err
+		}
+		for id, group := range covered {
+			if err := b.Put([]byte(arbitrage.Pad(id)), []byte(strconv.Itoa(group))); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// IsCovered returns whether a torrent was already fetched with its group.
+func (app *App) IsCovered(source string, id int) bool {
+	var covered bool
+	app.OpenBolt(source).View(func(tx *bolt.Tx) error {
+		if b := tx.Bucket(bucketCovered); b != nil {
+			covered = b.Get([]byte(arbitrage.Pad(id))) != nil
+		}
+		return nil
+	})
+	return covered
+}
+
 // boltFetchLatest returns the last crawled response given a key prefix
 func boltFetchLast(tx *bolt.Tx, typ string, id int) (io.Reader, error) {
 	b := tx.Bucket([]byte(typ))
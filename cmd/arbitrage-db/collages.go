@@ -0,0 +1,206 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/emotionaldots/arbitrage/cmd"
+	"github.com/emotionaldots/arbitrage/pkg/api/apierr"
+	"github.com/emotionaldots/arbitrage/pkg/model"
+	"github.com/jinzhu/gorm"
+)
+
+// interval in which due collages are looked up
+const collagePoll = 10 * time.Minute
+
+// Command "scancollages" crawls the collages of a tracker. New collage IDs
+// are scanned like "scan collage:source", while known collages of the
+// selected categories are crawled again once their last crawl is older than
+// the refresh interval, as collages change far more often than torrents.
+func (app *App) ScanCollages() {
+	fs := flag.NewFlagSet("scancollages", flag.ExitOnError)
+	categories := fs.String("categories", "", "comma-separated collage category `ids` to crawl again, defaults to all")
+	refresh := fs.Duration("refresh", 24*time.Hour, "crawl collages again after this `interval`")
+	sweep := fs.Bool("sweep", true, "scan new collage IDs")
+	fs.Parse(flag.Args()[1:])
+	if fs.NArg() != 1 {
+		log.Fatal("Usage: arbitrage-db scancollages [-categories ids] [-refresh 24h] [-sweep=false] [source[:id]]")
+	}
+
+	cats := make(map[int]bool)
+	for _, c := range strings.Split(*categories, ",") {
+		if c = strings.TrimSpace(c); c == "" {
+			continue
+		}
+		id, err := strconv.Atoi(c)
+		must(err)
+		cats[id] = true
+	}
+
+	_, source, id, hasId := parseScanArg("collage:" + fs.Arg(0))
+	if !app.APIForSource(source).Capabilities().Supports("collage") {
+		log.Fatalf("[%s] Scanning collages is not supported by this tracker", source)
+	}
+	archive := app.OpenBolt(source)
+	idx := app.GetDatabaseForSource(source)
+
+	responses := make(chan scanItem, 10)
+	stop := make(chan struct{})
+	// the sweep and the recrawl share the rate limit of the tracker
+	pause := &trackerPause{}
+	limit := &rateLimit{interval: requestInterval}
+	var wg sync.WaitGroup
+
+	if *sweep {
+		cur, found, err := app.LoadCursor(source, "collage")
+		must(err)
+		if hasId {
+			cur.Id, cur.Retries = id, 0
+		} else if found {
+			log.Printf("[%s] Resuming collage scan at %d", source, cur.Id)
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			app.ScanTracker(source, "collage", cur, pause, limit, responses, stop)
+		}()
+	}
+
+	c := app.DoLogin(source)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		app.recrawlCollages(source, c, archive, idx, cats, *refresh, pause, limit, responses, stop)
+	}()
+
+	stopOnSignal(stop)
+	go func() {
+		wg.Wait()
+		close(responses)
+	}()
+	app.archiveItems(responses)
+	app.Close()
+}
+
+// recrawlCollages requests collages again once they are due, until the stop
+// channel is closed. Collages that no longer exist are marked as deleted.
+func (app *App) recrawlCollages(source string, c cmd.API, archive *bolt.DB, idx *gorm.DB, cats map[int]bool, refresh time.Duration, pause *trackerPause, limit *rateLimit, responses chan scanItem, stop chan struct{}) {
+	for {
+		crawls, err := collageCrawls(archive)
+		if err != nil {
+			log.Printf("[%s] Could not read archived collages: %s", source, err)
+		}
+		var collages []model.Collage
+		if err := idx.Find(&collages).Error; err != nil {
+			log.Printf("[%s] Could not read indexed collages: %s", source, err)
+		}
+		due := dueCollages(crawls, collages, cats, time.Now().Add(-refresh))
+		if len(due) > 0 {
+			log.Printf("[%s] Crawling %d collages again", source, len(due))
+		}
+
+		for _, id := range due {
+			if !limit.Wait(stop) || !pause.Wait(stop) {
+				return
+			}
+			resp, err := c.Do("collage", id)
+			if err == nil {
+				pause.Resume()
+				responses <- scanItem{source, "collage", resp, nil}
+				continue
+			}
+
+			kind := apierr.KindOf(err)
+			log.Printf("[%s:%d] %s (%s)", source, id, err, kind)
+			switch scanPolicy[kind] {
+			case scanSkip:
+				if err := removeCollage(idx, id); err != nil {
+					log.Printf("[%s:%d] Could not remove collage: %s", source, id, err)
+				}
+			case scanPause:
+				log.Printf("[%s] Tracker is down, pausing scans for %s", source, pause.Pause())
+			case scanLogin:
+				if _, err := app.TryLogin(source); err != nil {
+					log.Printf("[%s] Could not log in again: %s", source, err)
+				}
+			}
+		}
+
+		if !sleep(stop, collagePoll) {
+			return
+		}
+	}
+}
+
+// collageCrawls returns the time of the last crawl of each archived collage.
+func collageCrawls(db *bolt.DB) (map[int]time.Time, error) {
+	crawls := make(map[int]time.Time)
+	err := db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte("collage"))
+		if b == nil {
+			return nil
+		}
+		return b.ForEach(func(k, _ []byte) error {
+			// keys are "id|time", see arbitrage.Response.UID
+			parts := strings.SplitN(string(k), "|", 2)
+			if len(parts) != 2 {
+				return nil
+			}
+			id, err := strconv.Atoi(parts[0])
+			if err != nil {
+				return nil
+			}
+			t, err := time.Parse(time.RFC3339, parts[1])
+			if err == nil && t.After(crawls[id]) {
+				crawls[id] = t
+			}
+			return nil
+		})
+	})
+	return crawls, err
+}
+
+// dueCollages returns the collages of the given categories that were last
+// crawled before a given time, oldest first. Deleted collages are skipped.
+func dueCollages(crawls map[int]time.Time, collages []model.Collage, cats map[int]bool, before time.Time) []int {
+	indexed := make(map[int]model.Collage, len(collages))
+	for _, c := range collages {
+		indexed[int(c.ID)] = c
+	}
+
+	due := make([]int, 0)
+	for id, t := range crawls {
+		if !t.Before(before) {
+			continue
+		}
+		c, ok := indexed[id]
+		if ok && bool(c.Deleted) {
+			continue
+		}
+		if len(cats) > 0 && (!ok || !cats[int(c.CollageCategoryId)]) {
+			continue
+		}
+		due = append(due, id)
+	}
+	sort.Slice(due, func(i, j int) bool {
+		if ti, tj := crawls[due[i]], crawls[due[j]]; !ti.Equal(tj) {
+			return ti.Before(tj)
+		}
+		return due[i] < due[j]
+	})
+	return due
+}
+
+// removeCollage marks a collage as deleted and removes all its groups.
+func removeCollage(db *gorm.DB, id int) error {
+	if err := removeCollageGroups(db, id, nil); err != nil {
+		return err
+	}
+	return db.Model(model.Collage{}).Where("id = ?", id).Update("deleted", true).Error
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/emotionaldots/arbitrage/pkg/model"
+	"github.com/jinzhu/gorm"
+)
+
+// openTestIndex creates a temporary sqlite index with the given tables.
+func openTestIndex(t *testing.T, tables ...interface{}) (*gorm.DB, func()) {
+	dir, err := ioutil.TempDir("", "arbitrage-db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := gorm.Open("sqlite3", filepath.Join(dir, "index.db"))
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	cleanup := func() {
+		db.Close()
+		os.RemoveAll(dir)
+	}
+	if err := db.AutoMigrate(tables...).Error; err != nil {
+		cleanup()
+		t.Fatal(err)
+	}
+	return db, cleanup
+}
+
+func TestCollageCrawls(t *testing.T) {
+	dir, err := ioutil.TempDir("", "arbitrage-db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	db, err := bolt.Open(filepath.Join(dir, "test.bolt"), 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	crawls, err := collageCrawls(db)
+	if err != nil || len(crawls) != 0 {
+		t.Fatalf("expected no crawls without bucket, got %v (%v)", crawls, err)
+	}
+
+	keys := []string{
+		"0000000001|2017-01-01T00:00:00Z",
+		"0000000001|2017-03-01T00:00:00Z",
+		"0000000001|2017-02-01T00:00:00Z",
+		"0000000002|2017-01-15T12:00:00Z",
+		"0000000003",
+		"0000000004|yesterday",
+		"collage|2017-01-01T00:00:00Z",
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucket([]byte("collage"))
+		if err != nil {
+			return err
+		}
+		for _, k := range keys {
+			if err := b.Put([]byte(k), []byte("{}")); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	crawls, err = collageCrawls(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[int]time.Time{
+		1: time.Date(2017, 3, 1, 0, 0, 0, 0, time.UTC),
+		2: time.Date(2017, 1, 15, 12, 0, 0, 0, time.UTC),
+	}
+	if len(crawls) != len(expected) {
+		t.Fatalf("expected %v, got %v", expected, crawls)
+	}
+	for id, e := range expected {
+		if !crawls[id].Equal(e) {
+			t.Errorf("collage %d: expected %s, got %s", id, e, crawls[id])
+		}
+	}
+}
+
+func TestDueCollages(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2017, 6, d, 0, 0, 0, 0, time.UTC) }
+	crawls := map[int]time.Time{1: day(3), 2: day(1), 3: day(2), 4: day(1), 5: day(10), 6: day(1)}
+	collages := []model.Collage{
+		{ID: 1, CollageCategoryId: 1},
+		{ID: 2, CollageCategoryId: 2},
+		{ID: 3, CollageCategoryId: 1},
+		{ID: 4, CollageCategoryId: 1, Deleted: true},
+		{ID: 5, CollageCategoryId: 1},
+		// 6 was archived but never indexed
+	}
+
+	tests := []struct {
+		name     string
+		cats     map[int]bool
+		before   time.Time
+		expected []int
+	}{
+		{"all", nil, day(5), []int{2, 6, 3, 1}},
+		{"oldest only", nil, day(2), []int{2, 6}},
+		{"category", map[int]bool{1: true}, day(5), []int{3, 1}},
+		{"categories", map[int]bool{1: true, 2: true}, day(11), []int{2, 3, 1, 5}},
+		{"none due", nil, day(1), []int{}},
+	}
+	for _, test := range tests {
+		if due := dueCollages(crawls, collages, test.cats, test.before); !reflect.DeepEqual(due, test.expected) {
+			t.Errorf("%s: expected %v, got %v", test.name, test.expected, due)
+		}
+	}
+}
+
+func collageGroups(t *testing.T, db *gorm.DB, collage int) []int {
+	var cts []model.CollagesTorrents
+	if err := db.Where("collage_id = ?", collage).Find(&cts).Error; err != nil {
+		t.Fatal(err)
+	}
+	groups := make([]int, 0, len(cts))
+	for _, ct := range cts {
+		groups = append(groups, ct.GroupID)
+	}
+	sort.Ints(groups)
+	return groups
+}
+
+func TestRemoveCollageGroups(t *testing.T) {
+	db, cleanup := openTestIndex(t, model.CollagesTorrents{})
+	defer cleanup()
+	for _, ct := range []model.CollagesTorrents{{CollageID: 1, GroupID: 10}, {CollageID: 1, GroupID: 11}, {CollageID: 1, GroupID: 12}, {CollageID: 2, GroupID: 10}} {
+		if err := db.Create(&ct).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := removeCollageGroups(db, 1, []int{10, 12}); err != nil {
+		t.Fatal(err)
+	}
+	if g := collageGroups(t, db, 1); !reflect.DeepEqual(g, []int{10, 12}) {
+		t.Errorf("expected groups [10 12], got %v", g)
+	}
+	if err := removeCollageGroups(db, 1, nil); err != nil {
+		t.Fatal(err)
+	}
+	if g := collageGroups(t, db, 1); len(g) != 0 {
+		t.Errorf("expected no groups, got %v", g)
+	}
+	if g := collageGroups(t, db, 2); !reflect.DeepEqual(g, []int{10}) {
+		t.Errorf("other collage changed: %v", g)
+	}
+}
+
+func TestUpdateIndexCollage(t *testing.T) {
+	db, cleanup := openTestIndex(t, model.Collage{}, model.CollagesTorrents{})
+	defer cleanup()
+
+	collage := func(deleted bool, groups ...model.FlexInt) model.CollageWithGroups {
+		return model.CollageWithGroups{Collage: model.Collage{
+			ID: 1, Name: "Best of 2017", Deleted: model.FlexBool(deleted), TorrentGroupIDList: groups,
+		}}
+	}
+	deleted := func() bool {
+		var c model.Collage
+		if err := db.First(&c, 1).Error; err != nil {
+			t.Fatal(err)
+		}
+		return bool(c.Deleted)
+	}
+
+	if err := updateIndex(db, collage(false, 10, 11, 12)); err != nil {
+		t.Fatal(err)
+	}
+	if g := collageGroups(t, db, 1); !reflect.DeepEqual(g, []int{10, 11, 12}) {
+		t.Errorf("expected groups [10 11 12], got %v", g)
+	}
+
+	// groups removed from the collage are removed from the index
+	if err := updateIndex(db, collage(false, 11, 12, 13)); err != nil {
+		t.Fatal(err)
+	}
+	if g := collageGroups(t, db, 1); !reflect.DeepEqual(g, []int{11, 12, 13}) {
+		t.Errorf("expected groups [11 12 13], got %v", g)
+	}
+	if deleted() {
+		t.Error("collage unexpectedly deleted")
+	}
+
+	// deleted collages keep their row but lose their groups
+	if err := updateIndex(db, collage(true, 11, 12, 13)); err != nil {
+		t.Fatal(err)
+	}
+	if g := collageGroups(t, db, 1); len(g) != 0 {
+		t.Errorf("expected no groups of deleted collage, got %v", g)
+	}
+	if !deleted() {
+		t.Error("expected collage to be marked as deleted")
+	}
+
+	// collages that are no longer found are removed the same way
+	if err := updateIndex(db, collage(false, 10)); err != nil {
+		t.Fatal(err)
+	}
+	if err := removeCollage(db, 1); err != nil {
+		t.Fatal(err)
+	}
+	if g := collageGroups(t, db, 1); len(g) != 0 {
+		t.Errorf("expected no groups of removed collage, got %v", g)
+	}
+	if !deleted() {
+		t.Error("expected removed collage to be marked as deleted")
+	}
+}
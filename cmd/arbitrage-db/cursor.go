@@ -0,0 +1,73 @@
+package main
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/boltdb/bolt"
+)
+
+// Bucket in the archive of a source that stores the scan cursors by type.
+var bucketCursors = []byte("_cursors")
+
+// Cursor is the position of a scan of one response type, so that scans can
+// be resumed where they stopped.
+type Cursor struct {
+	Id      int       `json:"id"`      // next ID to request
+	MaxId   int       `json:"maxId"`   // highest ID known to exist
+	Retries int       `json:"retries"` // failed requests of the current ID
+	Updated time.Time `json:"updated"`
+
+	// IDs below the newest one that were not found, e.g. because they are
+	// not yet moderated, with the number of times they were requested
+	Missing        map[int]int `json:"missing,omitempty"`
+	MissingChecked time.Time   `json:"missingChecked,omitempty"`
+}
+
+// Copy returns a copy of the cursor that can be passed to another goroutine.
+func (cur Cursor) Copy() Cursor {
+	if cur.Missing != nil {
+		missing := make(map[int]int, len(cur.Missing))
+		for id, n := range cur.Missing {
+			missing[id] = n
+		}
+		cur.Missing = missing
+	}
+	return cur
+}
+
+// LoadCursor returns the stored scan cursor of a source and type, or false
+// if the type was never scanned.
+func (app *App) LoadCursor(source, typ string) (Cursor, bool, error) {
+	var cur Cursor
+	var found bool
+	err := app.OpenBolt(source).View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucketCursors)
+		if b == nil {
+			return nil
+		}
+		raw := b.Get([]byte(typ))
+		if raw == nil {
+			return nil
+		}
+		found = true
+		return json.Unmarshal(raw, &cur)
+	})
+	return cur, found, err
+}
+
+// SaveCursor stores the scan cursor of a source and type.
+func (app *App) SaveCursor(source, typ string, cur Cursor) error {
+	cur.Updated = time.Now()
+	raw, err := json.Marshal(cur)
+	if err != nil {
+		return err
+	}
+	return app.OpenBolt(source).Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists(bucketCursors)
+		if err != nil {
+			return err
+		}
+		return b.Put([]byte(typ), raw)
+	})
+}
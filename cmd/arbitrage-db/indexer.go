@@ -24,18 +24,29 @@ func updateIndex(db *gorm.DB, m interface{}) error {
 	switch v := m.(type) {
 	case model.Torrent:
 		log.Printf("  - %v", v)
-		id = v.ID
+		id = int(v.ID)
 		if id == 0 {
 			return errors.New("Indexer: no ID found")
 		}
 		return db.Where("id = ?", id).Assign(v).FirstOrCreate(&v).Error
 	case model.Group:
-		log.Printf("  - %v", v)
+		log.Printf("  - %v (%s)", v, v.Category)
 		id = int(v.ID)
 		if id == 0 {
 			return errors.New("Indexer: no ID found")
 		}
-		return db.Where("id = ?", id).Assign(v).FirstOrCreate(&v).Error
+		if err := db.Where("id = ?", id).Assign(v).FirstOrCreate(&v).Error; err != nil {
+			return err
+		}
+		return updateGroupArtists(db, id, v.MusicInfo)
+	case model.ArtistWithGroups:
+		id = int(v.ID)
+		log.Printf("  - %v", v)
+		if id == 0 {
+			return errors.New("Indexer: no ID found")
+		}
+		a := v.Artist
+		return db.Where("id = ?", id).Assign(a).FirstOrCreate(&a).Error
 	case model.GroupAndTorrents:
 		if err := updateIndex(db, v.Group); err != nil {
 			return err
@@ -46,14 +57,24 @@ func updateIndex(db *gorm.DB, m interface{}) error {
 			}
 		}
 	case model.CollageWithGroups:
-		id = v.ID
+		id = int(v.ID)
 		log.Printf("  - %v", v)
-		for _, g := range v.TorrentGroups {
-			ct := model.CollagesTorrents{CollageID: id, GroupID: int(g.ID)}
+		if id == 0 {
+			return errors.New("Indexer: no ID found")
+		}
+		groups := v.GroupIDs()
+		if bool(v.Deleted) {
+			groups = nil
+		}
+		for _, g := range groups {
+			ct := model.CollagesTorrents{CollageID: id, GroupID: g}
 			if err := db.Where(ct).Assign(ct).FirstOrCreate(&ct).Error; err != nil {
 				return err
 			}
 		}
+		if err := removeCollageGroups(db, id, groups); err != nil {
+			return err
+		}
 		c := v.Collage
 		return db.Where("id = ?", id).Assign(c).FirstOrCreate(&c).Error
 	default:
@@ -62,6 +83,43 @@ func updateIndex(db *gorm.DB, m interface{}) error {
 	return nil
 }
 
+// updateGroupArtists replaces the artist roles of a group and adds artists
+// that are not yet indexed.
+func updateGroupArtists(db *gorm.DB, group int, info model.MusicInfo) error {
+	if err := db.Where("group_id = ?", group).Delete(model.GroupArtist{}).Error; err != nil {
+		return err
+	}
+	credits := info.Credits()
+	for _, role := range model.Roles {
+		seen := make(map[model.FlexInt]bool)
+		for _, link := range credits[role] {
+			if link.ID == 0 || seen[link.ID] {
+				continue
+			}
+			seen[link.ID] = true
+			ga := model.GroupArtist{GroupID: group, ArtistID: int(link.ID), Role: role}
+			if err := db.Create(&ga).Error; err != nil {
+				return err
+			}
+			a := model.Artist{ID: link.ID, Name: link.Name}
+			if err := db.Where("id = ?", int(link.ID)).Assign(model.Artist{Name: link.Name}).FirstOrCreate(&a).Error; err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// removeCollageGroups removes all groups from a collage that are no longer
+// part of it.
+func removeCollageGroups(db *gorm.DB, collage int, keep []int) error {
+	q := db.Where("collage_id = ?", collage)
+	if len(keep) > 0 {
+		q = q.Where("group_id NOT IN (?)", keep)
+	}
+	return q.Delete(model.CollagesTorrents{}).Error
+}
+
 // Command "recalculate" iterates over all crawled response in the BoltDB archive,
 // parses them and then rebuilds the torrent database.
 func (app *App) Recalculate() {
@@ -72,6 +130,9 @@ func (app *App) Recalculate() {
 		source, id = cmd.ParseSourceId(flag.Arg(2))
 	}
 
+	// Torrents are marked as covered after reading the archive, as bolt
+	// cannot write while a read transaction is open in the same goroutine.
+	covered := make(map[int]int)
 	archive := app.OpenBolt(source)
 	must(archive.View(func(tx *bolt.Tx) error {
 		b := tx.Bucket([]byte(typ))
@@ -93,18 +154,29 @@ func (app *App) Recalculate() {
 				Identifier: string(k),
 				Response:   string(body),
 			}
-			if err := app.IndexResponse(resp); err != nil {
+			if err := app.indexResponse(resp, covered); err != nil {
 				log.Printf("[%v] err: %s", resp, err.Error())
 			}
 			k, v = c.Next()
 		}
 		return nil
 	}))
+	must(app.MarkCovered(source, covered))
 }
 
 // IndexResponse parses an API response and stores the torrents and groups
 // in the SQL database
 func (app *App) IndexResponse(resp arbitrage.Response) error {
+	covered := make(map[int]int)
+	if err := app.indexResponse(resp, covered); err != nil {
+		return err
+	}
+	return app.MarkCovered(resp.Source, covered)
+}
+
+// indexResponse indexes an API response and adds the torrents that were
+// fetched with their group to covered.
+func (app *App) indexResponse(resp arbitrage.Response, covered map[int]int) error {
 	api := app.APIForSource(resp.Source)
 	idx := app.GetDatabaseForSource(resp.Source)
 	db := app.GetDatabase()
@@ -120,11 +192,23 @@ func (app *App) IndexResponse(resp arbitrage.Response) error {
 			return err
 		}
 	}
+	if resp.Type == "artist" {
+		// discographies lack the file lists and most group metadata, so
+		// only the artist is indexed
+		return updateIndex(idx, m)
+	}
 
 	gs, err := model.NormalizeTorrentGroups(m)
 	if err != nil {
 		return err
 	}
+	if resp.Type == "torrentgroup" {
+		for _, gt := range gs {
+			for _, t := range gt.Torrents {
+				covered[int(t.ID)] = int(gt.Group.ID)
+			}
+		}
+	}
 	for _, gt := range gs {
 		if err := updateIndex(idx, gt); err != nil {
 			return err
@@ -139,8 +223,8 @@ func (app *App) IndexResponse(resp arbitrage.Response) error {
 			r := arbitrage.Release{
 				Source:   resp.Source,
 				SourceId: int64(t.ID),
-				FileList: arbitrage.ParseFileList(html.UnescapeString(t.FileList)),
-				FilePath: html.UnescapeString(t.FilePath),
+				FileList: arbitrage.ParseFileList(html.UnescapeString(string(t.FileList))),
+				FilePath: html.UnescapeString(string(t.FilePath)),
 			}
 
 			arbitrage.HashDefault(&r)
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/emotionaldots/arbitrage/cmd"
+	"github.com/emotionaldots/arbitrage/pkg/arbitrage"
+	"github.com/jinzhu/gorm"
+)
+
+// newTestApp creates an app with a single gazelle source "red" whose
+// archive and index are stored in a temporary directory.
+func newTestApp(t *testing.T) (*App, func()) {
+	dir, err := ioutil.TempDir("", "arbitrage-db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	app := &App{
+		Archives: make(map[string]*bolt.DB),
+		Indexes:  make(map[string]*gorm.DB),
+	}
+	app.ConfigDir = dir
+	app.ApiClients = make(map[string]cmd.API)
+	app.Config.DatabaseType = "sqlite3"
+	app.Config.Database = dir
+	app.Config.Sources = map[string]cmd.Source{
+		"red": {Type: "gazelle", Url: "https://red.test"},
+	}
+	return app, func() {
+		app.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+func TestRecalculateTorrentGroups(t *testing.T) {
+	app, cleanup := newTestApp(t)
+	resp := arbitrage.Response{
+		Source:   "red",
+		Type:     "torrentgroup",
+		TypeId:   1,
+		Time:     time.Date(2017, 6, 1, 0, 0, 0, 0, time.UTC),
+		Response: `{"group": {"id": 1, "name": "Album"}, "torrents": [{"id": 10, "fileList": "01.flac{{{100}}}", "filePath": "Album"}, {"id": 11}]}`,
+	}
+	if err := app.ArchiveResponse(resp); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := flag.CommandLine.Parse([]string{"recalculate", "torrentgroup", "red"}); err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan struct{})
+	go func() {
+		app.Recalculate()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		// closing a deadlocked archive would block as well
+		t.Fatal("recalculate did not finish, archive deadlocked?")
+	}
+	defer cleanup()
+
+	for _, id := range []int{10, 11} {
+		if !app.IsCovered("red", id) {
+			t.Errorf("expected torrent %d to be covered", id)
+		}
+	}
+	if app.IsCovered("red", 12) {
+		t.Error("torrent 12 unexpectedly covered")
+	}
+	var r arbitrage.Release
+	if err := app.GetDatabase().Where(arbitrage.Release{Source: "red", SourceId: 10}).First(&r).Error; err != nil {
+		t.Errorf("expected hash of torrent 10: %s", err)
+	}
+}
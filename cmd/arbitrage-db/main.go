@@ -11,6 +11,7 @@ import (
 	"fmt"
 	"log"
 	"strings"
+	"sync"
 
 	"github.com/boltdb/bolt"
 	"github.com/emotionaldots/arbitrage/cmd"
@@ -26,9 +27,25 @@ import (
 const Usage = `Usage: arbitrage [command] [args...]
 
 Tracker API commands:
-	scan [source:id...]:       Fetch torrents from trackers, starting at id
-	scancollages [source]:     Fetch a single collage from tracker
+	scan [type:][source[:id]...]:
+	                           Fetch torrents from trackers, starting at id or resuming
+	                           where the last scan stopped (SIGINT/SIGTERM stop cleanly).
+	                           Scanning torrentgroup:source fetches all torrents of a group
+	                           at once, torrent scans then skip them.
+	announce [sources]:        Fetch torrents as they are announced on IRC, for all sources
+	                           with an announce config by default
+	scancollages [source[:id]]:
+	                           Scan new collages and crawl known ones again after -refresh
+	                           (24h), optionally only -categories [ids]
 	recalculate:               Recalculate all hashes from saved API responses
+
+Archive commands:
+	list [type] [source]:          List all archived responses
+	fetch [type] [source:id]:      Print the last archived response
+	fixture [type] [source:id]:    Export an archived response as replay fixture
+
+Set ARBITRAGE_REPLAY=record or ARBITRAGE_REPLAY=replay to record tracker
+requests as fixtures or replay them offline (ARBITRAGE_FIXTURES=dir).
 `
 
 func must(err error) {
@@ -55,6 +72,8 @@ type App struct {
 	cmd.App
 	Archives map[string]*bolt.DB
 	Indexes  map[string]*gorm.DB
+
+	archivesMu sync.Mutex
 }
 
 func (app *App) Run() {
@@ -66,6 +85,10 @@ func (app *App) Run() {
 	switch flag.Arg(0) {
 	case "scan":
 		app.Scan()
+	case "scancollages":
+		app.ScanCollages()
+	case "announce":
+		app.Announce()
 	case "recalculate":
 		app.Recalculate()
 	case "serve":
@@ -76,8 +99,10 @@ func (app *App) Run() {
 		app.List()
 	case "fetch":
 		app.Fetch()
+	case "fixture":
+		app.Fixture()
 	default:
-		fmt.Println(Usage)
+		fmt.Print(Usage)
 	}
 }
 
@@ -145,6 +170,8 @@ func (app *App) GetDatabaseForSource(source string) *gorm.DB {
 		must(db.AutoMigrate(model.Group{}).Error)
 		must(db.AutoMigrate(model.Collage{}).Error)
 		must(db.AutoMigrate(model.CollagesTorrents{}).Error)
+		must(db.AutoMigrate(model.Artist{}).Error)
+		must(db.AutoMigrate(model.GroupArtist{}).Error)
 	}
 
 	return db
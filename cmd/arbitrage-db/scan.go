@@ -3,52 +3,280 @@ package main
 import (
 	"flag"
 	"log"
+	"os"
+	"os/signal"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"syscall"
 	"time"
 
 	"github.com/emotionaldots/arbitrage/cmd"
+	"github.com/emotionaldots/arbitrage/pkg/api/apierr"
 	"github.com/emotionaldots/arbitrage/pkg/arbitrage"
 )
 
+// scanItem is passed from the scanners to the archiver. The cursor, if any,
+// is saved after the response, if any, was archived and indexed.
+type scanItem struct {
+	Source string
+	Type   string
+	Resp   *arbitrage.Response
+	Cursor *Cursor
+}
+
+// parseScanArg parses a scan argument of the form "[type:]source[:id]".
+func parseScanArg(arg string) (typ, source string, id int, hasId bool) {
+	parts := strings.Split(arg, ":")
+	typ = "torrent"
+	if len(parts) == 3 {
+		typ, parts = parts[0], parts[1:]
+	}
+	if len(parts) == 2 {
+		if _, err := strconv.Atoi(parts[1]); err != nil {
+			// "collage:red" without an ID
+			typ, parts = parts[0], parts[1:]
+		}
+	}
+	if len(parts) == 1 {
+		return typ, parts[0], 0, false
+	}
+	source, id = cmd.ParseSourceId(strings.Join(parts, ":"))
+	return typ, source, id, true
+}
+
 // Command "scan" sequentially requests torrents via API from multiple tracker sources,
 // archives their responses and indexes them for the database.
 // You can specify multiple sources, optionally with an ID used as a starting point, e.g. "scan apl red wcd" or "scan red:12345 wfl"
+// Without an ID, scans resume from the cursor stored in the archive.
+// SIGINT and SIGTERM stop the scanners and exit after all received
+// responses are archived.
 func (app *App) Scan() {
-	responses := make(chan *arbitrage.Response, 10)
+	responses := make(chan scanItem, 10)
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	pauses := make(map[string]*trackerPause)
+	limits := make(map[string]*rateLimit)
 
 	for i := 1; i < flag.NArg(); i++ {
-		go app.ScanTracker(flag.Arg(i), responses)
+		typ, source, id, hasId := parseScanArg(flag.Arg(i))
+		cur, found, err := app.LoadCursor(source, typ)
+		must(err)
+		if hasId {
+			cur.Id, cur.Retries = id, 0
+		} else if found {
+			log.Printf("[%s] Resuming %s scan at %d", source, typ, cur.Id)
+		}
+
+		if pauses[source] == nil {
+			pauses[source] = &trackerPause{}
+			limits[source] = &rateLimit{interval: requestInterval}
+		}
+		pause, limit := pauses[source], limits[source]
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			app.ScanTracker(source, typ, cur, pause, limit, responses, stop)
+		}()
 	}
 
-	for resp := range responses {
-		err := app.ArchiveResponse(*resp)
-		must(err)
-		err = app.IndexResponse(*resp)
-		if err != nil {
-			log.Printf("[%v] err: %v", resp, err)
+	stopOnSignal(stop)
+	go func() {
+		wg.Wait()
+		close(responses)
+	}()
+	app.archiveItems(responses)
+	app.Close()
+}
+
+// archiveItems archives and indexes scanned responses and saves the scan
+// cursors until the channel is closed.
+func (app *App) archiveItems(responses chan scanItem) {
+	for item := range responses {
+		if resp := item.Resp; resp != nil {
+			must(app.ArchiveResponse(*resp))
+			if err := app.IndexResponse(*resp); err != nil {
+				log.Printf("[%v] err: %v", resp, err)
+			}
+		}
+		if item.Cursor == nil {
 			continue
 		}
+		if err := app.SaveCursor(item.Source, item.Type, *item.Cursor); err != nil {
+			log.Printf("[%s] Could not save %s cursor: %s", item.Source, item.Type, err)
+		}
+	}
+}
+
+// stopOnSignal closes the stop channel on the first SIGINT or SIGTERM. A
+// second signal terminates immediately.
+func stopOnSignal(stop chan struct{}) {
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		s := <-sig
+		log.Printf("Received %s, finishing pending requests (again to force)", s)
+		signal.Stop(sig)
+		close(stop)
+	}()
+}
+
+// Close closes all opened archives and index databases.
+func (app *App) Close() {
+	for source, db := range app.Archives {
+		if err := db.Close(); err != nil {
+			log.Printf("[%s] Could not close archive: %s", source, err)
+		}
+	}
+	for source, db := range app.Indexes {
+		if err := db.Close(); err != nil {
+			log.Printf("[%s] Could not close index: %s", source, err)
+		}
+	}
+}
+
+// sleep waits for the duration and returns false if the scan was stopped in
+// the meantime.
+func sleep(stop chan struct{}, d time.Duration) bool {
+	select {
+	case <-stop:
+		return false
+	case <-time.After(d):
+		return true
+	}
+}
+
+const (
+	// minimum interval between API requests to a tracker, as allowed per
+	// the rules, shared by all scans of the tracker
+	requestInterval = 2 * time.Second
+	// interval in which the newest ID is polled once the scan caught up
+	pollInterval = 2 * time.Minute
+	// missing IDs within this distance of the newest ID are requested again
+	// later, as they may not be moderated yet
+	missingWindow = 1000
+	// interval and number of times missing IDs are requested again
+	missingInterval = time.Hour
+	missingAttempts = 24
+)
+
+// scanAction is the reaction of the scanner to a failed request.
+type scanAction int
+
+const (
+	scanRetry scanAction = iota // retry with an increasing backoff, skip the ID after a few tries
+	scanSkip                    // continue with the next ID
+	scanLogin                   // log in again and retry
+	scanPause                   // pause all scans of the tracker, then retry
+)
+
+// scanPolicy maps the kinds of errors to the reaction of the scanner. IDs
+// that are not found are skipped only if newer IDs are known to exist.
+var scanPolicy = map[apierr.Kind]scanAction{
+	apierr.NotFound:     scanSkip,
+	apierr.Deleted:      scanSkip,
+	apierr.RateLimited:  scanRetry,
+	apierr.LoggedOut:    scanLogin,
+	apierr.Maintenance:  scanPause,
+	apierr.ParseFailure: scanRetry,
+	apierr.Network:      scanRetry,
+	apierr.Unknown:      scanRetry,
+}
+
+// trackerPause pauses all scans of a tracker while it is down.
+type trackerPause struct {
+	mu    sync.Mutex
+	until time.Time
+	delay time.Duration
+}
+
+// Pause pauses the scans, doubling the delay from 5 minutes up to an hour
+// while the tracker stays down.
+func (p *trackerPause) Pause() time.Duration {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if d := p.until.Sub(time.Now()); d > 0 {
+		return d
+	}
+	if p.delay *= 2; p.delay < 5*time.Minute {
+		p.delay = 5 * time.Minute
+	} else if p.delay > time.Hour {
+		p.delay = time.Hour
 	}
+	p.until = time.Now().Add(p.delay)
+	return p.delay
+}
+
+// Resume resets the delay after a successful request.
+func (p *trackerPause) Resume() {
+	p.mu.Lock()
+	p.delay = 0
+	p.mu.Unlock()
+}
+
+// Until returns the end of the current pause, or a time in the past if the
+// scans are not paused.
+func (p *trackerPause) Until() time.Time {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.until
+}
+
+// rateLimit spaces out the requests of all scans of a tracker.
+type rateLimit struct {
+	interval time.Duration
+
+	mu   sync.Mutex
+	next time.Time
+}
+
+// Wait blocks until the next request is allowed and returns false if the
+// scan was stopped in the meantime.
+func (r *rateLimit) Wait(stop chan struct{}) bool {
+	r.mu.Lock()
+	now := time.Now()
+	at := r.next
+	if at.Before(now) {
+		at = now
+	}
+	r.next = at.Add(r.interval)
+	r.mu.Unlock()
+	return sleep(stop, at.Sub(now))
+}
+
+// Wait blocks while the scans are paused and returns false if the scan was
+// stopped in the meantime.
+func (p *trackerPause) Wait(stop chan struct{}) bool {
+	p.mu.Lock()
+	d := p.until.Sub(time.Now())
+	p.mu.Unlock()
+	if d <= 0 {
+		return true
+	}
+	return sleep(stop, d)
 }
 
 // ScanTracker sequentially crawls API responses of a given type from a single
 // tracker and returns the responses in an async channel.
-// This function blocks indefinitely and needs to be manually killed to stop
-// crawling.
+// This function blocks until the stop channel is closed.
+// If the tracker can look up its newest ID, the scan continues up to it and
+// then polls for new uploads. Otherwise it probes ahead to distinguish
+// deleted releases from the end of results.
 // It has an backoff/retry algorithm to catch server errors or sleep for a few
 // minutes if the end of sequential torrents was reached.
-func (app *App) ScanTracker(sourceId string, responses chan *arbitrage.Response) {
-	typ := "torrent"
-	if strings.Count(sourceId, ":") > 1 {
-		parts := strings.SplitN(sourceId, ":", 2)
-		typ, sourceId = parts[0], parts[1]
+// Failed requests are handled according to scanPolicy.
+func (app *App) ScanTracker(source, typ string, cur Cursor, pause *trackerPause, limit *rateLimit, responses chan scanItem, stop chan struct{}) {
+	caps := app.APIForSource(source).Capabilities()
+	if !caps.Supports(typ) {
+		log.Fatalf("[%s] Scanning type %s is not supported by this tracker", source, typ)
 	}
-	source, id := cmd.ParseSourceId(sourceId)
-
 	c := app.DoLogin(source)
-	retries, maxId := 0, 0
-	// minimum API request rate, as allowed per the rules
-	backoff := 2 * time.Second
+	latest, _ := c.(cmd.LatestAPI)
+	if !caps.SupportsLatest(typ) {
+		latest = nil
+	}
 	// number of releases to skip forward to determine whether the current
 	// release is simply no longer available (deleted) or we reached the end
 	// of results
@@ -56,40 +284,136 @@ func (app *App) ScanTracker(sourceId string, responses chan *arbitrage.Response)
 	if typ == "torrent" {
 		lookAhead = 500
 	}
+	send := func(resp *arbitrage.Response) {
+		snapshot := cur.Copy()
+		responses <- scanItem{source, typ, resp, &snapshot}
+	}
+	advance := func(resp *arbitrage.Response) {
+		cur.Id++
+		cur.Retries = 0
+		send(resp)
+	}
 
-	for {
-		time.Sleep(backoff)
+	for limit.Wait(stop) && pause.Wait(stop) {
+		if typ == "torrent" && app.IsCovered(source, cur.Id) {
+			// fetched with its group, skip without requests
+			for app.IsCovered(source, cur.Id) {
+				cur.Id++
+			}
+			cur.Retries = 0
+			send(nil)
+		}
+		id := cur.Id
+		if latest != nil && id > cur.MaxId {
+			if n, err := latest.LatestID(typ); err != nil {
+				log.Printf("[%s] Could not look up newest %s: %s", source, typ, err)
+			} else if n > cur.MaxId {
+				cur.MaxId = n
+			}
+			if id > cur.MaxId {
+				// caught up, wait for new uploads
+				if !app.retryMissing(c, source, typ, &cur, limit, send, stop) || !sleep(stop, pollInterval) {
+					return
+				}
+				continue
+			}
+		}
 
 		resp, err := c.Do(typ, id)
 		if err != nil {
-			log.Printf("[%s:%d] %s", source, id, err)
+			kind := apierr.KindOf(err)
+			log.Printf("[%s:%d] %s (%s)", source, id, err, kind)
 
-			if err.Error() == "Request failed: bad id parameter" || err.Error() == "Parsing failed: no filelist found" {
-				if id > maxId {
-					time.Sleep(backoff)
+			action := scanPolicy[kind]
+			if kind == apierr.NotFound {
+				if latest != nil {
+					if cur.MaxId-id < missingWindow {
+						if cur.Missing == nil {
+							cur.Missing = make(map[int]int)
+						}
+						cur.Missing[id] = 1
+					}
+					advance(nil)
+					continue
+				}
+				if id > cur.MaxId {
+					if !limit.Wait(stop) {
+						return
+					}
 					if _, err := c.Do(typ, id+lookAhead); err == nil {
-						maxId = id + lookAhead
+						cur.MaxId = id + lookAhead
 					}
 				}
-				if id < maxId {
-					id++
-					retries = 0
-					continue
+				if id >= cur.MaxId {
+					// probably the end of results
+					action = scanRetry
 				}
 			}
 
-			retries++
-			if retries > 3 {
-				id++
-				retries = 0
+			switch action {
+			case scanSkip:
+				advance(nil)
+				continue
+			case scanPause:
+				log.Printf("[%s] Tracker is down, pausing scans for %s", source, pause.Pause())
+				send(nil)
+				continue
+			case scanLogin:
+				if _, err := app.TryLogin(source); err != nil {
+					log.Printf("[%s] Could not log in again: %s", source, err)
+					break
+				}
+				if cur.Retries++; cur.Retries > 3 {
+					advance(nil)
+				}
+				continue
+			}
+
+			cur.Retries++
+			if cur.Retries > 3 {
+				advance(nil)
 			} else {
-				time.Sleep(5 * time.Duration(retries) * time.Minute)
+				send(nil)
+				if !sleep(stop, 5*time.Duration(cur.Retries)*time.Minute) {
+					return
+				}
 			}
 			continue
 		}
 
-		responses <- resp
-		id++
-		retries = 0
+		pause.Resume()
+		advance(resp)
+	}
+}
+
+// retryMissing requests missing IDs again once per missingInterval and
+// returns false if the scan was stopped.
+func (app *App) retryMissing(c cmd.API, source, typ string, cur *Cursor, limit *rateLimit, send func(*arbitrage.Response), stop chan struct{}) bool {
+	if len(cur.Missing) == 0 || time.Since(cur.MissingChecked) < missingInterval {
+		return true
+	}
+	ids := make([]int, 0, len(cur.Missing))
+	for id := range cur.Missing {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+
+	for _, id := range ids {
+		if !limit.Wait(stop) {
+			return false
+		}
+		resp, err := c.Do(typ, id)
+		if err == nil {
+			log.Printf("[%s:%d] Found previously missing %s", source, id, typ)
+			delete(cur.Missing, id)
+			send(resp)
+			continue
+		}
+		if cur.Missing[id]++; cur.Missing[id] >= missingAttempts || !apierr.Is(err, apierr.NotFound) {
+			delete(cur.Missing, id)
+		}
 	}
+	cur.MissingChecked = time.Now()
+	send(nil)
+	return true
 }
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestParseScanArg(t *testing.T) {
+	tests := []struct {
+		arg    string
+		typ    string
+		source string
+		id     int
+		hasId  bool
+	}{
+		{"red", "torrent", "red", 0, false},
+		{"red:123", "torrent", "red", 123, true},
+		{"collage:red", "collage", "red", 0, false},
+		{"collage:red:5", "collage", "red", 5, true},
+		{"torrentgroup:red", "torrentgroup", "red", 0, false},
+		{"torrent:red:0", "torrent", "red", 0, true},
+	}
+	for _, test := range tests {
+		typ, source, id, hasId := parseScanArg(test.arg)
+		if typ != test.typ || source != test.source || id != test.id || hasId != test.hasId {
+			t.Errorf("%s: expected %s %s %d %v, got %s %s %d %v", test.arg,
+				test.typ, test.source, test.id, test.hasId, typ, source, id, hasId)
+		}
+	}
+}
+
+func TestCursorRoundTrip(t *testing.T) {
+	app, cleanup := newTestApp(t)
+	defer cleanup()
+
+	if _, found, err := app.LoadCursor("red", "torrent"); found || err != nil {
+		t.Fatalf("expected no cursor, got %v %v", found, err)
+	}
+
+	checked := time.Date(2017, 6, 1, 12, 0, 0, 0, time.UTC)
+	cur := Cursor{Id: 1234, MaxId: 2000, Retries: 2, Missing: map[int]int{1200: 3}, MissingChecked: checked}
+	if err := app.SaveCursor("red", "torrent", cur); err != nil {
+		t.Fatal(err)
+	}
+	if err := app.SaveCursor("red", "collage", Cursor{Id: 7}); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, found, err := app.LoadCursor("red", "torrent")
+	if err != nil || !found {
+		t.Fatalf("expected cursor, got %v %v", found, err)
+	}
+	if loaded.Id != 1234 || loaded.MaxId != 2000 || loaded.Retries != 2 || loaded.Missing[1200] != 3 ||
+		!loaded.MissingChecked.Equal(checked) || loaded.Updated.IsZero() {
+		t.Errorf("unexpected cursor: %+v", loaded)
+	}
+	if c, _, _ := app.LoadCursor("red", "collage"); c.Id != 7 || c.Missing != nil {
+		t.Errorf("unexpected collage cursor: %+v", c)
+	}
+}
+
+func TestCursorCopy(t *testing.T) {
+	cur := Cursor{Id: 10, Missing: map[int]int{5: 1}}
+	c := cur.Copy()
+	c.Missing[5] = 2
+	c.Missing[6] = 1
+	c.Id = 11
+	if cur.Id != 10 || cur.Missing[5] != 1 || len(cur.Missing) != 1 {
+		t.Errorf("copy shares state with the original: %+v", cur)
+	}
+	if c := (Cursor{Id: 1}).Copy(); c.Missing != nil {
+		t.Errorf("expected nil missing map, got %v", c.Missing)
+	}
+}
+
+func TestTrackerPause(t *testing.T) {
+	p := &trackerPause{}
+	if !p.Wait(make(chan struct{})) {
+		t.Error("expected wait without pause to return immediately")
+	}
+
+	expected := []time.Duration{5, 10, 20, 40, 60, 60}
+	for i, e := range expected {
+		if d := p.Pause(); d != e*time.Minute {
+			t.Errorf("pause %d: expected %s, got %s", i+1, e*time.Minute, d)
+		}
+		// pausing again while paused does not extend the pause
+		if d := p.Pause(); d > e*time.Minute || d < e*time.Minute-time.Second {
+			t.Errorf("pause %d while paused: expected remaining %s, got %s", i+1, e*time.Minute, d)
+		}
+		p.until = time.Time{}
+	}
+
+	p.Resume()
+	if d := p.Pause(); d != 5*time.Minute {
+		t.Errorf("expected backoff to reset after resume, got %s", d)
+	}
+
+	stop := make(chan struct{})
+	close(stop)
+	if p.Wait(stop) {
+		t.Error("expected wait to return false when stopped")
+	}
+}
+
+func TestRateLimitShared(t *testing.T) {
+	const interval = 20 * time.Millisecond
+	limit := &rateLimit{interval: interval}
+	stop := make(chan struct{})
+
+	// e.g. the collage sweep and recrawl of the same tracker
+	start := time.Now()
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for n := 0; n < 3; n++ {
+				if !limit.Wait(stop) {
+					t.Error("unexpected stop")
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if d := time.Since(start); d < 5*interval {
+		t.Errorf("expected 6 requests to take at least %s, took %s", 5*interval, d)
+	}
+
+	close(stop)
+	if limit.Wait(stop) {
+		t.Error("expected wait to return false when stopped")
+	}
+}
@@ -15,6 +15,7 @@ import (
 	"github.com/emotionaldots/arbitrage/pkg/model"
 	"github.com/gorilla/handlers"
 	"github.com/gorilla/mux"
+	"github.com/jinzhu/gorm"
 )
 
 // Command "serve" starts the HTTP API server, so clients can query
@@ -59,7 +60,8 @@ type AjaxResult struct {
 }
 
 // handleAjax provides an API that is very similar to the original Gazelle
-// one, serving torrents, groups and collages from the database for a single tracker source.
+// one, serving torrents, groups, collages and artist discographies from the
+// database for a single tracker source.
 // It allows cross-referencing releases from other trackers with a special
 // parameter "xref=[source]".
 func (app *App) handleAjax(w http.ResponseWriter, r *http.Request) {
@@ -88,7 +90,7 @@ func (app *App) handleAjax(w http.ResponseWriter, r *http.Request) {
 	switch action {
 	case "torrent":
 		gt := model.TorrentAndGroup{}
-		gt.Torrent.ID = id
+		gt.Torrent.ID = model.FlexInt(id)
 		err = db.Where(gt.Torrent).First(&gt.Torrent).Error
 		if err == nil {
 			gt.Group.ID = gt.Torrent.GroupID
@@ -97,12 +99,29 @@ func (app *App) handleAjax(w http.ResponseWriter, r *http.Request) {
 		result = gt
 	case "torrentgroup":
 		gt := model.GroupAndTorrents{}
-		gt.Group.ID = id
+		gt.Group.ID = model.FlexInt(id)
 		err = db.Where(gt.Group).First(&gt.Group).Error
 		if err == nil {
-			err = db.Where(model.Torrent{GroupID: id}).Find(&gt.Torrents).Error
+			err = db.Where(model.Torrent{GroupID: model.FlexInt(id)}).Find(&gt.Torrents).Error
 		}
 		result = gt
+	case "artist":
+		a := model.ArtistWithGroups{}
+		name := r.FormValue("artistname")
+		if id == 0 && name == "" {
+			jsonError(w, "Missing artist id or artistname", 400)
+			return
+		}
+		if id == 0 {
+			err = db.Where("name = ?", name).First(&a.Artist).Error
+		} else {
+			a.ID = model.FlexInt(id)
+			err = db.Where(a.Artist).First(&a.Artist).Error
+		}
+		if err == nil {
+			a.TorrentGroup, err = artistDiscography(db, int(a.ID))
+		}
+		result = a
 	default:
 		jsonError(w, "Unknown action:"+action, 400)
 		return
@@ -119,6 +138,64 @@ func (app *App) handleAjax(w http.ResponseWriter, r *http.Request) {
 	w.Write(raw)
 }
 
+// artistDiscography returns all indexed groups of an artist with their
+// torrents, newest first.
+func artistDiscography(db *gorm.DB, artist int) ([]model.ArtistGroup, error) {
+	var links []model.GroupArtist
+	if err := db.Where("artist_id = ?", artist).Find(&links).Error; err != nil {
+		return nil, err
+	}
+	discography := make([]model.ArtistGroup, 0)
+	if len(links) == 0 {
+		return discography, nil
+	}
+
+	// an artist can have several roles in a group, keep the first one
+	roles := make(map[int]string)
+	ids := make([]int, 0, len(links))
+	for _, role := range model.Roles {
+		for _, l := range links {
+			if l.Role == role && roles[l.GroupID] == "" {
+				roles[l.GroupID] = role
+				ids = append(ids, l.GroupID)
+			}
+		}
+	}
+
+	var groups []model.Group
+	if err := db.Where("id IN (?)", ids).Order("year desc, id desc").Find(&groups).Error; err != nil {
+		return nil, err
+	}
+	var torrents []model.Torrent
+	if err := db.Where("group_id IN (?)", ids).Find(&torrents).Error; err != nil {
+		return nil, err
+	}
+	byGroup := make(map[int][]model.Torrent)
+	for _, t := range torrents {
+		byGroup[int(t.GroupID)] = append(byGroup[int(t.GroupID)], t)
+	}
+
+	for _, g := range groups {
+		ts := byGroup[int(g.ID)]
+		if ts == nil {
+			ts = []model.Torrent{}
+		}
+		discography = append(discography, model.ArtistGroup{
+			GroupID:              g.ID,
+			GroupName:            g.Name,
+			GroupYear:            g.Year,
+			GroupRecordLabel:     g.RecordLabel,
+			GroupCatalogueNumber: g.CatalogueNumber,
+			Tags:                 g.Tags,
+			ReleaseType:          g.ReleaseType,
+			GroupVanityHouse:     g.VanityHouse,
+			Role:                 roles[int(g.ID)],
+			Torrent:              ts,
+		})
+	}
+	return discography, nil
+}
+
 func jsonError(w http.ResponseWriter, err string, code int) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(code)
@@ -127,10 +204,85 @@ func jsonError(w http.ResponseWriter, err string, code int) {
 	w.Write(raw)
 }
 
-type minimalRelease struct {
-	Id       int64  `json:"id"`
-	Hash     string `json:"hash"`
-	FilePath string `json:"filePath"`
+// queryRelease is a matching release as returned by the query endpoints,
+// with torrent metadata from the per-source index if available.
+type queryRelease struct {
+	Id          int64  `json:"id"`
+	Hash        string `json:"hash"`
+	FilePath    string `json:"filePath"`
+	Media       string `json:"media,omitempty"`
+	Format      string `json:"format,omitempty"`
+	Encoding    string `json:"encoding,omitempty"`
+	Size        int64  `json:"size,omitempty"`
+	Seeders     int    `json:"seeders"`
+	Snatched    int    `json:"snatched"`
+	FreeTorrent bool   `json:"freeTorrent"`
+	Remastered  bool   `json:"remastered"`
+	Edition     string `json:"edition,omitempty"`
+	Time        string `json:"time,omitempty"`
+}
+
+func edition(t model.Torrent) string {
+	if !t.Remastered {
+		return "Original Release"
+	}
+	parts := make([]string, 0, 4)
+	if t.RemasterYear > 0 {
+		parts = append(parts, strconv.Itoa(int(t.RemasterYear)))
+	}
+	for _, p := range []model.FlexString{t.RemasterTitle, t.RemasterRecordLabel, t.RemasterCatalogueNumber} {
+		if p != "" {
+			parts = append(parts, string(p))
+		}
+	}
+	return strings.Join(parts, " / ")
+}
+
+// queryReleases looks up the torrents of matching releases in the index of
+// their source and returns them with metadata.
+func (app *App) queryReleases(source string, releases []*arbitrage.Release) ([]queryRelease, error) {
+	result := make([]queryRelease, len(releases))
+	ids := make([]int64, len(releases))
+	for i, r := range releases {
+		result[i] = queryRelease{
+			Id:       r.SourceId,
+			Hash:     r.Hash,
+			FilePath: r.FilePath,
+		}
+		ids[i] = r.SourceId
+	}
+	if len(ids) == 0 {
+		return result, nil
+	}
+
+	var torrents []model.Torrent
+	db := app.GetDatabaseForSource(source)
+	if err := db.Where("id IN (?)", ids).Find(&torrents).Error; err != nil {
+		return nil, err
+	}
+	byId := make(map[int64]model.Torrent, len(torrents))
+	for _, t := range torrents {
+		byId[int64(t.ID)] = t
+	}
+
+	for i, r := range result {
+		t, ok := byId[r.Id]
+		if !ok {
+			continue
+		}
+		r.Media = string(t.Media)
+		r.Format = string(t.Format)
+		r.Encoding = string(t.Encoding)
+		r.Size = int64(t.Size)
+		r.Seeders = int(t.Seeders)
+		r.Snatched = int(t.Snatched)
+		r.FreeTorrent = bool(t.FreeTorrent)
+		r.Remastered = bool(t.Remastered)
+		r.Edition = edition(t)
+		r.Time = string(t.Time)
+		result[i] = r
+	}
+	return result, nil
 }
 
 // handleApiQueryBatch provides batch functionality for the hash-based
@@ -166,12 +318,10 @@ func (app *App) handleApiQueryBatch(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	result := make([]minimalRelease, len(releases))
-	for i, r := range releases {
-		result[i] = minimalRelease{
-			Id:   r.SourceId,
-			Hash: r.Hash,
-		}
+	result, err := app.queryReleases(source, releases)
+	if err != nil {
+		jsonError(w, err.Error(), 500)
+		return
 	}
 
 	resp := map[string]interface{}{"torrents": result}
@@ -209,13 +359,10 @@ func (app *App) handleApiQuery(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	result := make([]minimalRelease, len(releases))
-	for i, r := range releases {
-		result[i] = minimalRelease{
-			Id:       r.SourceId,
-			Hash:     r.Hash,
-			FilePath: r.FilePath,
-		}
+	result, err := app.queryReleases(source, releases)
+	if err != nil {
+		jsonError(w, err.Error(), 500)
+		return
 	}
 
 	resp := map[string]interface{}{"torrents": result}
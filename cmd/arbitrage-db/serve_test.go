@@ -0,0 +1,168 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/emotionaldots/arbitrage/pkg/model"
+	"github.com/jinzhu/gorm"
+)
+
+func groupArtists(t *testing.T, db *gorm.DB, group int) []string {
+	var links []model.GroupArtist
+	if err := db.Where("group_id = ?", group).Find(&links).Error; err != nil {
+		t.Fatal(err)
+	}
+	roles := make([]string, 0, len(links))
+	for _, l := range links {
+		roles = append(roles, fmt.Sprintf("%s:%d", l.Role, l.ArtistID))
+	}
+	sort.Strings(roles)
+	return roles
+}
+
+func TestUpdateGroupArtists(t *testing.T) {
+	db, cleanup := openTestIndex(t, model.Artist{}, model.GroupArtist{})
+	defer cleanup()
+
+	info := model.MusicInfo{
+		Artists:  []model.ArtistLink{{ID: 1, Name: "Main"}, {ID: 2, Name: "Second"}, {ID: 1, Name: "Main"}},
+		With:     []model.ArtistLink{{ID: 3, Name: "Guest"}, {ID: 0, Name: "Unknown"}},
+		Producer: []model.ArtistLink{{ID: 1, Name: "Main"}},
+	}
+	expected := []string{"guest:3", "main:1", "main:2", "producer:1"}
+	// indexing the group again must not add its artists twice
+	for i := 0; i < 2; i++ {
+		if err := updateGroupArtists(db, 10, info); err != nil {
+			t.Fatal(err)
+		}
+		if roles := groupArtists(t, db, 10); !reflect.DeepEqual(roles, expected) {
+			t.Errorf("run %d: expected %v, got %v", i+1, expected, roles)
+		}
+	}
+
+	// roles that were removed from the group are removed from the index
+	info.With = nil
+	info.Artists[0].Name = "Renamed"
+	info.Producer[0].Name = "Renamed"
+	if err := updateGroupArtists(db, 10, info); err != nil {
+		t.Fatal(err)
+	}
+	if roles := groupArtists(t, db, 10); !reflect.DeepEqual(roles, []string{"main:1", "main:2", "producer:1"}) {
+		t.Errorf("unexpected roles after update: %v", roles)
+	}
+
+	var artists []model.Artist
+	if err := db.Order("id").Find(&artists).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(artists) != 3 || artists[0].Name != "Renamed" {
+		t.Errorf("unexpected artists: %+v", artists)
+	}
+}
+
+func TestArtistDiscography(t *testing.T) {
+	db, cleanup := openTestIndex(t, model.Torrent{}, model.Group{}, model.GroupArtist{})
+	defer cleanup()
+
+	rows := []interface{}{
+		&model.Group{ID: 10, Name: "Debut", Year: 2001},
+		&model.Group{ID: 11, Name: "Remixes", Year: 2005},
+		&model.Group{ID: 12, Name: "Collaboration", Year: 2005},
+		&model.Group{ID: 13, Name: "Other Artist", Year: 2010},
+		&model.Torrent{ID: 100, GroupID: 10, Format: "FLAC"},
+		&model.Torrent{ID: 101, GroupID: 10, Format: "MP3"},
+		&model.Torrent{ID: 110, GroupID: 11, Format: "FLAC"},
+		&model.GroupArtist{GroupID: 10, ArtistID: 1, Role: model.RoleMain},
+		&model.GroupArtist{GroupID: 11, ArtistID: 1, Role: model.RoleRemixer},
+		&model.GroupArtist{GroupID: 12, ArtistID: 1, Role: model.RoleProducer},
+		&model.GroupArtist{GroupID: 12, ArtistID: 1, Role: model.RoleGuest},
+		&model.GroupArtist{GroupID: 13, ArtistID: 2, Role: model.RoleMain},
+	}
+	for _, r := range rows {
+		if err := db.Create(r).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	d, err := artistDiscography(db, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	type entry struct {
+		group    model.FlexInt
+		role     string
+		torrents int
+	}
+	expected := []entry{{12, model.RoleGuest, 0}, {11, model.RoleRemixer, 1}, {10, model.RoleMain, 2}}
+	got := make([]entry, 0, len(d))
+	for _, g := range d {
+		got = append(got, entry{g.GroupID, g.Role, len(g.Torrent)})
+		if g.Torrent == nil {
+			t.Errorf("group %d: torrents must encode as empty list", g.GroupID)
+		}
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %v, got %v", expected, got)
+	}
+
+	if d, err := artistDiscography(db, 3); err != nil || d == nil || len(d) != 0 {
+		t.Errorf("expected empty discography, got %v (%v)", d, err)
+	}
+}
+
+func TestHandleAjaxArtist(t *testing.T) {
+	app, cleanup := newTestApp(t)
+	defer cleanup()
+
+	db := app.GetDatabaseForSource("red")
+	for _, r := range []interface{}{
+		&model.Artist{ID: 1, Name: "First"},
+		&model.Artist{ID: 2, Name: "Second"},
+		&model.Group{ID: 10, Name: "Album"},
+		&model.GroupArtist{GroupID: 10, ArtistID: 2, Role: model.RoleMain},
+	} {
+		if err := db.Create(r).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		query  string
+		code   int
+		artist model.FlexInt
+		groups int
+	}{
+		{"action=artist", 400, 0, 0},
+		{"action=artist&id=0", 400, 0, 0},
+		{"action=artist&id=2", 200, 2, 1},
+		{"action=artist&artistname=Second", 200, 2, 1},
+		{"action=artist&id=1&artistname=Second", 200, 1, 0},
+	}
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		app.handleAjax(w, httptest.NewRequest("GET", "/red/ajax.php?"+test.query, nil))
+		if w.Code != test.code {
+			t.Errorf("%s: expected status %d, got %d: %s", test.query, test.code, w.Code, w.Body)
+			continue
+		}
+		if test.code != 200 {
+			continue
+		}
+		var res struct {
+			Status   string
+			Response model.ArtistWithGroups
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+			t.Errorf("%s: %s", test.query, err)
+			continue
+		}
+		if res.Response.ID != test.artist || len(res.Response.TorrentGroup) != test.groups {
+			t.Errorf("%s: expected artist %d with %d groups, got %+v", test.query, test.artist, test.groups, res.Response)
+		}
+	}
+}
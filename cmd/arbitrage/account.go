@@ -0,0 +1,234 @@
+// Author: EmotionalDots @ PTH
+//
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/emotionaldots/arbitrage/cmd"
+)
+
+var bucketAccounts = []byte("accounts")
+
+// openLocalDB opens the client's local database in the config directory.
+func (app *App) openLocalDB() (*bolt.DB, error) {
+	return bolt.Open(filepath.Join(app.ConfigDir, "local.bolt"), 0600, &bolt.Options{Timeout: 5 * time.Second})
+}
+
+// Snapshot records the account statistics of a source at a point in time.
+type Snapshot struct {
+	Time          time.Time `json:"time"`
+	Username      string    `json:"username"`
+	Class         string    `json:"class"`
+	Uploaded      int64     `json:"uploaded"`
+	Downloaded    int64     `json:"downloaded"`
+	Ratio         float64   `json:"ratio"`
+	RequiredRatio float64   `json:"requiredRatio"`
+	Messages      int       `json:"messages"`
+	Notifications int       `json:"notifications"`
+}
+
+// Buffer returns how much can still be downloaded before the ratio drops
+// below the required ratio.
+func (s Snapshot) Buffer() int64 {
+	if s.RequiredRatio <= 0 {
+		return s.Uploaded - s.Downloaded
+	}
+	return int64(float64(s.Uploaded)/s.RequiredRatio) - s.Downloaded
+}
+
+// Warning returns a warning if the ratio is within margin (e.g. 0.2 for
+// 20%) of the required ratio.
+func (s Snapshot) Warning(margin float64) string {
+	if s.RequiredRatio <= 0 || s.Downloaded == 0 {
+		return ""
+	}
+	if s.Ratio < s.RequiredRatio {
+		return fmt.Sprintf("ratio %.2f is below the required %.2f", s.Ratio, s.RequiredRatio)
+	}
+	if s.Ratio < s.RequiredRatio*(1+margin) {
+		return fmt.Sprintf("ratio %.2f is close to the required %.2f", s.Ratio, s.RequiredRatio)
+	}
+	return ""
+}
+
+func saveSnapshot(db *bolt.DB, source string, s Snapshot) error {
+	return db.Update(func(tx *bolt.Tx) error {
+		root, err := tx.CreateBucketIfNotExists(bucketAccounts)
+		if err != nil {
+			return err
+		}
+		b, err := root.CreateBucketIfNotExists([]byte(source))
+		if err != nil {
+			return err
+		}
+		raw, err := json.Marshal(s)
+		if err != nil {
+			return err
+		}
+		return b.Put([]byte(s.Time.UTC().Format(time.RFC3339)), raw)
+	})
+}
+
+// loadSnapshots returns all snapshots of a source since a given time, oldest
+// first.
+func loadSnapshots(db *bolt.DB, source string, since time.Time) ([]Snapshot, error) {
+	snaps := make([]Snapshot, 0)
+	err := db.View(func(tx *bolt.Tx) error {
+		root := tx.Bucket(bucketAccounts)
+		if root == nil {
+			return nil
+		}
+		b := root.Bucket([]byte(source))
+		if b == nil {
+			return nil
+		}
+		c := b.Cursor()
+		for k, v := c.Seek([]byte(since.UTC().Format(time.RFC3339))); k != nil; k, v = c.Next() {
+			var s Snapshot
+			if err := json.Unmarshal(v, &s); err != nil {
+				return err
+			}
+			snaps = append(snaps, s)
+		}
+		return nil
+	})
+	return snaps, err
+}
+
+// Command "account" logs into all sources and shows a combined table of
+// their account statistics, which are stored as snapshots for "--history".
+func (app *App) Account() {
+	fs := flag.NewFlagSet("account", flag.ExitOnError)
+	history := fs.Bool("history", false, "show stored snapshots instead of logging in")
+	days := fs.Int("days", 30, "number of days shown with -history")
+	margin := fs.Float64("warn", 0.2, "warn if the ratio is within this fraction of the required ratio")
+	fs.Parse(flag.Args()[1:])
+
+	sources := fs.Args()
+	if len(sources) == 0 {
+		for name := range app.Config.Sources {
+			sources = append(sources, name)
+		}
+		sort.Strings(sources)
+	}
+
+	db, err := app.openLocalDB()
+	must(err)
+	defer db.Close()
+
+	if *history {
+		app.accountHistory(db, sources, time.Now().AddDate(0, 0, -*days), *margin)
+		return
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "source\tuser\tclass\tuploaded\tdownloaded\tbuffer\tratio\trequired\tmessages\t")
+	warnings := make([]string, 0)
+	for _, source := range sources {
+		s, err := app.fetchSnapshot(source)
+		if err != nil {
+			log.Printf("[%s] %s", source, err)
+			continue
+		}
+		if err := saveSnapshot(db, source, s); err != nil {
+			log.Printf("[%s] Could not store snapshot: %s", source, err)
+		}
+
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%.2f\t%.2f\t%d\t\n", source, s.Username, s.Class,
+			cmd.FormatBytes(s.Uploaded), cmd.FormatBytes(s.Downloaded), cmd.FormatBytes(s.Buffer()),
+			s.Ratio, s.RequiredRatio, s.Messages)
+		if w := s.Warning(*margin); w != "" {
+			warnings = append(warnings, fmt.Sprintf("[%s] Warning: %s", source, w))
+		}
+	}
+	tw.Flush()
+	for _, w := range warnings {
+		fmt.Println(w)
+	}
+}
+
+func (app *App) fetchSnapshot(source string) (Snapshot, error) {
+	if _, ok := app.APIForSource(source).(cmd.AccountAPI); !ok {
+		return Snapshot{}, fmt.Errorf("account statistics are not supported by this tracker")
+	}
+	c, err := app.TryLogin(source)
+	if err != nil {
+		return Snapshot{}, err
+	}
+	a, err := c.(cmd.AccountAPI).GetAccount()
+	if err != nil {
+		return Snapshot{}, err
+	}
+	return Snapshot{
+		Time:          time.Now(),
+		Username:      string(a.Username),
+		Class:         string(a.UserStats.Class),
+		Uploaded:      int64(a.UserStats.Uploaded),
+		Downloaded:    int64(a.UserStats.Downloaded),
+		Ratio:         float64(a.UserStats.Ratio),
+		RequiredRatio: float64(a.UserStats.RequiredRatio),
+		Messages:      int(a.Notifications.Messages),
+		Notifications: int(a.Notifications.Notifications),
+	}, nil
+}
+
+// accountHistory prints the last snapshot of every day per source, with the
+// change in ratio and buffer.
+func (app *App) accountHistory(db *bolt.DB, sources []string, since time.Time, margin float64) {
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "source\tdate\tuploaded\tdownloaded\tbuffer\tΔbuffer\tratio\tΔratio\t")
+	warnings := make([]string, 0)
+
+	for _, source := range sources {
+		snaps, err := loadSnapshots(db, source, since)
+		must(err)
+
+		daily := make([]Snapshot, 0)
+		for _, s := range snaps {
+			if n := len(daily); n > 0 && daily[n-1].Time.Format("2006-01-02") == s.Time.Format("2006-01-02") {
+				daily[n-1] = s
+			} else {
+				daily = append(daily, s)
+			}
+		}
+
+		for i, s := range daily {
+			dBuffer, dRatio := int64(0), 0.0
+			if i > 0 {
+				dBuffer = s.Buffer() - daily[i-1].Buffer()
+				dRatio = s.Ratio - daily[i-1].Ratio
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%.2f\t%+.2f\t\n", source, s.Time.Format("2006-01-02"),
+				cmd.FormatBytes(s.Uploaded), cmd.FormatBytes(s.Downloaded), cmd.FormatBytes(s.Buffer()),
+				cmd.FormatBytes(dBuffer), s.Ratio, dRatio)
+		}
+
+		if n := len(daily); n > 0 {
+			last := daily[n-1]
+			if w := last.Warning(margin); w != "" {
+				warnings = append(warnings, fmt.Sprintf("[%s] Warning: %s", source, w))
+			}
+			if n > 1 && last.Buffer() < daily[0].Buffer() {
+				warnings = append(warnings, fmt.Sprintf("[%s] Buffer shrank by %s since %s", source,
+					cmd.FormatBytes(daily[0].Buffer()-last.Buffer()), daily[0].Time.Format("2006-01-02")))
+			}
+		}
+	}
+	tw.Flush()
+	for _, w := range warnings {
+		fmt.Println(w)
+	}
+}
@@ -0,0 +1,93 @@
+// Author: EmotionalDots @ PTH
+//
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/emotionaldots/arbitrage/pkg/arbitrage/plan"
+)
+
+func (app *App) journalDir() string {
+	return filepath.Join(app.ConfigDir, "journal")
+}
+
+// Command "apply" checks and executes a plan file written by "downthemall".
+// Every run is recorded in a journal that "undo" can revert. Applied plans
+// are renamed to "[plan].applied".
+func (app *App) Apply() {
+	fs := flag.NewFlagSet("apply", flag.ExitOnError)
+	dryRun := fs.Bool("dry-run", false, "only check the plan and show the actions")
+	fs.Parse(flag.Args()[1:])
+
+	path := fs.Arg(0)
+	if path == "" {
+		path = "arbitrage-plan.json"
+	}
+	if _, err := os.Stat(path); err != nil {
+		log.Fatal(err)
+	}
+	p, err := plan.Load(path)
+	must(err)
+
+	errs := p.Check()
+	for _, err := range errs {
+		fmt.Println("error", err)
+	}
+	if len(errs) > 0 {
+		log.Fatalf("Refusing to apply %s: %d of %d actions failed the check", path, len(errs), len(p.Actions))
+	}
+	if *dryRun {
+		for _, a := range p.Actions {
+			fmt.Println(a)
+		}
+		return
+	}
+
+	abs, _ := filepath.Abs(path)
+	j, err := plan.NewJournal(app.journalDir(), abs)
+	must(err)
+	err = plan.Apply(p, j)
+	for _, e := range j.Entries {
+		if e.Error == "" {
+			fmt.Println(e.Action)
+		}
+	}
+	log.Printf("Applied %d actions, journal: %s", len(j.Entries), j.Path())
+	if err != nil {
+		log.Fatalf("Stopped: %s. Run \"arbitrage undo\" to revert.", err)
+	}
+
+	// the plan cannot be applied twice, keep it for reference
+	done := path + ".applied"
+	must(os.Rename(path, done))
+	log.Printf("Moved plan to %s", done)
+}
+
+// Command "undo" reverts an "apply" run, by default the latest one.
+func (app *App) Undo() {
+	path := flag.Arg(1)
+	if path == "" {
+		var err error
+		path, err = plan.LatestJournal(app.journalDir())
+		must(err)
+	}
+	j, err := plan.LoadJournal(path)
+	must(err)
+
+	errs := plan.Undo(j)
+	for _, err := range errs {
+		fmt.Println("error", err)
+	}
+	if len(errs) > 0 {
+		log.Fatalf("Could not revert %d actions of %s", len(errs), path)
+	}
+	log.Printf("Reverted %s", path)
+}
@@ -0,0 +1,72 @@
+// Author: EmotionalDots @ PTH
+//
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"runtime"
+
+	"github.com/emotionaldots/arbitrage/pkg/integrity"
+)
+
+// Command "check" verifies the integrity of local releases with their
+// SFV, MD5 and FFP files, cue sheets and the audio MD5 of FLAC files.
+func (app *App) Check() {
+	fs, opts := discoveryFlags("check")
+	noAudio := fs.Bool("no-audio", false, "skip decoding FLAC files")
+	quiet := fs.Bool("q", false, "only print failed checks")
+	workers := fs.Int("jobs", runtime.NumCPU(), "number of FLAC files decoded in parallel")
+	fs.Parse(flag.Args()[1:])
+	if fs.NArg() == 0 {
+		log.Fatal("Usage: arbitrage check [dirs...]")
+	}
+
+	checked, failed := 0, 0
+	for _, path := range app.findReleases(fs.Args(), *opts) {
+		results, err := integrity.Check(path, integrity.Options{
+			Audio:   !*noAudio,
+			Workers: *workers,
+		})
+		if err != nil {
+			log.Printf("[%s] %s", path, err)
+			failed++
+			continue
+		}
+
+		if !*quiet {
+			fmt.Printf("# %s\n", path)
+		}
+		ok := true
+		for _, r := range results {
+			if r.Failed() {
+				ok = false
+			} else if *quiet {
+				continue
+			}
+			detail := ""
+			if r.Detail != "" {
+				detail = " (" + r.Detail + ")"
+			}
+			fmt.Printf("%-12s %-4s %q%s\n", r.Status, r.Kind, r.File, detail)
+		}
+
+		checked++
+		if !ok {
+			failed++
+			fmt.Printf("FAILED %q\n", path)
+		} else if len(results) == 0 && !*quiet {
+			fmt.Println("nothing to verify")
+		}
+	}
+
+	log.Printf("Checked %d releases, %d failed", checked, failed)
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
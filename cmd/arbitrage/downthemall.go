@@ -0,0 +1,442 @@
+// Author: EmotionalDots @ PTH
+//
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/emotionaldots/arbitrage/cmd"
+	"github.com/emotionaldots/arbitrage/pkg/arbitrage"
+	"github.com/emotionaldots/arbitrage/pkg/arbitrage/plan"
+	"github.com/emotionaldots/arbitrage/pkg/client"
+	"github.com/emotionaldots/arbitrage/pkg/model"
+)
+
+// Number of concurrent torrent downloads, requests are still limited by the
+// rate limit of the source.
+const downloadWorkers = 2
+
+type job struct {
+	Path     string // local path of the release root
+	Name     string // base name of the release root
+	Hash     string
+	Size     int64 // total size of local files
+	Releases []client.Release
+}
+
+func totalSize(files []arbitrage.File) int64 {
+	var size int64
+	for _, f := range files {
+		size += f.Size
+	}
+	return size
+}
+
+// hashReleases hashes release directories with a bounded number of workers.
+// Hashes already recorded in the state are reused, unless the release was
+// modified since.
+func (app *App) hashReleases(paths []string, workers int, state *arbitrage.State) chan job {
+	in := make(chan string)
+	out := make(chan job)
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for path := range in {
+				mod, modErr := arbitrage.ModTime(path)
+				if state != nil && modErr == nil {
+					if r, ok := state.Get(path); ok && r.Size > 0 && !r.Changed(mod) {
+						out <- job{path, r.Name, r.Hash, r.Size, nil}
+						continue
+					}
+				}
+
+				r, err := arbitrage.FromFile(path)
+				if err != nil {
+					log.Printf("[%s] Could not hash release, skipping: %s", path, err)
+					if state != nil {
+						state.Update(path, func(sr *arbitrage.StateRelease) {
+							sr.Errors = append(sr.Errors, err.Error())
+						})
+					}
+					continue
+				}
+				arbitrage.HashDefault(r)
+				if r.Hash == "" {
+					continue
+				}
+				size := totalSize(r.FileList)
+				if state != nil {
+					state.Update(path, func(sr *arbitrage.StateRelease) {
+						if sr.Hash != r.Hash {
+							// earlier matches belong to the old files
+							sr.Queried, sr.Matches = false, nil
+						}
+						sr.Name, sr.Hash, sr.Size, sr.ModTime = r.FilePath, r.Hash, size, mod
+					})
+				}
+				out <- job{path, r.FilePath, r.Hash, size, nil}
+			}
+		}()
+	}
+
+	go func() {
+		for _, path := range paths {
+			in <- path
+		}
+		close(in)
+		wg.Wait()
+		close(out)
+	}()
+	return out
+}
+
+// batchQueryReleases queries the server for hashed releases in batches of
+// 100. Batches that fail are logged and skipped.
+func (app *App) batchQueryReleases(in chan job, source string) chan []job {
+	queue := make(chan []job, 0)
+	c := client.New(app.Config.Server, cmd.UserAgent)
+
+	go func() {
+		hashes := make([]string, 0, 100)
+		jobs := make([]job, 0, 100)
+
+		doQuery := func() {
+			var releases []client.Release
+			var err error
+			for i := 0; i < 3; i++ {
+				if releases, err = c.Query(source, hashes); err == nil {
+					break
+				}
+				log.Printf("error on try %d/3: %s", i, err)
+				time.Sleep(5 * time.Second)
+			}
+
+			if err != nil {
+				log.Printf("Query failed, skipping %d releases: %s", len(jobs), err)
+			} else {
+				byHash := make(map[string][]client.Release, 0)
+				for _, r := range releases {
+					byHash[r.Hash] = append(byHash[r.Hash], r)
+				}
+				for i, job := range jobs {
+					job.Releases = byHash[job.Hash]
+					jobs[i] = job
+				}
+				queue <- jobs
+			}
+
+			hashes = make([]string, 0, 100)
+			jobs = make([]job, 0, 100)
+		}
+
+		for j := range in {
+			if len(jobs) >= 100 {
+				doQuery()
+			}
+			jobs = append(jobs, j)
+			hashes = append(hashes, j.Hash)
+		}
+		if len(jobs) > 0 {
+			doQuery()
+		}
+		close(queue)
+	}()
+	return queue
+}
+
+// pendingReleases returns the releases that are not done yet or were
+// modified since they were checked.
+func pendingReleases(state *arbitrage.State, paths []string) []string {
+	pending := make([]string, 0)
+	for _, path := range paths {
+		r, ok := state.Get(path)
+		if !ok || !r.Done() {
+			pending = append(pending, path)
+		} else if mod, err := arbitrage.ModTime(path); err != nil || r.Changed(mod) {
+			pending = append(pending, path)
+		}
+	}
+	return pending
+}
+
+func matchStatus(j job, other client.Release) string {
+	if other.FilePath == "" {
+		return "no_filepath"
+	} else if j.Name != other.FilePath {
+		return "renamed"
+	}
+	return "ok"
+}
+
+// recordMatches stores the query result of a release in the state and
+// returns whether the release is done.
+func recordMatches(state *arbitrage.State, j job, owned map[int64]string) bool {
+	var done bool
+	state.Update(j.Path, func(r *arbitrage.StateRelease) {
+		r.Name, r.Hash, r.Size = j.Name, j.Hash, j.Size
+		r.Queried = true
+		r.Matches = r.Matches[:0]
+		for _, other := range j.Releases {
+			r.Matches = append(r.Matches, arbitrage.StateMatch{
+				Id:       other.Id,
+				FilePath: other.FilePath,
+				Status:   matchStatus(j, other),
+				Have:     owned[other.Id],
+			})
+		}
+		done = r.Done()
+	})
+	return done
+}
+
+// Command "downthemall" discovers all releases in the given directories,
+// looks them up on the server and downloads matching torrents.
+// Progress is checkpointed to a state file, so that interrupted runs resume
+// where they stopped.
+func (app *App) DownThemAll() {
+	fs, opts := discoveryFlags("downthemall")
+	statePath := fs.String("state", "", "checkpoint `file`, defaults to arbitrage-[source].json")
+	dryRun := fs.Bool("dry-run", false, "only show which torrents would be downloaded")
+	workers := fs.Int("jobs", runtime.NumCPU(), "number of directories hashed in parallel")
+	planPath := fs.String("plan", "arbitrage-plan.json", "plan `file` for \"arbitrage apply\"")
+	policy := fs.String("policy", "", "select between matching torrents by `policy`: seeders, freeleech or original")
+	op := fs.String("action", plan.OpRename, "planned action for renamed releases: rename, hardlink, symlink or reflink")
+	fs.Parse(flag.Args()[1:])
+
+	args := fs.Args()
+	if len(args) < 2 {
+		log.Fatal("Usage: arbitrage downthemall [source] [dirs...]")
+	}
+	source := args[0]
+	dirs := args[1:]
+	if *statePath == "" {
+		*statePath = "arbitrage-" + source + ".json"
+	}
+	if *workers < 1 {
+		*workers = 1
+	}
+
+	if !app.APIForSource(source).Capabilities().Download {
+		log.Fatalf("[%s] Downloading torrents is not supported by this tracker", source)
+	}
+	if !validOp(*op) {
+		log.Fatalf("Unknown action %q, expected one of %v", *op, plan.Ops)
+	}
+	if *policy == "" {
+		*policy = app.Config.Sources[source].MatchPolicy()
+	}
+	must(client.ValidPolicy(*policy))
+	state, err := arbitrage.LoadState(*statePath, source)
+	must(err)
+	pl, err := plan.Load(*planPath)
+	must(err)
+
+	var c cmd.API
+	var account *model.Account
+	var lw io.Writer = os.Stdout
+	if !*dryRun {
+		c = app.DoLogin(source)
+		if a, ok := c.(cmd.AccountAPI); ok {
+			if acc, err := a.GetAccount(); err == nil {
+				account = &acc
+			} else {
+				log.Printf("[%s] Could not check ratio: %s", source, err)
+			}
+		}
+
+		logf, err := os.OpenFile("arbitrage.log", os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+		must(err)
+		defer logf.Close()
+		if fi, err := logf.Stat(); err == nil && fi.Size() == 0 {
+			fmt.Fprintf(logf, "#!/usr/bin/env bash\n")
+		}
+		fmt.Fprintf(logf, "\n## %s arbitrage downthemall %s %q\n\n", time.Now().Format(time.RFC3339), source, dirs)
+		lw = io.MultiWriter(os.Stdout, logf)
+	}
+
+	var logMu sync.Mutex
+	logLine := func(format string, args ...interface{}) {
+		logMu.Lock()
+		defer logMu.Unlock()
+		fmt.Fprintf(lw, format, args...)
+	}
+	var planMu sync.Mutex
+	addAction := func(a plan.Action) {
+		planMu.Lock()
+		defer planMu.Unlock()
+		if pl.Add(a) {
+			must(pl.Save(*planPath))
+		}
+	}
+	save := func() {
+		if !*dryRun {
+			if err := state.Save(*statePath); err != nil {
+				log.Printf("Could not save state: %s", err)
+			}
+		}
+	}
+
+	paths := pendingReleases(state, app.findReleases(dirs, *opts))
+	log.Printf("Found %d releases to check", len(paths))
+
+	owned := app.ownedTorrents(source)
+	guard, err := cmd.NewGuard(source, app.Config.Sources[source], account)
+	must(err)
+	if _, ok := c.(cmd.TokenAPI); !ok {
+		guard.UseTokens = false
+	}
+	log.Println(guard)
+
+	limit := time.NewTicker(app.Config.Sources[source].Interval())
+	defer limit.Stop()
+
+	downloads := make(chan job)
+	var wg sync.WaitGroup
+	for i := 0; i < downloadWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range downloads {
+				// try matches in order of preference until one succeeds
+				for _, other := range client.Rank(j.Releases, *policy) {
+					useToken, err := guard.Reserve(other.Size, j.Size, other.FreeTorrent)
+					if err != nil {
+						logLine("# skipped %s:%d %q: %s\n", source, other.Id, j.Path, err)
+						continue
+					}
+					if *dryRun {
+						token := ""
+						if useToken {
+							token = " with token"
+						}
+						logLine("# would download%s %s:%d %s %q%s\n", token, source, other.Id, matchStatus(j, other), j.Path, describeMatch(other))
+						break
+					}
+
+					<-limit.C
+					name, err := app.downloadMatch(c, source, j, other, "", useToken)
+					if err == cmd.ErrTokenFailed {
+						log.Printf("[%s:%d] %s, no longer using tokens", source, other.Id, err)
+						guard.NoTokensLeft()
+						guard.Cancel(other.Size, j.Size, true)
+						useToken = false
+						if _, err = guard.Reserve(other.Size, j.Size, other.FreeTorrent); err != nil {
+							logLine("# skipped %s:%d %q: %s\n", source, other.Id, j.Path, err)
+							continue
+						}
+						<-limit.C
+						name, err = app.downloadMatch(c, source, j, other, "", false)
+					}
+					if err == nil {
+						if j.Name != name {
+							target := filepath.Join(filepath.Dir(j.Path), name)
+							logLine("mv %q %q    # %s:%d\n", j.Path, target, source, other.Id)
+							addAction(plan.Action{
+								Op:      *op,
+								Source:  j.Path,
+								Target:  target,
+								Torrent: fmt.Sprintf("%s:%d", source, other.Id),
+							})
+						} else {
+							logLine("# ok %s:%d %q\n", source, other.Id, j.Path)
+						}
+						state.Update(j.Path, func(r *arbitrage.StateRelease) {
+							r.Downloaded = append(r.Downloaded, other.Id)
+						})
+						save()
+						break
+					}
+					guard.Cancel(other.Size, j.Size, useToken)
+					log.Printf("[%s:%d] %s, skipping\n", source, other.Id, err)
+					logLine("# error %s:%d %q: %s\n", source, other.Id, j.Path, err)
+					state.Update(j.Path, func(r *arbitrage.StateRelease) {
+						r.Errors = append(r.Errors, fmt.Sprintf("%s:%d: %s", source, other.Id, err))
+					})
+				}
+			}
+		}()
+	}
+
+	hashed := app.hashReleases(paths, *workers, state)
+	for jobs := range app.batchQueryReleases(hashed, source) {
+		for _, j := range jobs {
+			if !recordMatches(state, j, owned) {
+				downloads <- j
+			} else if id, typ := ownedMatch(j, owned); typ != "" {
+				logLine("# %s %s:%d %q\n", typ, source, id, j.Path)
+			}
+		}
+		save()
+	}
+	close(downloads)
+	wg.Wait()
+	save()
+	log.Println(guard)
+}
+
+// downloadMatch downloads and saves a single matching torrent to a directory
+// and returns the name of its root file or directory.
+func (app *App) downloadMatch(c cmd.API, source string, j job, other client.Release, dir string, useToken bool) (string, error) {
+	var torrent []byte
+	var err error
+	if t, ok := c.(cmd.TokenAPI); ok && useToken {
+		torrent, err = t.DownloadWithToken(int(other.Id))
+	} else {
+		torrent, err = c.Download(int(other.Id))
+	}
+	if err == cmd.ErrTokenFailed {
+		return "", err
+	} else if err != nil {
+		return "", fmt.Errorf("could not download torrent: %s", err)
+	}
+
+	name, err := app.GetTorrentName(torrent)
+	if err != nil {
+		return "", fmt.Errorf("invalid torrent file: %s", err)
+	}
+
+	status := "ok"
+	if j.Name != name {
+		status = "renamed"
+	}
+	tfile := fmt.Sprintf("%s-%d-%s.torrent", source, other.Id, status)
+	if err := app.SaveTorrent(torrent, filepath.Join(dir, tfile)); err != nil {
+		return "", fmt.Errorf("could not save torrent: %s", err)
+	}
+	return name, nil
+}
+
+func validOp(op string) bool {
+	for _, o := range plan.Ops {
+		if o == op {
+			return true
+		}
+	}
+	return false
+}
+
+// describeMatch summarizes the metadata of a match, if known.
+func describeMatch(r client.Release) string {
+	if r.Format == "" {
+		return ""
+	}
+	desc := fmt.Sprintf(" (%s %s %s, %s, %d seeders", r.Media, r.Format, r.Encoding, r.Edition, r.Seeders)
+	if r.FreeTorrent {
+		desc += ", freeleech"
+	}
+	return desc + ")"
+}
@@ -0,0 +1,91 @@
+// Author: EmotionalDots @ PTH
+//
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/emotionaldots/arbitrage/pkg/arbitrage"
+)
+
+func writeRelease(t *testing.T, dir string, files map[string]string) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func hashOne(t *testing.T, path string, state *arbitrage.State) job {
+	app := &App{}
+	jobs := make([]job, 0)
+	for j := range app.hashReleases([]string{path}, 1, state) {
+		jobs = append(jobs, j)
+	}
+	if len(jobs) != 1 {
+		t.Fatalf("expected one job, got %v", jobs)
+	}
+	return jobs[0]
+}
+
+func TestHashReleasesResume(t *testing.T) {
+	root, err := ioutil.TempDir("", "arbitrage-downthemall")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	album := filepath.Join(root, "Album")
+	writeRelease(t, album, map[string]string{"01.flac": "one", "02.flac": "two"})
+	state, _ := arbitrage.LoadState(filepath.Join(root, "state.json"), "red")
+
+	j := hashOne(t, album, state)
+	r, ok := state.Get(album)
+	if !ok || r.Hash != j.Hash || r.Size != 6 || j.Size != 6 {
+		t.Fatalf("expected hash to be recorded, got %+v for %+v", r, j)
+	}
+
+	// unchanged releases reuse the recorded hash and matches
+	state.Update(album, func(r *arbitrage.StateRelease) {
+		r.Hash, r.Queried = "cached", true
+		r.Matches = []arbitrage.StateMatch{{Id: 1}}
+	})
+	if j := hashOne(t, album, state); j.Hash != "cached" {
+		t.Errorf("expected cached hash, got %s", j.Hash)
+	}
+	if pending := pendingReleases(state, []string{album}); len(pending) != 1 {
+		t.Errorf("expected release with pending match to be resumed, got %v", pending)
+	}
+	state.Update(album, func(r *arbitrage.StateRelease) { r.Downloaded = []int64{1} })
+	if pending := pendingReleases(state, []string{album}); len(pending) != 0 {
+		t.Errorf("expected done release to be skipped, got %v", pending)
+	}
+
+	// edited releases are hashed and checked again
+	later := time.Now().Add(time.Minute)
+	writeRelease(t, album, map[string]string{"03.flac": "three"})
+	if err := os.Chtimes(album, later, later); err != nil {
+		t.Fatal(err)
+	}
+	if pending := pendingReleases(state, []string{album}); !reflect.DeepEqual(pending, []string{album}) {
+		t.Errorf("expected edited release to be checked again, got %v", pending)
+	}
+	j = hashOne(t, album, state)
+	if j.Hash == "cached" || j.Size != 11 {
+		t.Errorf("expected edited release to be hashed again, got %+v", j)
+	}
+	if r, _ := state.Get(album); r.Queried || len(r.Matches) != 0 || r.Hash != j.Hash {
+		t.Errorf("expected stale matches to be reset, got %+v", r)
+	}
+}
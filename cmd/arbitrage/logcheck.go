@@ -0,0 +1,118 @@
+// Author: EmotionalDots @ PTH
+//
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/emotionaldots/arbitrage/cmd"
+	"github.com/emotionaldots/arbitrage/pkg/client"
+	"github.com/emotionaldots/arbitrage/pkg/riplog"
+)
+
+// findLogs returns all rip logs in a release.
+func findLogs(root string) []string {
+	logs := make([]string, 0)
+	filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() && strings.EqualFold(filepath.Ext(path), ".log") {
+			logs = append(logs, path)
+		}
+		return nil
+	})
+	return logs
+}
+
+// Command "logcheck" scores the EAC and XLD logs of local releases and,
+// with -source, compares them to the log score of the matching torrent.
+func (app *App) LogCheck() {
+	fs, opts := discoveryFlags("logcheck")
+	source := fs.String("source", "", "compare with the log score of matching torrents on `source`")
+	fs.Parse(flag.Args()[1:])
+	if fs.NArg() == 0 {
+		log.Fatal("Usage: arbitrage logcheck [-source source] [dirs...]")
+	}
+
+	paths := make([]string, 0)
+	scores := make(map[string]int)
+	for _, path := range app.findReleases(fs.Args(), *opts) {
+		logs := findLogs(path)
+		if len(logs) == 0 {
+			continue
+		}
+		paths = append(paths, path)
+
+		// the release scores as its worst log, like on the tracker
+		min, parsed := 100, false
+		for _, lpath := range logs {
+			// unreadable logs are reported like unparseable ones, the
+			// release stays unscored unless another log parses
+			raw, err := ioutil.ReadFile(lpath)
+			if err != nil {
+				fmt.Printf("  ? %q: %s\n", lpath, err)
+				continue
+			}
+			l, err := riplog.Parse(raw)
+			if err != nil {
+				fmt.Printf("  ? %q: %s\n", lpath, err)
+				continue
+			}
+			score := l.Score()
+			if score < min {
+				min = score
+			}
+			parsed = true
+			fmt.Printf("%3d %q %s %s, %d/%d tracks AccurateRip\n", score, lpath, l.Ripper, l.Version, l.AccurateRip(), len(l.Tracks))
+			for _, d := range l.Deductions() {
+				fmt.Printf("    -%d %s\n", d.Points, d.Reason)
+			}
+		}
+		if parsed {
+			scores[path] = min
+		}
+	}
+
+	if *source == "" || len(paths) == 0 {
+		return
+	}
+
+	c := client.New(app.Config.Server, cmd.UserAgent)
+	hashed := app.hashReleases(paths, runtime.NumCPU(), nil)
+	for jobs := range app.batchQueryReleases(hashed, *source) {
+		for _, j := range jobs {
+			for _, match := range j.Releases {
+				tg, err := c.Torrent(*source, match.Id)
+				if err != nil {
+					log.Printf("[%s:%d] Not in index: %s", *source, match.Id, err)
+					continue
+				}
+				t := tg.Torrent
+				score, scored := scores[j.Path]
+				if !scored {
+					// none of the logs could be parsed
+					fmt.Printf("unscored %s:%d local ?, tracker %d %q\n", *source, match.Id, t.LogScore, j.Path)
+					continue
+				}
+				state := "same"
+				switch {
+				case !bool(t.HasLog):
+					state = "no_log_on_tracker"
+				case score > int(t.LogScore):
+					state = "better"
+				case score < int(t.LogScore):
+					state = "worse"
+				}
+				fmt.Printf("%s %s:%d local %d, tracker %d %q\n", state, *source, match.Id, score, t.LogScore, j.Path)
+			}
+		}
+	}
+}
@@ -9,17 +9,18 @@ import (
 	"bytes"
 	"flag"
 	"fmt"
-	"io"
 	"io/ioutil"
 	"log"
 	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
 	"strconv"
-	"time"
+	"strings"
 
 	"github.com/emotionaldots/arbitrage/cmd"
 	"github.com/emotionaldots/arbitrage/pkg/arbitrage"
 	"github.com/emotionaldots/arbitrage/pkg/arbitrage/torrentinfo"
-	"github.com/emotionaldots/arbitrage/pkg/client"
 	"github.com/emotionaldots/arbitrage/pkg/model"
 )
 
@@ -28,16 +29,66 @@ var bootstrapUrl string
 const Usage = `Usage: arbitrage [command] [args...]
 
 Local directory commands:
-	lookup [source] [dir]: Find releases with matching hash for directory
+	lookup [source] [dir]: Find releases with matching hash for directory or library
 	hash   [dir]:          Print hashes for a torrent directory
+	apply  [plan]:         Check and execute the renames and links planned by downthemall
+	undo   [journal]:      Revert an apply run, by default the latest one
+	report [state files]:  Write an HTML or Markdown report of downthemall or lookup -state results
+	check [dirs]:          Verify releases with their SFV, MD5, FFP and cue files and FLAC audio MD5
+	logcheck [dirs]:       Score EAC/XLD logs, with -source compare them to the tracker's log score
+	opportunities [source] [dirs]:
+	                       List missing formats and cue sheets we could upload from local releases
+
+Configuration commands:
+	config check [--login]:       Validate all sources and optionally test each login
+	config secret [source]:       Store a tracker password in the encrypted secrets file
 
 Tracker API commands:
+	sources                       List configured trackers and their capabilities
+	account [sources]             Show ratio and statistics of all accounts (-history for trends)
+	mytorrents import [sources]   Import seeding, leeching, uploaded and snatched lists, which
+	                              lookup and downthemall use to mark and skip torrents we have
+	mytorrents orphans [source] [dirs]:
+	                              List seeding torrents without a matching local release
 	download [source:id]          Download a torrent from tracker
 	downthemall [source] [dirs]:  Walk through all subdirectories and download matching torrents
+	                              (resumable, see -state and -dry-run)
+	watch [dirs]:                 Wait for new downloads and fetch matching torrents from all sources
+
+Release discovery options (lookup, downthemall, opportunities, mytorrents orphans):
+	-include [glob]   Only consider releases matching the glob (repeatable)
+	-exclude [glob]   Skip releases and directories matching the glob (repeatable)
+	-single           Treat loose media files in the given dirs as single-file releases
+
+Download options (downthemall):
+	-state [file]     Checkpoint file, defaults to arbitrage-[source].json
+	-dry-run          Only show which torrents would be downloaded
+	-jobs [n]         Number of directories hashed in parallel
+	-plan [file]      Plan file for apply, defaults to arbitrage-plan.json
+	-action [op]      Planned action: rename (default), hardlink, symlink or reflink
+	-policy [policy]  Select between matching torrents: seeders (default), freeleech or original
+
+	Downloads that need data beyond local files are limited by the ratio buffer and
+	the per-source settings max_download, min_buffer, use_tokens and token_threshold.
+
+Report options:
+	-format [html|md] Output format (html)
+	-o [file]         Output file, defaults to stdout
+	-metadata         Fetch torrent metadata from the server for uncached matches
+
+Watch options:
+	-settle [duration]  Time without changes before a new directory is hashed (2m)
+	-sources [list]     Comma-separated sources to query, defaults to all
+	-out [dir]          Directory to save torrents to
+	-plan, -action      As above, watch plans symlinks by default
 
 Example Usage:
 	arbitrage lookup "./Various Artists - The What CD [FLAC]/"
 	arbitrage download pth:41950
+	arbitrage downthemall -exclude "*Live*" pth ~/Music
+
+Set ARBITRAGE_REPLAY=record or ARBITRAGE_REPLAY=replay to record tracker
+requests as fixtures or replay them offline (ARBITRAGE_FIXTURES=dir).
 `
 
 func must(err error) {
@@ -63,12 +114,34 @@ func (app *App) Run() {
 		app.Hash()
 	case "lookup":
 		app.Lookup()
+	case "apply":
+		app.Apply()
+	case "undo":
+		app.Undo()
+	case "report":
+		app.Report()
+	case "opportunities":
+		app.Opportunities()
+	case "logcheck":
+		app.LogCheck()
+	case "check":
+		app.Check()
+	case "config":
+		app.ConfigCommand()
+	case "sources":
+		app.Sources()
+	case "account":
+		app.Account()
+	case "mytorrents":
+		app.MyTorrents()
 	case "download":
 		app.Download()
 	case "downthemall":
 		app.DownThemAll()
+	case "watch":
+		app.Watch()
 	default:
-		fmt.Println(Usage)
+		fmt.Print(Usage)
 	}
 }
 
@@ -80,30 +153,123 @@ func (app *App) Hash() {
 	fmt.Println(r.Hash)
 }
 
+// globList collects repeated glob flags.
+type globList []string
+
+func (l *globList) String() string { return strings.Join(*l, ",") }
+
+func (l *globList) Set(v string) error {
+	if _, err := filepath.Match(v, ""); err != nil {
+		return err
+	}
+	*l = append(*l, v)
+	return nil
+}
+
+// discoveryFlags returns a flag set for a subcommand with the release
+// discovery options.
+func discoveryFlags(name string) (*flag.FlagSet, *arbitrage.DiscoverOptions) {
+	opts := &arbitrage.DiscoverOptions{}
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	fs.Var((*globList)(&opts.Include), "include", "only consider releases matching `glob`")
+	fs.Var((*globList)(&opts.Exclude), "exclude", "skip releases and directories matching `glob`")
+	fs.BoolVar(&opts.SingleFiles, "single", false, "treat loose media files in the given directories as single-file releases")
+	return fs, opts
+}
+
+func (app *App) findReleases(dirs []string, opts arbitrage.DiscoverOptions) []string {
+	paths := make([]string, 0)
+	for _, dir := range dirs {
+		found, err := arbitrage.FindReleases(dir, opts)
+		must(err)
+		paths = append(paths, found...)
+	}
+	return paths
+}
+
 func (app *App) Lookup() {
-	source := flag.Arg(1)
-	dir := flag.Arg(2)
-	r, err := arbitrage.FromFile(dir)
-	must(err)
-	arbitrage.HashDefault(r)
+	fs, opts := discoveryFlags("lookup")
+	statePath := fs.String("state", "", "record the results in a state `file` for \"arbitrage report\"")
+	fs.Parse(flag.Args()[1:])
+	args := fs.Args()
+	if len(args) < 2 {
+		log.Fatal("Usage: arbitrage lookup [source] [dir]")
+	}
+	source := args[0]
+	paths := app.findReleases(args[1:], *opts)
+
+	var state *arbitrage.State
+	if *statePath != "" {
+		var err error
+		state, err = arbitrage.LoadState(*statePath, source)
+		must(err)
+		defer func() { must(state.Save(*statePath)) }()
+	}
 
-	c := client.New(app.Config.Server, cmd.UserAgent)
-	releases, err := c.Query(source, []string{r.Hash})
-	must(err)
+	owned := app.ownedTorrents(source)
+	hashed := app.hashReleases(paths, runtime.NumCPU(), state)
+	for jobs := range app.batchQueryReleases(hashed, source) {
+		for _, job := range jobs {
+			if state != nil {
+				recordMatches(state, job, owned)
+			}
+			if len(paths) > 1 {
+				fmt.Printf("# %s\n", job.Path)
+			}
+			for _, other := range job.Releases {
+				have := ""
+				if typ := owned[other.Id]; typ != "" {
+					have = " [" + typ + "]"
+				}
+				fmt.Printf("%s %s:%d %q%s%s\n", matchStatus(job, other), source, other.Id, other.FilePath, describeMatch(other), have)
+			}
+		}
+	}
+}
 
-	for _, other := range releases {
-		state := "ok"
-		if other.FilePath == "" {
-			state = "no_filepath"
-		} else if r.FilePath != other.FilePath {
-			state = "renamed"
+func (app *App) ConfigCommand() {
+	switch flag.Arg(1) {
+	case "check":
+		if !app.CheckConfig(flag.Arg(2) == "--login") {
+			os.Exit(1)
+		}
+	case "secret":
+		source := flag.Arg(2)
+		if _, ok := app.Config.Sources[source]; !ok {
+			log.Fatal("Unknown source: ", source)
+		}
+		password, err := cmd.Prompt("Password for " + source + ": ")
+		must(err)
+		must(app.SetSecret(source, password))
+		log.Printf("Stored password for %s in %s", source, app.SecretsFile())
+	default:
+		fmt.Print(Usage)
+	}
+}
+
+func (app *App) Sources() {
+	names := make([]string, 0, len(app.Config.Sources))
+	for name := range app.Config.Sources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		s := app.Config.Sources[name]
+		caps := app.APIForSource(name).Capabilities()
+		download := "no download"
+		if caps.Download {
+			download = "download"
 		}
-		fmt.Printf("%s %s:%d %q\n", state, source, other.Id, other.FilePath)
+		fmt.Printf("%s\t%s\t%s\t%s, %s\n", name, s.Type, s.Url, strings.Join(caps.Types, "/"), download)
 	}
 }
 
 func (app *App) Download() {
 	source, id := cmd.ParseSourceId(flag.Arg(1))
+	if !app.APIForSource(source).Capabilities().Download {
+		log.Fatalf("[%s] Downloading torrents is not supported by this tracker", source)
+	}
 	c := app.DoLogin(source)
 
 	torrent, err := c.Download(id)
@@ -133,163 +299,6 @@ func (app *App) SaveTorrent(torrent []byte, path string) error {
 	return ioutil.WriteFile(path, torrent, 0644)
 }
 
-type job struct {
-	LocalDir string
-	Hash     string
-	Releases []client.Release
-}
-
-func (app *App) batchQueryDirectory(dir, source string) chan []job {
-	fdir, err := os.Open(dir)
-	must(err)
-	defer fdir.Close()
-
-	names, err := fdir.Readdirnames(-1)
-	must(err)
-
-	queue := make(chan []job, 0)
-	c := client.New(app.Config.Server, cmd.UserAgent)
-
-	go func() {
-		hashes := make([]string, 0, 100)
-		jobs := make([]job, 0, 100)
-
-		doQuery := func() {
-			var releases []client.Release
-			for i := 0; i < 3; i++ {
-				if releases, err = c.Query(source, hashes); err == nil {
-					break
-				}
-				log.Printf("error on try %d/3: %s", i, err)
-				time.Sleep(5 * time.Second)
-			}
-			must(err)
-
-			byHash := make(map[string][]client.Release, 0)
-			for _, r := range releases {
-				byHash[r.Hash] = append(byHash[r.Hash], r)
-			}
-			for i, job := range jobs {
-				job.Releases = byHash[job.Hash]
-				jobs[i] = job
-			}
-
-			queue <- jobs
-			hashes = make([]string, 0, 100)
-			jobs = make([]job, 0, 100)
-		}
-
-		for _, n := range names {
-			r, err := arbitrage.FromFile(dir + "/" + n)
-			must(err)
-			arbitrage.HashDefault(r)
-
-			if len(jobs) >= 100 {
-				doQuery()
-			}
-			jobs = append(jobs, job{r.FilePath, r.Hash, nil})
-			hashes = append(hashes, r.Hash)
-		}
-		if len(jobs) > 0 {
-			doQuery()
-		}
-		close(queue)
-	}()
-	return queue
-}
-
-func (app *App) DownThemAll() {
-	source := flag.Arg(1)
-	dir := flag.Arg(2)
-
-	c := app.DoLogin(source)
-
-	logf, err := os.Create("arbitrage.log")
-	must(err)
-	defer logf.Close()
-	lw := io.MultiWriter(os.Stdout, logf)
-	fmt.Fprintf(logf, "#!/usr/bin/env bash\n## arbitrage downthemall %s %q\n\n\n", source, dir)
-
-	for jobs := range app.batchQueryDirectory(dir, source) {
-		for _, job := range jobs {
-			for _, other := range job.Releases {
-				torrent, err := c.Download(int(other.Id))
-				if err != nil {
-					log.Printf("[%s:%d] Could not download torrent, skipping: %s\n", source, other.Id, err)
-					continue
-				}
-
-				path, err := app.GetTorrentName(torrent)
-				if err != nil {
-					log.Printf("[%s:%d] Invalid torrent file, skipping: %s\n", source, other.Id, err)
-					continue
-				}
-
-				status := "ok"
-				if job.LocalDir != path {
-					status = "renamed"
-					fmt.Fprintf(lw, "mv %q %q    # %s:%d\n", job.LocalDir, path, source, other.Id)
-				} else {
-					fmt.Fprintf(lw, "# ok %s:%d %q\n", source, other.Id, path)
-				}
-
-				tfile := fmt.Sprintf("%s-%d-%s.torrent", source, other.Id, status)
-				must(app.SaveTorrent(torrent, tfile))
-
-				time.Sleep(200 * time.Millisecond) // Rate-limiting
-				break
-			}
-		}
-	}
-}
-
 func GroupToInfo(gt model.GroupAndTorrents) arbitrage.InfoRelease {
-	g := gt.Group
-	t := gt.Torrents[0]
-
-	r := arbitrage.InfoRelease{}
-	r.Name = g.Name
-	r.TorrentId = t.ID
-	r.FilePath = t.FilePath
-	r.Tags = g.Tags
-	r.Description = g.WikiBody
-	r.Image = g.WikiImage
-
-	r.Format = t.Media + " / " + t.Format
-	if t.HasLog {
-		r.Format += " / " + strconv.Itoa(t.LogScore)
-	}
-
-	if t.Remastered {
-		r.Year = t.RemasterYear
-		r.RecordLabel = t.RemasterRecordLabel
-		r.CatalogueNumber = t.RemasterCatalogueNumber
-		r.Edition = t.RemasterTitle
-	} else {
-		r.Year = g.Year
-		r.RecordLabel = g.RecordLabel
-		r.CatalogueNumber = g.CatalogueNumber
-		r.Edition = "Original Release"
-	}
-
-	for _, a := range g.MusicInfo.Composers {
-		r.Composers = append(r.Composers, a.Name)
-	}
-	for _, a := range g.MusicInfo.Artists {
-		r.Artists = append(r.Artists, a.Name)
-	}
-	for _, a := range g.MusicInfo.With {
-		r.With = append(r.With, a.Name)
-	}
-	for _, a := range g.MusicInfo.DJ {
-		r.DJ = append(r.DJ, a.Name)
-	}
-	for _, a := range g.MusicInfo.RemixedBy {
-		r.RemixedBy = append(r.RemixedBy, a.Name)
-	}
-	for _, a := range g.MusicInfo.Producer {
-		r.Producer = append(r.Producer, a.Name)
-	}
-
-	return r
+	return cmd.ReleaseInfo(gt.Group, gt.Torrents[0])
 }
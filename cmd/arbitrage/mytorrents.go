@@ -0,0 +1,261 @@
+// Author: EmotionalDots @ PTH
+//
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"runtime"
+	"sort"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/emotionaldots/arbitrage/cmd"
+	"github.com/emotionaldots/arbitrage/pkg/arbitrage"
+	"github.com/emotionaldots/arbitrage/pkg/model"
+)
+
+var (
+	bucketUserTorrents = []byte("user_torrents")
+	keyUpdated         = []byte("updated")
+)
+
+// saveUserTorrents replaces a stored user torrent list of a source.
+func saveUserTorrents(db *bolt.DB, source, typ string, list []model.UserTorrent) error {
+	return db.Update(func(tx *bolt.Tx) error {
+		root, err := tx.CreateBucketIfNotExists(bucketUserTorrents)
+		if err != nil {
+			return err
+		}
+		sb, err := root.CreateBucketIfNotExists([]byte(source))
+		if err != nil {
+			return err
+		}
+		if sb.Bucket([]byte(typ)) != nil {
+			if err := sb.DeleteBucket([]byte(typ)); err != nil {
+				return err
+			}
+		}
+		b, err := sb.CreateBucket([]byte(typ))
+		if err != nil {
+			return err
+		}
+		for _, t := range list {
+			raw, err := json.Marshal(t)
+			if err != nil {
+				return err
+			}
+			if err := b.Put([]byte(strconv.Itoa(int(t.TorrentID))), raw); err != nil {
+				return err
+			}
+		}
+		return sb.Put(keyUpdated, []byte(time.Now().UTC().Format(time.RFC3339)))
+	})
+}
+
+// loadUserTorrents returns a stored user torrent list of a source and the
+// time the lists of the source were last imported.
+func loadUserTorrents(db *bolt.DB, source, typ string) ([]model.UserTorrent, time.Time, error) {
+	list := make([]model.UserTorrent, 0)
+	var updated time.Time
+	err := db.View(func(tx *bolt.Tx) error {
+		root := tx.Bucket(bucketUserTorrents)
+		if root == nil {
+			return nil
+		}
+		sb := root.Bucket([]byte(source))
+		if sb == nil {
+			return nil
+		}
+		updated, _ = time.Parse(time.RFC3339, string(sb.Get(keyUpdated)))
+		b := sb.Bucket([]byte(typ))
+		if b == nil {
+			return nil
+		}
+		return b.ForEach(func(k, v []byte) error {
+			var t model.UserTorrent
+			if err := json.Unmarshal(v, &t); err != nil {
+				return err
+			}
+			list = append(list, t)
+			return nil
+		})
+	})
+	sort.Slice(list, func(i, j int) bool { return list[i].TorrentID < list[j].TorrentID })
+	return list, updated, err
+}
+
+// ownedTorrents returns the imported torrent IDs of a source with the first
+// user torrent list they are on. It is empty if the lists were not imported
+// or the local database is unavailable.
+func (app *App) ownedTorrents(source string) map[int64]string {
+	owned := make(map[int64]string)
+	db, err := app.openLocalDB()
+	if err != nil {
+		log.Printf("[%s] Could not open local database, not checking seeding torrents: %s", source, err)
+		return owned
+	}
+	defer db.Close()
+
+	for _, typ := range model.UserTorrentTypes {
+		list, _, err := loadUserTorrents(db, source, typ)
+		if err != nil {
+			log.Printf("[%s] Could not load %s torrents: %s", source, typ, err)
+			continue
+		}
+		for _, t := range list {
+			if _, ok := owned[int64(t.TorrentID)]; !ok {
+				owned[int64(t.TorrentID)] = typ
+			}
+		}
+	}
+	return owned
+}
+
+// ownedMatch returns the first match of a job that is on one of the user's
+// torrent lists.
+func ownedMatch(j job, owned map[int64]string) (int64, string) {
+	for _, other := range j.Releases {
+		if typ := owned[other.Id]; typ != "" {
+			return other.Id, typ
+		}
+	}
+	return 0, ""
+}
+
+// Command "mytorrents" imports the user's seeding, leeching, uploaded and
+// snatched lists, which lookup and downthemall use to skip torrents we
+// already have.
+func (app *App) MyTorrents() {
+	switch flag.Arg(1) {
+	case "import":
+		app.importUserTorrents(flag.Args()[2:])
+	case "orphans":
+		app.orphanedTorrents()
+	default:
+		app.showUserTorrents(flag.Args()[1:])
+	}
+}
+
+func (app *App) userTorrentSources(sources []string) []string {
+	if len(sources) > 0 {
+		return sources
+	}
+	for name := range app.Config.Sources {
+		sources = append(sources, name)
+	}
+	sort.Strings(sources)
+	return sources
+}
+
+func (app *App) importUserTorrents(sources []string) {
+	db, err := app.openLocalDB()
+	must(err)
+	defer db.Close()
+
+	for _, source := range app.userTorrentSources(sources) {
+		c, err := app.TryLogin(source)
+		if err != nil {
+			log.Printf("[%s] %s", source, err)
+			continue
+		}
+		u, ok := c.(cmd.UserTorrentsAPI)
+		if !ok {
+			log.Printf("[%s] Listing user torrents is not supported by this tracker", source)
+			continue
+		}
+		for _, typ := range model.UserTorrentTypes {
+			list, err := u.UserTorrents(typ)
+			if err != nil {
+				log.Printf("[%s] Could not list %s torrents: %s", source, typ, err)
+				continue
+			}
+			must(saveUserTorrents(db, source, typ, list))
+			log.Printf("[%s] Imported %d %s torrents", source, len(list), typ)
+		}
+	}
+}
+
+func (app *App) showUserTorrents(sources []string) {
+	db, err := app.openLocalDB()
+	must(err)
+	defer db.Close()
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "Source\tSeeding\tLeeching\tUploaded\tSnatched\tImported")
+	for _, source := range app.userTorrentSources(sources) {
+		var updated time.Time
+		fmt.Fprintf(tw, "%s", source)
+		for _, typ := range model.UserTorrentTypes {
+			list, u, err := loadUserTorrents(db, source, typ)
+			must(err)
+			updated = u
+			fmt.Fprintf(tw, "\t%d", len(list))
+		}
+		if updated.IsZero() {
+			fmt.Fprintln(tw, "\tnever")
+		} else {
+			fmt.Fprintf(tw, "\t%s\n", updated.Local().Format("2006-01-02 15:04"))
+		}
+	}
+	tw.Flush()
+}
+
+// orphanedTorrents lists torrents the tracker thinks we are seeding that have
+// no matching release in the given directories, e.g. because they were
+// removed locally.
+func (app *App) orphanedTorrents() {
+	fs, opts := discoveryFlags("mytorrents orphans")
+	statePath := fs.String("state", "", "reuse hashes from a state `file`")
+	fs.Parse(flag.Args()[2:])
+	args := fs.Args()
+	if len(args) < 2 {
+		log.Fatal("Usage: arbitrage mytorrents orphans [source] [dirs...]")
+	}
+	source := args[0]
+
+	db, err := app.openLocalDB()
+	must(err)
+	seeding, updated, err := loadUserTorrents(db, source, "seeding")
+	db.Close()
+	must(err)
+	if updated.IsZero() {
+		log.Fatalf("[%s] No torrent lists imported, run \"arbitrage mytorrents import %s\" first", source, source)
+	}
+
+	var state *arbitrage.State
+	if *statePath != "" {
+		state, err = arbitrage.LoadState(*statePath, source)
+		must(err)
+	}
+
+	local := make(map[int64]bool)
+	paths := app.findReleases(args[1:], *opts)
+	hashed := app.hashReleases(paths, runtime.NumCPU(), state)
+	for jobs := range app.batchQueryReleases(hashed, source) {
+		for _, j := range jobs {
+			for _, other := range j.Releases {
+				local[other.Id] = true
+			}
+		}
+	}
+
+	missing := 0
+	for _, t := range seeding {
+		if local[int64(t.TorrentID)] {
+			continue
+		}
+		missing++
+		fmt.Printf("%s:%d %s - %s\n", source, t.TorrentID, t.ArtistName, t.Name)
+	}
+	log.Printf("[%s] %d of %d seeding torrents not found locally (lists imported %s)",
+		source, missing, len(seeding), updated.Local().Format("2006-01-02 15:04"))
+}
@@ -0,0 +1,139 @@
+// Author: EmotionalDots @ PTH
+//
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+
+	"github.com/emotionaldots/arbitrage/cmd"
+	"github.com/emotionaldots/arbitrage/pkg/arbitrage"
+	"github.com/emotionaldots/arbitrage/pkg/client"
+	"github.com/emotionaldots/arbitrage/pkg/model"
+)
+
+type opportunity struct {
+	Path     string
+	Source   string
+	Id       int64
+	Release  string
+	Missing  []string // formats missing from the edition
+	Extras   []string // files we have that the torrent lacks, e.g. "cue"
+	Snatched int      // snatches of the whole edition, as a measure of demand
+}
+
+func (o opportunity) Score() int {
+	return 10*len(o.Missing) + 3*len(o.Extras)
+}
+
+// extensions returns the set of lower-case extensions of a file list.
+func extensions(files []arbitrage.File) map[string]bool {
+	exts := make(map[string]bool)
+	for _, f := range files {
+		exts[strings.TrimPrefix(strings.ToLower(filepath.Ext(f.Name)), ".")] = true
+	}
+	return exts
+}
+
+// Command "opportunities" lists uploads that can be made from local
+// releases: formats missing from the matched edition on the tracker, and
+// cue sheets that the tracker torrent lacks.
+func (app *App) Opportunities() {
+	fs, opts := discoveryFlags("opportunities")
+	fs.Parse(flag.Args()[1:])
+	args := fs.Args()
+	if len(args) < 2 {
+		log.Fatal("Usage: arbitrage opportunities [source] [dirs...]")
+	}
+	source := args[0]
+	paths := app.findReleases(args[1:], *opts)
+
+	c := client.New(app.Config.Server, cmd.UserAgent)
+	groups := make(map[int64]*model.GroupAndTorrents)
+	seen := make(map[string]bool)
+	found := make([]opportunity, 0)
+
+	hashed := app.hashReleases(paths, runtime.NumCPU(), nil)
+	for jobs := range app.batchQueryReleases(hashed, source) {
+		for _, j := range jobs {
+			for _, match := range j.Releases {
+				o, ok := app.findOpportunity(c, source, j, match, groups)
+				if !ok {
+					continue
+				}
+				key := fmt.Sprintf("%d|%v|%v", o.Id, o.Missing, o.Extras)
+				if !seen[key] {
+					seen[key] = true
+					found = append(found, o)
+				}
+			}
+		}
+	}
+
+	sort.SliceStable(found, func(i, j int) bool {
+		if found[i].Score() != found[j].Score() {
+			return found[i].Score() > found[j].Score()
+		}
+		return found[i].Snatched > found[j].Snatched
+	})
+	for _, o := range found {
+		fmt.Printf("%3d %s:%d %q\n", o.Score(), o.Source, o.Id, o.Path)
+		fmt.Printf("    %s\n", o.Release)
+		if len(o.Missing) > 0 {
+			fmt.Printf("    missing: %s\n", strings.Join(o.Missing, ", "))
+		}
+		if len(o.Extras) > 0 {
+			fmt.Printf("    we have: %s, the torrent has not\n", strings.Join(o.Extras, ", "))
+		}
+	}
+	log.Printf("%d opportunities in %d releases", len(found), len(paths))
+}
+
+func (app *App) findOpportunity(c *client.Client, source string, j job, match client.Release, groups map[int64]*model.GroupAndTorrents) (opportunity, bool) {
+	o := opportunity{Path: j.Path, Source: source, Id: match.Id}
+
+	tg, err := c.Torrent(source, match.Id)
+	if err != nil {
+		log.Printf("[%s:%d] Not in index: %s", source, match.Id, err)
+		return o, false
+	}
+	gid := int64(tg.Group.ID)
+	gt, ok := groups[gid]
+	if !ok {
+		if gt, err = c.TorrentGroup(source, gid); err != nil {
+			log.Printf("[%s:%d] Group %d not in index: %s", source, match.Id, gid, err)
+			return o, false
+		}
+		groups[gid] = gt
+	}
+
+	o.Release = cmd.ReleaseInfo(tg.Group, tg.Torrent).String()
+	o.Missing = gt.MissingFormats(tg.Torrent)
+	for _, t := range gt.Edition(tg.Torrent) {
+		o.Snatched += int(t.Snatched)
+	}
+
+	local, err := arbitrage.FromFile(j.Path)
+	if err != nil {
+		log.Printf("[%s] %s", j.Path, err)
+		return o, false
+	}
+	have := extensions(local.FileList)
+	remote := extensions(arbitrage.ParseFileList(string(tg.Torrent.FileList)))
+	// logs are part of the release hash, so matches always have the same
+	// logs as we do
+	for _, ext := range []string{"cue"} {
+		if have[ext] && !remote[ext] {
+			o.Extras = append(o.Extras, ext)
+		}
+	}
+	return o, len(o.Missing) > 0 || len(o.Extras) > 0
+}
@@ -0,0 +1,129 @@
+// Author: EmotionalDots @ PTH
+//
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/emotionaldots/arbitrage/cmd"
+	"github.com/emotionaldots/arbitrage/pkg/arbitrage"
+	"github.com/emotionaldots/arbitrage/pkg/client"
+	"github.com/emotionaldots/arbitrage/pkg/report"
+)
+
+// TorrentPage returns the URL of a torrent's page on the tracker.
+func (app *App) TorrentPage(source string, id int64) string {
+	s, ok := app.Config.Sources[source]
+	if !ok {
+		return ""
+	}
+	base := strings.TrimRight(s.Url, "/")
+	if s.Scraper != nil && s.Scraper.DetailsURL != "" && !strings.Contains(strings.Replace(s.Scraper.DetailsURL, "{id}", "", -1), "{") {
+		return base + "/" + strings.Replace(s.Scraper.DetailsURL, "{id}", strconv.FormatInt(id, 10), -1)
+	}
+	return fmt.Sprintf("%s/torrents.php?torrentid=%d", base, id)
+}
+
+// Command "report" renders one or more state files of "downthemall" or
+// "lookup -state" as HTML or Markdown.
+func (app *App) Report() {
+	fs := flag.NewFlagSet("report", flag.ExitOnError)
+	format := fs.String("format", "html", "output format: html or md")
+	out := fs.String("o", "", "output `file`, defaults to stdout")
+	title := fs.String("title", "", "report title")
+	metadata := fs.Bool("metadata", false, "fetch torrent metadata from the server for uncached matches")
+	fs.Parse(flag.Args()[1:])
+
+	if fs.NArg() == 0 {
+		log.Fatal("Usage: arbitrage report [-format html|md] [-o file] [state files...]")
+	}
+	states := make([]*arbitrage.State, 0, fs.NArg())
+	for _, path := range fs.Args() {
+		st, err := arbitrage.ReadState(path)
+		must(err)
+		states = append(states, st)
+	}
+
+	cache := app.loadMetadataCache()
+	c := client.New(app.Config.Server, cmd.UserAgent)
+	fetched := 0
+	describe := func(source string, id int64) string {
+		key := fmt.Sprintf("%s:%d", source, id)
+		if desc, ok := cache[key]; ok || !*metadata {
+			return desc
+		}
+		tg, err := c.Torrent(source, id)
+		if err != nil {
+			log.Printf("[%s] No metadata: %s", key, err)
+			return ""
+		}
+		desc := cmd.ReleaseInfo(tg.Group, tg.Torrent).String()
+		cache[key] = desc
+		fetched++
+		return desc
+	}
+
+	r := report.New(states, report.Options{
+		Title:    *title,
+		Link:     app.TorrentPage,
+		Describe: describe,
+	})
+	if fetched > 0 {
+		app.saveMetadataCache(cache)
+	}
+
+	var w io.Writer = os.Stdout
+	if *out != "" {
+		f, err := os.Create(*out)
+		must(err)
+		defer f.Close()
+		w = f
+	}
+	switch *format {
+	case "html":
+		must(r.WriteHTML(w))
+	case "md", "markdown":
+		must(r.WriteMarkdown(w))
+	default:
+		log.Fatalf("Unknown format %q, expected html or md", *format)
+	}
+}
+
+func (app *App) metadataCachePath() string {
+	return filepath.Join(app.ConfigDir, "metadata-cache.json")
+}
+
+// loadMetadataCache reads the cached torrent descriptions, by "source:id".
+func (app *App) loadMetadataCache() map[string]string {
+	cache := make(map[string]string)
+	raw, err := ioutil.ReadFile(app.metadataCachePath())
+	if err == nil {
+		err = json.Unmarshal(raw, &cache)
+	}
+	if err != nil && !os.IsNotExist(err) {
+		log.Printf("Ignoring metadata cache: %s", err)
+	}
+	return cache
+}
+
+func (app *App) saveMetadataCache(cache map[string]string) {
+	raw, err := json.Marshal(cache)
+	if err == nil {
+		err = ioutil.WriteFile(app.metadataCachePath(), raw, 0600)
+	}
+	if err != nil {
+		log.Printf("Could not save metadata cache: %s", err)
+	}
+}
@@ -0,0 +1,218 @@
+// Author: EmotionalDots @ PTH
+//
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/emotionaldots/arbitrage/cmd"
+	"github.com/emotionaldots/arbitrage/pkg/arbitrage"
+	"github.com/emotionaldots/arbitrage/pkg/arbitrage/plan"
+	"github.com/emotionaldots/arbitrage/pkg/arbitrage/watch"
+	"github.com/emotionaldots/arbitrage/pkg/client"
+)
+
+// crossSeeder looks up new releases on all sources and downloads matching
+// torrents, logging in to each source on first use.
+type crossSeeder struct {
+	app     *App
+	server  *client.Client
+	sources []string
+	apis    map[string]cmd.API
+	last    map[string]time.Time
+	done    map[string]bool // "source:hash" that were downloaded or have no match
+	retries map[string]int  // releases to check again, with the number of attempts
+	out     string
+	plan    *plan.Plan
+	planOp  string
+	planOut string
+}
+
+// Command "watch" waits for new downloads in the given directories and
+// downloads matching torrents from all sources for cross-seeding.
+func (app *App) Watch() {
+	fs, opts := discoveryFlags("watch")
+	settle := fs.Duration("settle", 2*time.Minute, "time without changes before a new directory is hashed")
+	sourceList := fs.String("sources", "", "comma-separated `sources` to query, defaults to all that support downloads")
+	out := fs.String("out", ".", "`directory` to save torrents to, e.g. the watch directory of a torrent client")
+	planPath := fs.String("plan", "arbitrage-plan.json", "plan `file` for renamed matches")
+	op := fs.String("action", plan.OpSymlink, "planned action for renamed matches: rename, hardlink, symlink or reflink")
+	fs.Parse(flag.Args()[1:])
+
+	dirs := fs.Args()
+	if len(dirs) == 0 {
+		log.Fatal("Usage: arbitrage watch [dirs...]")
+	}
+	if !validOp(*op) {
+		log.Fatalf("Unknown action %q, expected one of %v", *op, plan.Ops)
+	}
+
+	cs := &crossSeeder{
+		app:     app,
+		server:  client.New(app.Config.Server, cmd.UserAgent),
+		apis:    make(map[string]cmd.API),
+		last:    make(map[string]time.Time),
+		done:    make(map[string]bool),
+		retries: make(map[string]int),
+		out:     *out,
+		planOp:  *op,
+		planOut: *planPath,
+	}
+	if *sourceList != "" {
+		cs.sources = strings.Split(*sourceList, ",")
+	} else {
+		for name := range app.Config.Sources {
+			if app.APIForSource(name).Capabilities().Download {
+				cs.sources = append(cs.sources, name)
+			}
+		}
+		sort.Strings(cs.sources)
+	}
+	if len(cs.sources) == 0 {
+		log.Fatal("No sources with download support configured")
+	}
+	var err error
+	cs.plan, err = plan.Load(*planPath)
+	must(err)
+
+	w, err := watch.New(dirs, *settle)
+	must(err)
+	defer w.Close()
+	log.Printf("Watching %v for new releases, querying %v", dirs, cs.sources)
+
+	retry := time.NewTicker(retryInterval)
+	defer retry.Stop()
+	for {
+		select {
+		case <-retry.C:
+			cs.retry()
+		case path := <-w.Paths:
+			releases, err := arbitrage.FindReleases(path, *opts)
+			if err != nil {
+				log.Printf("[%s] %s", path, err)
+				continue
+			}
+			for _, r := range releases {
+				cs.handle(r)
+			}
+		case err := <-w.Errors:
+			log.Printf("watch: %s", err)
+		}
+	}
+}
+
+const (
+	// interval and number of times releases are checked again after
+	// failed queries, logins or downloads
+	retryInterval = 10 * time.Minute
+	retryAttempts = 6
+)
+
+// retry checks releases again that could not be checked on all sources.
+func (cs *crossSeeder) retry() {
+	for path, attempts := range cs.retries {
+		if attempts >= retryAttempts {
+			log.Printf("[%s] Giving up after %d attempts", path, attempts)
+			delete(cs.retries, path)
+			continue
+		}
+		cs.retries[path]++
+		cs.handle(path)
+	}
+}
+
+// handle looks up a release on all sources and downloads the matches. A
+// source is not checked again once a match was downloaded or it has none,
+// releases that failed on any source are retried later.
+func (cs *crossSeeder) handle(path string) {
+	r, err := arbitrage.FromFile(path)
+	if err != nil {
+		log.Printf("[%s] Could not hash release: %s", path, err)
+		delete(cs.retries, path)
+		return
+	}
+	arbitrage.HashDefault(r)
+	if r.Hash == "" {
+		delete(cs.retries, path)
+		return
+	}
+	j := job{path, r.FilePath, r.Hash, totalSize(r.FileList), nil}
+
+	failed := false
+	for _, source := range cs.sources {
+		if cs.done[source+":"+r.Hash] {
+			continue
+		}
+		if ok := cs.crossSeed(source, j); ok {
+			cs.done[source+":"+r.Hash] = true
+		} else {
+			failed = true
+		}
+	}
+	if !failed {
+		delete(cs.retries, path)
+	} else if _, ok := cs.retries[path]; !ok {
+		cs.retries[path] = 0
+	}
+}
+
+// crossSeed downloads the best match of a release from a source and returns
+// whether the source is done, i.e. a match was downloaded or there is none.
+func (cs *crossSeeder) crossSeed(source string, j job) bool {
+	path := j.Path
+	releases, err := cs.server.Query(source, []string{j.Hash})
+	if err != nil {
+		log.Printf("[%s] Query failed: %s", source, err)
+		return false
+	}
+	if len(releases) == 0 {
+		return true
+	}
+	log.Printf("[%s] New release %s on %s", path, j.Hash, source)
+
+	c, ok := cs.apis[source]
+	if !ok {
+		if c, err = cs.app.TryLogin(source); err != nil {
+			log.Printf("[%s] Could not log in, skipping: %s", source, err)
+			return false
+		}
+		cs.apis[source] = c
+	}
+
+	for _, other := range client.Rank(releases, cs.app.Config.Sources[source].MatchPolicy()) {
+		time.Sleep(cs.last[source].Add(cs.app.Config.Sources[source].Interval()).Sub(time.Now()))
+		cs.last[source] = time.Now()
+
+		name, err := cs.app.downloadMatch(c, source, j, other, cs.out, false)
+		if err != nil {
+			log.Printf("[%s:%d] %s, skipping", source, other.Id, err)
+			continue
+		}
+		log.Printf("[%s:%d] Downloaded %q", source, other.Id, name)
+		if name != j.Name {
+			a := plan.Action{
+				Op:      cs.planOp,
+				Source:  path,
+				Target:  filepath.Join(filepath.Dir(path), name),
+				Torrent: fmt.Sprintf("%s:%d", source, other.Id),
+			}
+			if cs.plan.Add(a) {
+				if err := cs.plan.Save(cs.planOut); err != nil {
+					log.Printf("Could not save plan: %s", err)
+				}
+				log.Printf("[%s:%d] Planned %s, run \"arbitrage apply\"", source, other.Id, a)
+			}
+		}
+		return true
+	}
+	return false
+}
@@ -0,0 +1,272 @@
+// Author: EmotionalDots @ PTH
+//
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/emotionaldots/arbitrage/pkg/client"
+	"github.com/emotionaldots/arbitrage/pkg/secrets"
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+// SecretsFile returns the path of the encrypted secrets file.
+func (app *App) SecretsFile() string {
+	if app.Config.SecretsFile != "" {
+		return app.Config.SecretsFile
+	}
+	return app.ConfigDir + "/secrets.json"
+}
+
+// Credentials resolves the username and password for a source.
+//
+// The password is taken from the first of:
+//   - password_command: first line of the command output, e.g.
+//     "pass show trackers/red" or "secret-tool lookup arbitrage red" to
+//     use the OS keyring
+//   - password: either plaintext or "env:NAME" to read an environment variable
+//   - the encrypted secrets file, unlocked by ARBITRAGE_PASSPHRASE or a prompt
+//
+// The user may also reference an environment variable with "env:NAME".
+func (app *App) Credentials(source string) (user, password string, err error) {
+	s, ok := app.Config.Sources[source]
+	if !ok {
+		return "", "", errors.New("Source '" + source + "' not found in config!")
+	}
+
+	if user, err = resolveValue(s.User); err != nil {
+		return "", "", err
+	}
+
+	switch {
+	case s.PasswordCommand != "":
+		password, err = runPasswordCommand(s.PasswordCommand)
+	case s.Password != "":
+		password, err = resolveValue(s.Password)
+	default:
+		var secrets map[string]string
+		if secrets, err = app.Secrets(); err == nil {
+			password = secrets[source]
+		}
+	}
+	if err != nil {
+		return "", "", fmt.Errorf("[%s] password: %s", source, err)
+	}
+	if password == "" {
+		return "", "", fmt.Errorf("[%s] no password configured", source)
+	}
+	return user, password, nil
+}
+
+// Secrets unlocks the encrypted secrets file, prompting for the passphrase
+// if ARBITRAGE_PASSPHRASE is not set.
+func (app *App) Secrets() (map[string]string, error) {
+	if app.secrets != nil {
+		return app.secrets, nil
+	}
+	path := app.SecretsFile()
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return map[string]string{}, nil
+	}
+
+	pass, err := app.passphrase()
+	if err != nil {
+		return nil, err
+	}
+	app.secrets, err = secrets.Load(path, pass)
+	return app.secrets, err
+}
+
+// SetSecret stores a password for a source in the encrypted secrets file.
+func (app *App) SetSecret(source, password string) error {
+	s, err := app.Secrets()
+	if err != nil {
+		return err
+	}
+	getPass := app.passphrase
+	if _, err := os.Stat(app.SecretsFile()); os.IsNotExist(err) {
+		getPass = app.newPassphrase
+	}
+	pass, err := getPass()
+	if err != nil {
+		return err
+	}
+	s[source] = password
+	app.secrets = s
+	return secrets.Save(app.SecretsFile(), pass, s)
+}
+
+func (app *App) passphrase() (string, error) {
+	if app.passphraseCache != "" {
+		return app.passphraseCache, nil
+	}
+	pass := os.Getenv("ARBITRAGE_PASSPHRASE")
+	if pass == "" {
+		var err error
+		if pass, err = Prompt("Passphrase for " + app.SecretsFile() + ": "); err != nil {
+			return "", err
+		}
+	}
+	app.passphraseCache = pass
+	return pass, nil
+}
+
+// newPassphrase prompts twice for the passphrase of a new secrets file, as
+// a typo would lock it.
+func (app *App) newPassphrase() (string, error) {
+	if app.passphraseCache != "" || os.Getenv("ARBITRAGE_PASSPHRASE") != "" || !terminal.IsTerminal(int(os.Stdin.Fd())) {
+		return app.passphrase()
+	}
+	pass, err := Prompt("New passphrase for " + app.SecretsFile() + ": ")
+	if err != nil {
+		return "", err
+	}
+	confirm, err := Prompt("Repeat passphrase: ")
+	if err != nil {
+		return "", err
+	}
+	if pass != confirm {
+		return "", errors.New("passphrases do not match")
+	}
+	app.passphraseCache = pass
+	return pass, nil
+}
+
+// Prompt reads a secret from the terminal without echoing it, or a line from
+// stdin if it is not a terminal.
+func Prompt(prompt string) (string, error) {
+	fmt.Fprint(os.Stderr, prompt)
+	defer fmt.Fprintln(os.Stderr)
+
+	fd := int(os.Stdin.Fd())
+	if terminal.IsTerminal(fd) {
+		raw, err := terminal.ReadPassword(fd)
+		return string(raw), err
+	}
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && line == "" {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+func resolveValue(v string) (string, error) {
+	if !strings.HasPrefix(v, "env:") {
+		return v, nil
+	}
+	name := strings.TrimPrefix(v, "env:")
+	value, ok := os.LookupEnv(name)
+	if !ok {
+		return "", errors.New("environment variable " + name + " is not set")
+	}
+	return value, nil
+}
+
+func runPasswordCommand(command string) (string, error) {
+	var stderr bytes.Buffer
+	c := exec.Command("sh", "-c", command)
+	c.Stderr = &stderr
+	c.Stdin = os.Stdin
+	out, err := c.Output()
+	if err != nil {
+		return "", fmt.Errorf("password_command failed: %s %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return strings.SplitN(strings.TrimRight(string(out), "\r\n"), "\n", 2)[0], nil
+}
+
+// CheckConfig validates every configured source, warns about readable
+// credential files and optionally tests each login. It returns false if any
+// error was found.
+func (app *App) CheckConfig(testLogin bool) bool {
+	ok := true
+	report := func(source, level, msg string) {
+		if level == "error" {
+			ok = false
+		}
+		fmt.Printf("%-6s %-7s %s\n", source, level, msg)
+	}
+
+	for _, path := range []string{app.ConfigDir + "/config.toml", app.SecretsFile()} {
+		fi, err := os.Stat(path)
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			report("-", "error", err.Error())
+			continue
+		}
+		if perm := fi.Mode().Perm(); perm&0004 != 0 {
+			report("-", "warning", fmt.Sprintf("%s is world-readable (%04o), run: chmod 600 %q", path, perm, path))
+		} else if perm&0040 != 0 {
+			report("-", "warning", fmt.Sprintf("%s is group-readable (%04o)", path, perm))
+		}
+	}
+
+	names := make([]string, 0, len(app.Config.Sources))
+	for name := range app.Config.Sources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		s := app.Config.Sources[name]
+		if _, known := backends[s.Type]; !known {
+			report(name, "error", fmt.Sprintf("unknown type %q, expected one of %v", s.Type, BackendTypes()))
+			continue
+		}
+		if u, err := url.Parse(s.Url); err != nil || !u.IsAbs() {
+			report(name, "error", fmt.Sprintf("invalid url %q", s.Url))
+			continue
+		}
+		if s.RateLimit != "" {
+			if _, err := time.ParseDuration(s.RateLimit); err != nil {
+				report(name, "error", fmt.Sprintf("invalid rate_limit %q", s.RateLimit))
+			}
+		}
+		if s.Policy != "" {
+			if err := client.ValidPolicy(s.Policy); err != nil {
+				report(name, "error", err.Error())
+			}
+		}
+		if s.Announce != nil {
+			if err := s.Announce.Validate(); err != nil {
+				report(name, "error", err.Error())
+			}
+		}
+		if s.PasswordCommand == "" && s.Password != "" && !strings.HasPrefix(s.Password, "env:") {
+			report(name, "warning", "plaintext password in config.toml, consider password_command, env: or the secrets file")
+		}
+
+		user, password, err := app.Credentials(name)
+		if err != nil {
+			report(name, "error", err.Error())
+			continue
+		}
+		if user == "" {
+			report(name, "error", "no user configured")
+			continue
+		}
+		if !testLogin {
+			report(name, "ok", "credentials found for "+user)
+			continue
+		}
+
+		if err := app.APIForSource(name).Login(user, password); err != nil {
+			report(name, "error", "login failed: "+err.Error())
+			continue
+		}
+		report(name, "ok", "logged in as "+user)
+	}
+	return ok
+}
@@ -0,0 +1,176 @@
+// Author: EmotionalDots @ PTH
+//
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/emotionaldots/arbitrage/pkg/model"
+)
+
+var reSize = regexp.MustCompile(`^\s*([0-9.]+)\s*([KMGTP]?)(I?B)?\s*$`)
+
+// ParseSize parses sizes like "500MiB", "10 GB" or "1T". All units are
+// binary. An empty string is zero.
+func ParseSize(s string) (int64, error) {
+	if s == "" {
+		return 0, nil
+	}
+	m := reSize.FindStringSubmatch(strings.ToUpper(s))
+	if m == nil {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	f, err := strconv.ParseFloat(m[1], 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	exp := strings.Index("KMGTP", m[2]) + 1
+	if m[2] == "" {
+		exp = 0
+	}
+	for i := 0; i < exp; i++ {
+		f *= 1024
+	}
+	return int64(f), nil
+}
+
+// Guard enforces the download limits of a source during bulk downloads.
+// Matches whose files are all present locally (cross-seeds) are free;
+// partial matches cost the size of the missing data, unless the torrent is
+// freeleech or a token is spent on it.
+type Guard struct {
+	Source    string
+	Budget    int64 // remaining estimated download, -1 if unlimited
+	UseTokens bool
+	Threshold int64
+
+	mu         sync.Mutex
+	Spent      int64
+	Tokens     int
+	noneLeft   bool
+	ratioKnown bool
+}
+
+// NewGuard creates a guard from the limits of a source and, if known, the
+// account statistics.
+func NewGuard(source string, s Source, account *model.Account) (*Guard, error) {
+	g := &Guard{Source: source, Budget: -1, UseTokens: s.UseTokens}
+	max, err := ParseSize(s.MaxDownload)
+	if err != nil {
+		return nil, err
+	}
+	minBuffer, err := ParseSize(s.MinBuffer)
+	if err != nil {
+		return nil, err
+	}
+	if g.Threshold, err = ParseSize(s.TokenThreshold); err != nil {
+		return nil, err
+	}
+
+	if max > 0 {
+		g.Budget = max
+	}
+	if account != nil {
+		g.ratioKnown = true
+		st := account.UserStats
+		buffer := int64(st.Uploaded) - int64(st.Downloaded)
+		if st.RequiredRatio > 0 {
+			buffer = int64(float64(st.Uploaded)/float64(st.RequiredRatio)) - int64(st.Downloaded)
+		}
+		if avail := buffer - minBuffer; g.Budget < 0 || avail < g.Budget {
+			if avail < 0 {
+				avail = 0
+			}
+			g.Budget = avail
+		}
+	}
+	return g, nil
+}
+
+// Cost estimates how much would be downloaded to complete a local release
+// of localSize bytes with a torrent of size bytes.
+func Cost(size, localSize int64) int64 {
+	if size <= localSize {
+		return 0
+	}
+	return size - localSize
+}
+
+// Reserve decides whether a torrent of the given size may be downloaded.
+// It returns whether a freeleech token should be used, or an error if the
+// download would exceed the limits.
+func (g *Guard) Reserve(size, localSize int64, free bool) (useToken bool, err error) {
+	cost := Cost(size, localSize)
+	if cost == 0 || free {
+		return false, nil
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.UseTokens && !g.noneLeft && size >= g.Threshold {
+		g.Tokens++
+		return true, nil
+	}
+	if g.Budget >= 0 && g.Spent+cost > g.Budget {
+		return false, fmt.Errorf("would download %s, exceeding the remaining budget of %s",
+			FormatBytes(cost), FormatBytes(g.Budget-g.Spent))
+	}
+	g.Spent += cost
+	return false, nil
+}
+
+// Cancel returns a reservation after a failed download.
+func (g *Guard) Cancel(size, localSize int64, usedToken bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if usedToken {
+		g.Tokens--
+	} else {
+		g.Spent -= Cost(size, localSize)
+	}
+}
+
+// NoTokensLeft stops the use of tokens for the rest of the run.
+func (g *Guard) NoTokensLeft() {
+	g.mu.Lock()
+	g.noneLeft = true
+	g.mu.Unlock()
+}
+
+func (g *Guard) String() string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	budget := "unlimited"
+	if g.Budget >= 0 {
+		budget = FormatBytes(g.Budget)
+		if !g.ratioKnown {
+			budget += " (ratio unknown)"
+		}
+	}
+	return fmt.Sprintf("[%s] estimated download %s of %s, %d freeleech tokens", g.Source, FormatBytes(g.Spent), budget, g.Tokens)
+}
+
+// FormatBytes formats a size in binary units, e.g. "1.50 GiB".
+func FormatBytes(b int64) string {
+	sign := ""
+	if b < 0 {
+		sign, b = "-", -b
+	}
+	const unit = 1024
+	if b < unit {
+		return fmt.Sprintf("%s%d B", sign, b)
+	}
+	div, exp := int64(unit), 0
+	for n := b / unit; n >= unit && exp < 5; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%s%.2f %ciB", sign, float64(b)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,144 @@
+// Author: EmotionalDots @ PTH
+//
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"testing"
+
+	"github.com/emotionaldots/arbitrage/pkg/model"
+)
+
+const (
+	mib = 1 << 20
+	gib = 1 << 30
+)
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		s        string
+		expected int64
+	}{
+		{"", 0},
+		{"0", 0},
+		{"512", 512},
+		{"1K", 1024},
+		{"500MiB", 500 * mib},
+		{"500mb", 500 * mib},
+		{"10 GB", 10 * gib},
+		{" 1.5G ", gib + gib/2},
+		{"1T", 1024 * gib},
+	}
+	for _, test := range tests {
+		n, err := ParseSize(test.s)
+		if err != nil || n != test.expected {
+			t.Errorf("ParseSize(%q): expected %d, got %d (%v)", test.s, test.expected, n, err)
+		}
+	}
+	for _, s := range []string{"ten", "-1G", "1X", "1.2.3M", "G"} {
+		if _, err := ParseSize(s); err == nil {
+			t.Errorf("ParseSize(%q): expected error", s)
+		}
+	}
+}
+
+func testAccount(uploaded, downloaded int64, required float64) *model.Account {
+	a := &model.Account{}
+	a.UserStats.Uploaded = model.FlexInt(uploaded)
+	a.UserStats.Downloaded = model.FlexInt(downloaded)
+	a.UserStats.RequiredRatio = model.FlexFloat(required)
+	return a
+}
+
+func TestNewGuard(t *testing.T) {
+	tests := []struct {
+		name     string
+		source   Source
+		account  *model.Account
+		expected int64
+	}{
+		{"unlimited", Source{}, nil, -1},
+		{"max download", Source{MaxDownload: "10G"}, nil, 10 * gib},
+		{"min buffer without account", Source{MinBuffer: "5G"}, nil, -1},
+		{"plain buffer", Source{}, testAccount(100*gib, 50*gib, 0), 50 * gib},
+		{"required ratio", Source{}, testAccount(100*gib, 50*gib, 0.5), 150 * gib},
+		{"required ratio and min buffer", Source{MinBuffer: "20G"}, testAccount(60*gib, 40*gib, 1), 0},
+		{"min buffer", Source{MinBuffer: "5G"}, testAccount(60*gib, 40*gib, 1), 15 * gib},
+		{"max below buffer", Source{MaxDownload: "10G"}, testAccount(100*gib, 50*gib, 0.5), 10 * gib},
+		{"buffer below max", Source{MaxDownload: "100G"}, testAccount(100*gib, 50*gib, 0), 50 * gib},
+		{"ratio below requirement", Source{}, testAccount(10*gib, 50*gib, 0.6), 0},
+	}
+	for _, test := range tests {
+		g, err := NewGuard("src", test.source, test.account)
+		if err != nil {
+			t.Errorf("%s: %s", test.name, err)
+			continue
+		}
+		if g.Budget != test.expected {
+			t.Errorf("%s: expected budget %s, got %s", test.name, FormatBytes(test.expected), FormatBytes(g.Budget))
+		}
+	}
+
+	for _, s := range []Source{{MaxDownload: "x"}, {MinBuffer: "x"}, {TokenThreshold: "x"}} {
+		if _, err := NewGuard("src", s, nil); err == nil {
+			t.Errorf("NewGuard(%+v): expected error", s)
+		}
+	}
+}
+
+func TestGuardReserve(t *testing.T) {
+	g, err := NewGuard("src", Source{MaxDownload: "1G", UseTokens: true, TokenThreshold: "500M"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	steps := []struct {
+		name      string
+		size      int64
+		localSize int64
+		free      bool
+		token     bool
+		fail      bool
+		spent     int64
+		tokens    int
+	}{
+		{"cross-seed", 2 * gib, 2 * gib, false, false, false, 0, 0},
+		{"freeleech", 2 * gib, 0, true, false, false, 0, 0},
+		{"above threshold", 600 * mib, 0, false, true, false, 0, 1},
+		{"below threshold", 300 * mib, 100 * mib, false, false, false, 200 * mib, 1},
+		{"within budget", 400 * mib, 0, false, false, false, 600 * mib, 1},
+		{"exceeds budget", 499 * mib, 0, false, false, true, 600 * mib, 1},
+	}
+	for _, s := range steps {
+		token, err := g.Reserve(s.size, s.localSize, s.free)
+		if (err != nil) != s.fail || token != s.token {
+			t.Errorf("%s: expected token=%v fail=%v, got token=%v err=%v", s.name, s.token, s.fail, token, err)
+		}
+		if g.Spent != s.spent || g.Tokens != s.tokens {
+			t.Errorf("%s: expected %d spent and %d tokens, got %d and %d", s.name, s.spent, s.tokens, g.Spent, g.Tokens)
+		}
+	}
+
+	// A failed token download is returned and retried without token.
+	g.Cancel(600*mib, 0, true)
+	if g.Tokens != 0 {
+		t.Errorf("expected 0 tokens after cancel, got %d", g.Tokens)
+	}
+	g.NoTokensLeft()
+	if token, err := g.Reserve(700*mib, 0, false); token || err == nil {
+		t.Errorf("expected budget error without tokens, got token=%v err=%v", token, err)
+	}
+	if token, err := g.Reserve(300*mib, 0, false); token || err != nil {
+		t.Errorf("expected download without token, got token=%v err=%v", token, err)
+	}
+	if g.Spent != 900*mib {
+		t.Errorf("expected %d spent, got %d", 900*mib, g.Spent)
+	}
+
+	g.Cancel(300*mib, 100*mib, false)
+	if g.Spent != 700*mib {
+		t.Errorf("expected %d spent after cancel, got %d", 700*mib, g.Spent)
+	}
+}
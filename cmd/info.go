@@ -0,0 +1,120 @@
+// Author: EmotionalDots @ PTH
+//
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"strconv"
+
+	"github.com/emotionaldots/arbitrage/pkg/arbitrage"
+	"github.com/emotionaldots/arbitrage/pkg/model"
+)
+
+// ReleaseInfo describes a single torrent of a group for release.info.yaml,
+// with typed metadata depending on the category of the group.
+func ReleaseInfo(g model.Group, t model.Torrent) arbitrage.InfoRelease {
+	g.Category = model.DetectCategory(g)
+
+	r := arbitrage.InfoRelease{}
+	r.Name = string(g.Name)
+	r.TorrentId = int(t.ID)
+	r.Category = string(g.Category)
+	r.FilePath = string(t.FilePath)
+	r.Tags = g.Tags
+	r.Description = string(g.WikiBody)
+	r.Image = string(g.WikiImage)
+
+	r.Format = string(t.Media + " / " + t.Format)
+	if t.HasLog {
+		r.Format += " / " + strconv.Itoa(int(t.LogScore))
+	}
+
+	if t.Remastered {
+		r.Year = int(t.RemasterYear)
+		r.RecordLabel = string(t.RemasterRecordLabel)
+		r.CatalogueNumber = string(t.RemasterCatalogueNumber)
+		r.Edition = string(t.RemasterTitle)
+	} else {
+		r.Year = int(g.Year)
+		r.RecordLabel = string(g.RecordLabel)
+		r.CatalogueNumber = string(g.CatalogueNumber)
+		r.Edition = "Original Release"
+	}
+
+	switch g.Category {
+	case model.CategoryEBook, model.CategoryAudiobook:
+		b := g.BookInfo
+		if b == nil {
+			parsed := model.ParseBookInfo(g)
+			b = &parsed
+		}
+		r.Book = &arbitrage.InfoBook{
+			Authors:   b.Authors,
+			ISBN:      string(b.ISBN),
+			Publisher: string(b.Publisher),
+			Language:  string(b.Language),
+			Pages:     int(b.Pages),
+		}
+		return r
+
+	case model.CategoryVideo:
+		// Resolution and codec differ per torrent, so they are parsed from the
+		// torrent itself and completed by the group metadata
+		v := model.ParseVideoInfo(string(t.FilePath))
+		if g.VideoInfo != nil {
+			v = mergeVideoInfo(v, *g.VideoInfo)
+		}
+		r.Format = string(v.Source + " / " + v.Codec + " / " + v.Resolution)
+		r.Video = &arbitrage.InfoVideo{
+			Resolution: string(v.Resolution),
+			Codec:      string(v.Codec),
+			Source:     string(v.Source),
+			Container:  string(v.Container),
+			Season:     int(v.Season),
+			Episode:    int(v.Episode),
+		}
+		return r
+	}
+
+	for _, a := range g.MusicInfo.Composers {
+		r.Composers = append(r.Composers, string(a.Name))
+	}
+	for _, a := range g.MusicInfo.Artists {
+		r.Artists = append(r.Artists, string(a.Name))
+	}
+	for _, a := range g.MusicInfo.With {
+		r.With = append(r.With, string(a.Name))
+	}
+	for _, a := range g.MusicInfo.DJ {
+		r.DJ = append(r.DJ, string(a.Name))
+	}
+	for _, a := range g.MusicInfo.RemixedBy {
+		r.RemixedBy = append(r.RemixedBy, string(a.Name))
+	}
+	for _, a := range g.MusicInfo.Producer {
+		r.Producer = append(r.Producer, string(a.Name))
+	}
+
+	return r
+}
+
+func mergeVideoInfo(v, fallback model.VideoInfo) model.VideoInfo {
+	if v.Resolution == "" {
+		v.Resolution = fallback.Resolution
+	}
+	if v.Codec == "" {
+		v.Codec = fallback.Codec
+	}
+	if v.Source == "" {
+		v.Source = fallback.Source
+	}
+	if v.Container == "" {
+		v.Container = fallback.Container
+	}
+	if v.Season == 0 {
+		v.Season, v.Episode = fallback.Season, fallback.Episode
+	}
+	return v
+}
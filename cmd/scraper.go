@@ -0,0 +1,106 @@
+// Author: EmotionalDots @ PTH
+//
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/emotionaldots/arbitrage/pkg/api/replay"
+	"github.com/emotionaldots/arbitrage/pkg/api/scraper"
+	"github.com/emotionaldots/arbitrage/pkg/arbitrage"
+)
+
+// ScraperAPI is a backend for HTML-only trackers, configured by the
+// "scraper" section of a source.
+type ScraperAPI struct {
+	*scraper.API
+	Source      string
+	canDownload bool
+}
+
+func init() {
+	RegisterBackend("scraper", func(source string, s Source, rt http.RoundTripper) (API, error) {
+		if s.Scraper == nil {
+			return nil, errors.New("Source '" + source + "' has no [scraper] section")
+		}
+		w, err := scraper.NewAPI(s.Url, UserAgent, *s.Scraper)
+		if err != nil {
+			return nil, err
+		}
+		if rt != nil {
+			w.SetTransport(rt)
+		}
+		return &ScraperAPI{w, source, s.Scraper.DownloadURL != ""}, nil
+	})
+}
+
+func (w *ScraperAPI) Capabilities() Capabilities {
+	return Capabilities{
+		Types:    []string{"torrent"},
+		Download: w.canDownload,
+	}
+}
+
+func (w *ScraperAPI) Do(typ string, id int) (resp *arbitrage.Response, err error) {
+	resp = &arbitrage.Response{
+		Source: w.Source,
+		Type:   typ,
+		TypeId: id,
+		Time:   time.Now(),
+	}
+
+	var raw []byte
+	switch typ {
+	case "torrent":
+		raw, err = w.DoTorrent(id)
+	default:
+		return nil, errors.New("Unknown type: " + typ)
+	}
+	if err != nil {
+		return resp, err
+	}
+
+	resp.Response = string(raw)
+	return resp, nil
+}
+
+func (w *ScraperAPI) ParseResponseReleases(resp arbitrage.Response) (interface{}, error) {
+	if resp.Type != "torrent" {
+		return nil, errors.New("API: unexpected response type: " + resp.Type)
+	}
+
+	t, err := w.ParseTorrent([]byte(resp.Response))
+	if err != nil {
+		return nil, err
+	}
+	return interface{}(t), nil
+}
+
+func (w *ScraperAPI) Download(id int) ([]byte, error) {
+	body, err := w.DownloadTorrent(id)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+	return ioutil.ReadAll(body)
+}
+
+func (w *ScraperAPI) ResponseFixture(resp arbitrage.Response) (replay.Fixture, error) {
+	u, err := w.TorrentURL(resp.TypeId)
+	if err != nil {
+		return replay.Fixture{}, err
+	}
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return replay.Fixture{}, err
+	}
+
+	header := http.Header{"Content-Type": {"text/html; charset=utf-8"}}
+	return replay.NewFixture(req, nil, http.StatusOK, header, []byte(resp.Response)), nil
+}
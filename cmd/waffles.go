@@ -8,10 +8,11 @@ package cmd
 import (
 	"errors"
 	"io/ioutil"
+	"net/http"
 	"net/url"
-	"strconv"
 	"time"
 
+	"github.com/emotionaldots/arbitrage/pkg/api/replay"
 	"github.com/emotionaldots/arbitrage/pkg/api/waffles"
 	"github.com/emotionaldots/arbitrage/pkg/arbitrage"
 )
@@ -21,6 +22,26 @@ type WafflesAPI struct {
 	Source string
 }
 
+func init() {
+	RegisterBackend("waffles", func(source string, s Source, rt http.RoundTripper) (API, error) {
+		w, err := waffles.NewAPI(s.Url, UserAgent)
+		if err != nil {
+			return nil, err
+		}
+		if rt != nil {
+			w.SetTransport(rt)
+		}
+		return &WafflesAPI{w, source}, nil
+	})
+}
+
+func (w *WafflesAPI) Capabilities() Capabilities {
+	return Capabilities{
+		Types:    []string{"torrent"},
+		Download: true,
+	}
+}
+
 func (w *WafflesAPI) Do(typ string, id int) (resp *arbitrage.Response, err error) {
 	resp = &arbitrage.Response{
 		Source: w.Source,
@@ -66,53 +87,22 @@ func (w *WafflesAPI) Download(id int) ([]byte, error) {
 	return ioutil.ReadAll(body)
 }
 
-func (w *WafflesAPI) ResponseToInfo(resp *arbitrage.Response) arbitrage.InfoRelease {
-	t, err := w.ParseTorrent([]byte(resp.Response))
-	must(err)
-
-	r := arbitrage.InfoRelease{}
-	r.Name = t.Group.Name
-	r.TorrentId = t.Torrent.ID
-	r.FilePath = t.Torrent.FilePath
-	r.Tags = t.Group.Tags
-	r.Description = t.Group.WikiBody
-	r.Image = t.Group.WikiImage
-
-	r.Format = t.Torrent.Media + " / " + t.Torrent.Format
-	if t.Torrent.HasLog {
-		r.Format += " / " + strconv.Itoa(t.Torrent.LogScore)
+func (w *WafflesAPI) ResponseFixture(resp arbitrage.Response) (replay.Fixture, error) {
+	u, err := w.TorrentURL(resp.TypeId, url.Values{})
+	if err != nil {
+		return replay.Fixture{}, err
 	}
-
-	if t.Torrent.Remastered {
-		r.Year = t.Torrent.RemasterYear
-		r.RecordLabel = t.Torrent.RemasterRecordLabel
-		r.CatalogueNumber = t.Torrent.RemasterCatalogueNumber
-		r.Edition = t.Torrent.RemasterTitle
-	} else {
-		r.Year = int(t.Group.Year)
-		r.RecordLabel = t.Group.RecordLabel
-		r.CatalogueNumber = t.Group.CatalogueNumber
-		r.Edition = "Original Release"
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return replay.Fixture{}, err
 	}
 
-	for _, a := range t.Group.MusicInfo.Composers {
-		r.Composers = append(r.Composers, a.Name)
-	}
-	for _, a := range t.Group.MusicInfo.Artists {
-		r.Artists = append(r.Artists, a.Name)
-	}
-	for _, a := range t.Group.MusicInfo.With {
-		r.With = append(r.With, a.Name)
-	}
-	for _, a := range t.Group.MusicInfo.DJ {
-		r.DJ = append(r.DJ, a.Name)
-	}
-	for _, a := range t.Group.MusicInfo.RemixedBy {
-		r.RemixedBy = append(r.RemixedBy, a.Name)
-	}
-	for _, a := range t.Group.MusicInfo.Producer {
-		r.Producer = append(r.Producer, a.Name)
-	}
+	header := http.Header{"Content-Type": {"text/html; charset=utf-8"}}
+	return replay.NewFixture(req, nil, http.StatusOK, header, []byte(resp.Response)), nil
+}
 
-	return r
+func (w *WafflesAPI) ResponseToInfo(resp *arbitrage.Response) arbitrage.InfoRelease {
+	t, err := w.ParseTorrent([]byte(resp.Response))
+	must(err)
+	return ReleaseInfo(t.Group, t.Torrent)
 }
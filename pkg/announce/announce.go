@@ -0,0 +1,259 @@
+// Author: EmotionalDots @ PTH
+//
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+// Package announce listens to a tracker's IRC announce channel and extracts
+// the torrent IDs of new uploads.
+package announce
+
+import (
+	"bufio"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultPattern matches the torrent ID in Gazelle announce lines, e.g.
+// "Artist - Album [2017] [Album] - FLAC / Lossless - https://tracker/torrents.php?id=1&torrentid=2"
+const DefaultPattern = `torrentid=(?P<id>\d+)`
+
+// Config describes the IRC server and channel a tracker announces new
+// uploads in.
+type Config struct {
+	Server   string `toml:"server"` // host:port
+	TLS      bool   `toml:"tls,omitempty"`
+	Nick     string `toml:"nick"`
+	Password string `toml:"password,omitempty"` // server password
+	Channel  string `toml:"channel"`
+	Key      string `toml:"key,omitempty"` // channel key
+
+	// Announcer restricts matches to messages from this nick
+	Announcer string `toml:"announcer,omitempty"`
+
+	// Pattern matches announce lines, the torrent ID is taken from the
+	// group named "id" or else the first group. Defaults to DefaultPattern.
+	Pattern string `toml:"pattern,omitempty"`
+
+	// Commands are sent after connecting, before joining the channel,
+	// e.g. "PRIVMSG NickServ :IDENTIFY password"
+	Commands []string `toml:"commands,omitempty"`
+}
+
+// Regexp compiles the announce pattern.
+func (c Config) Regexp() (*regexp.Regexp, error) {
+	p := c.Pattern
+	if p == "" {
+		p = DefaultPattern
+	}
+	re, err := regexp.Compile(p)
+	if err != nil {
+		return nil, err
+	}
+	if re.NumSubexp() < 1 {
+		return nil, fmt.Errorf("announce pattern %q has no group for the torrent id", p)
+	}
+	return re, nil
+}
+
+// Validate checks that the config is complete.
+func (c Config) Validate() error {
+	if c.Server == "" || c.Nick == "" || c.Channel == "" {
+		return errors.New("announce: server, nick and channel are required")
+	}
+	if _, _, err := net.SplitHostPort(c.Server); err != nil {
+		return fmt.Errorf("announce: invalid server %q: %s", c.Server, err)
+	}
+	_, err := c.Regexp()
+	return err
+}
+
+// Timeout after which a silent connection is considered dead. Servers ping
+// clients every few minutes.
+var Timeout = 10 * time.Minute
+
+// Listener is a connection to an announce channel.
+type Listener struct {
+	config Config
+	re     *regexp.Regexp
+	conn   net.Conn
+}
+
+// Dial connects and registers with the IRC server.
+func Dial(c Config) (*Listener, error) {
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	re, _ := c.Regexp()
+
+	var conn net.Conn
+	var err error
+	d := &net.Dialer{Timeout: 30 * time.Second}
+	if c.TLS {
+		conn, err = tls.DialWithDialer(d, "tcp", c.Server, nil)
+	} else {
+		conn, err = d.Dial("tcp", c.Server)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	l := &Listener{c, re, conn}
+	if c.Password != "" {
+		l.send("PASS " + c.Password)
+	}
+	l.send("NICK " + c.Nick)
+	if err := l.send("USER " + c.Nick + " 0 * :" + c.Nick); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return l, nil
+}
+
+func (l *Listener) send(line string) error {
+	l.conn.SetWriteDeadline(time.Now().Add(30 * time.Second))
+	_, err := fmt.Fprintf(l.conn, "%s\r\n", line)
+	return err
+}
+
+// Close disconnects from the server.
+func (l *Listener) Close() error {
+	l.send("QUIT")
+	return l.conn.Close()
+}
+
+// Listen joins the announce channel and sends the ID of each announced
+// torrent to ids. IDs are dropped while ids is full, so that a slow consumer
+// cannot stall the connection until the server drops it for not answering
+// pings. It returns when the connection fails or is closed.
+func (l *Listener) Listen(ids chan<- int) error {
+	r := bufio.NewReader(l.conn)
+	for {
+		l.conn.SetReadDeadline(time.Now().Add(Timeout))
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return err
+		}
+		m := parseMessage(strings.TrimRight(line, "\r\n"))
+
+		switch m.Command {
+		case "PING":
+			err = l.send("PONG :" + m.Trailing())
+		case "001":
+			for _, cmd := range l.config.Commands {
+				if err = l.send(cmd); err != nil {
+					return err
+				}
+			}
+			join := "JOIN " + l.config.Channel
+			if l.config.Key != "" {
+				join += " " + l.config.Key
+			}
+			err = l.send(join)
+		case "433":
+			// nick in use
+			l.config.Nick += "_"
+			err = l.send("NICK " + l.config.Nick)
+		case "ERROR":
+			return errors.New("announce: " + m.Trailing())
+		case "PRIVMSG":
+			if len(m.Params) < 2 || !strings.EqualFold(m.Params[0], l.config.Channel) {
+				continue
+			}
+			if l.config.Announcer != "" && !strings.EqualFold(m.Nick(), l.config.Announcer) {
+				continue
+			}
+			if id, ok := l.Match(m.Trailing()); ok {
+				select {
+				case ids <- id:
+				default:
+					log.Printf("announce: queue full, dropped torrent %d", id)
+				}
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// Match extracts the torrent ID from an announce line.
+func (l *Listener) Match(text string) (int, bool) {
+	sub := l.re.FindStringSubmatch(StripFormatting(text))
+	if sub == nil {
+		return 0, false
+	}
+	group := 1
+	for i, name := range l.re.SubexpNames() {
+		if name == "id" {
+			group = i
+		}
+	}
+	id, err := strconv.Atoi(sub[group])
+	return id, err == nil
+}
+
+var reFormatting = regexp.MustCompile("\x03[0-9]{0,2}(,[0-9]{1,2})?|[\x02\x0f\x16\x1d\x1f]")
+
+// StripFormatting removes IRC color and formatting codes.
+func StripFormatting(s string) string {
+	return reFormatting.ReplaceAllString(s, "")
+}
+
+type message struct {
+	Prefix  string
+	Command string
+	Params  []string
+}
+
+// parseMessage parses a raw IRC line, e.g.
+// ":nick!user@host PRIVMSG #channel :text"
+func parseMessage(line string) message {
+	var m message
+	if strings.HasPrefix(line, ":") {
+		i := strings.Index(line, " ")
+		if i < 0 {
+			return m
+		}
+		m.Prefix, line = line[1:i], line[i+1:]
+	}
+	for line != "" {
+		if strings.HasPrefix(line, ":") {
+			m.Params = append(m.Params, line[1:])
+			break
+		}
+		i := strings.Index(line, " ")
+		if i < 0 {
+			i = len(line)
+		}
+		if m.Command == "" {
+			m.Command = strings.ToUpper(line[:i])
+		} else {
+			m.Params = append(m.Params, line[:i])
+		}
+		line = strings.TrimLeft(line[i:], " ")
+	}
+	return m
+}
+
+// Trailing returns the last parameter.
+func (m message) Trailing() string {
+	if len(m.Params) == 0 {
+		return ""
+	}
+	return m.Params[len(m.Params)-1]
+}
+
+// Nick returns the nick of the sender.
+func (m message) Nick() string {
+	if i := strings.Index(m.Prefix, "!"); i >= 0 {
+		return m.Prefix[:i]
+	}
+	return m.Prefix
+}
@@ -0,0 +1,174 @@
+// Author: EmotionalDots @ PTH
+//
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package announce
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// ircServer is a minimal IRC server that records the client's lines and
+// lets tests send raw lines to it.
+type ircServer struct {
+	ln    net.Listener
+	lines chan string
+	conn  chan net.Conn
+}
+
+func newIRCServer(t *testing.T) *ircServer {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &ircServer{ln, make(chan string, 100), make(chan net.Conn, 1)}
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		s.conn <- conn
+		r := bufio.NewReader(conn)
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				close(s.lines)
+				return
+			}
+			s.lines <- strings.TrimRight(line, "\r\n")
+		}
+	}()
+	return s
+}
+
+// expect waits for a line from the client with the given prefix.
+func (s *ircServer) expect(t *testing.T, prefix string) string {
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case line, ok := <-s.lines:
+			if !ok {
+				t.Fatalf("connection closed, expected %q", prefix)
+			}
+			if strings.HasPrefix(line, prefix) {
+				return line
+			}
+		case <-timeout:
+			t.Fatalf("timeout, expected %q", prefix)
+		}
+	}
+}
+
+func TestListen(t *testing.T) {
+	s := newIRCServer(t)
+	defer s.ln.Close()
+
+	l, err := Dial(Config{
+		Server:    s.ln.Addr().String(),
+		Nick:      "arbitrage",
+		Channel:   "#announce",
+		Announcer: "Drone",
+		Commands:  []string{"PRIVMSG NickServ :IDENTIFY secret"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	ids := make(chan int, 10)
+	errs := make(chan error, 1)
+	go func() { errs <- l.Listen(ids) }()
+
+	conn := <-s.conn
+	send := func(format string, args ...interface{}) {
+		fmt.Fprintf(conn, format+"\r\n", args...)
+	}
+	s.expect(t, "NICK arbitrage")
+	s.expect(t, "USER arbitrage")
+
+	send(":irc.test 433 * arbitrage :Nickname is already in use")
+	s.expect(t, "NICK arbitrage_")
+	send(":irc.test 001 arbitrage_ :Welcome")
+	s.expect(t, "PRIVMSG NickServ :IDENTIFY secret")
+	s.expect(t, "JOIN #announce")
+
+	send("PING :irc.test")
+	if line := s.expect(t, "PONG"); line != "PONG :irc.test" {
+		t.Errorf("unexpected pong: %q", line)
+	}
+
+	send(":Someone!user@host PRIVMSG #announce :fake - https://tracker.test/torrents.php?id=1&torrentid=666")
+	send(":Drone!drone@tracker PRIVMSG #other :Other - https://tracker.test/torrents.php?id=1&torrentid=667")
+	send(":Drone!drone@tracker PRIVMSG #announce :\x0304Artist\x03 - \x02Album\x02 [2017] - FLAC / Lossless - https://tracker.test/torrents.php?id=100&torrentid=1234")
+	send(":Drone!drone@tracker PRIVMSG #ANNOUNCE :Artist - Single - https://tracker.test/torrents.php?id=101&torrentid=1235")
+
+	for _, expected := range []int{1234, 1235} {
+		select {
+		case id := <-ids:
+			if id != expected {
+				t.Errorf("expected torrent %d, got %d", expected, id)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timeout waiting for torrent %d", expected)
+		}
+	}
+
+	send("ERROR :Closing link")
+	select {
+	case err := <-errs:
+		if err == nil || !strings.Contains(err.Error(), "Closing link") {
+			t.Errorf("expected server error, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("listener did not return")
+	}
+}
+
+func TestListenFullQueue(t *testing.T) {
+	s := newIRCServer(t)
+	defer s.ln.Close()
+
+	l, err := Dial(Config{Server: s.ln.Addr().String(), Nick: "arbitrage", Channel: "#announce"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	// nobody receives from ids, announces must not block the connection
+	ids := make(chan int)
+	go l.Listen(ids)
+
+	conn := <-s.conn
+	s.expect(t, "USER arbitrage")
+	fmt.Fprintf(conn, ":Drone!drone@tracker PRIVMSG #announce :Artist - Album - https://tracker.test/torrents.php?id=1&torrentid=1234\r\n")
+	fmt.Fprintf(conn, "PING :irc.test\r\n")
+	s.expect(t, "PONG :irc.test")
+}
+
+func TestMatch(t *testing.T) {
+	l := &Listener{}
+	var err error
+	l.re, err = Config{Pattern: `^(.+) - (?P<id>\d+)$`}.Regexp()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id, ok := l.Match("Artist - Album - 42"); !ok || id != 42 {
+		t.Errorf("expected named group id 42, got %d %v", id, ok)
+	}
+	if _, ok := l.Match("no id here"); ok {
+		t.Error("unexpected match")
+	}
+
+	if _, err := (Config{Pattern: `torrentid=\d+`}).Regexp(); err == nil {
+		t.Error("expected error for pattern without group")
+	}
+	if err := (Config{Server: "irc.test", Nick: "a", Channel: "#a"}).Validate(); err == nil {
+		t.Error("expected error for server without port")
+	}
+}
@@ -0,0 +1,98 @@
+// Package apierr classifies the errors of tracker backends, so that callers
+// can decide whether to skip, retry or log in again without comparing error
+// messages.
+package apierr
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+)
+
+type Kind int
+
+const (
+	Unknown      Kind = iota
+	NotFound          // the requested ID does not exist
+	Deleted           // the requested ID existed but was removed
+	RateLimited       // too many requests, retry later
+	LoggedOut         // the session is no longer valid
+	Maintenance       // the tracker is down
+	ParseFailure      // the response could not be parsed
+	Network           // the tracker could not be reached
+)
+
+var kindNames = []string{"unknown", "not found", "deleted", "rate limited", "logged out", "maintenance", "parse failure", "network"}
+
+func (k Kind) String() string {
+	if int(k) < len(kindNames) {
+		return kindNames[k]
+	}
+	return fmt.Sprintf("kind %d", int(k))
+}
+
+// Error is an error of a known kind. Its message is the message of the
+// original error, so classifying errors does not change what is logged.
+type Error struct {
+	Kind Kind
+	Err  error
+}
+
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+// New returns an error of the given kind.
+func New(kind Kind, msg string) error {
+	return &Error{kind, fmt.Errorf("%s", msg)}
+}
+
+// Errorf returns a formatted error of the given kind.
+func Errorf(kind Kind, format string, args ...interface{}) error {
+	return &Error{kind, fmt.Errorf(format, args...)}
+}
+
+// Wrap classifies an existing error, nil stays nil.
+func Wrap(kind Kind, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &Error{kind, err}
+}
+
+// KindOf returns the kind of an error. Errors from the network stack are
+// classified as Network.
+func KindOf(err error) Kind {
+	switch e := err.(type) {
+	case nil:
+		return Unknown
+	case *Error:
+		return e.Kind
+	case net.Error:
+		return Network
+	}
+	return Unknown
+}
+
+// Is returns whether an error is of the given kind.
+func Is(err error, kind Kind) bool {
+	return err != nil && KindOf(err) == kind
+}
+
+// FromStatus returns an error for an unexpected HTTP status.
+func FromStatus(resp *http.Response) error {
+	kind := Unknown
+	switch resp.StatusCode {
+	case http.StatusNotFound:
+		kind = NotFound
+	case http.StatusGone:
+		kind = Deleted
+	case http.StatusTooManyRequests:
+		kind = RateLimited
+	case http.StatusUnauthorized, http.StatusForbidden:
+		kind = LoggedOut
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		kind = Maintenance
+	}
+	return Errorf(kind, "Request failed: Status Code %s", resp.Status)
+}
@@ -0,0 +1,36 @@
+package apierr
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"testing"
+)
+
+func TestKindOf(t *testing.T) {
+	tests := []struct {
+		err  error
+		kind Kind
+	}{
+		{nil, Unknown},
+		{errors.New("plain"), Unknown},
+		{New(NotFound, "Request failed: bad id parameter"), NotFound},
+		{Wrap(ParseFailure, errors.New("unexpected EOF")), ParseFailure},
+		{&net.OpError{Op: "dial", Err: errors.New("connection refused")}, Network},
+		{FromStatus(&http.Response{StatusCode: 503, Status: "503 Service Unavailable"}), Maintenance},
+		{FromStatus(&http.Response{StatusCode: 429, Status: "429 Too Many Requests"}), RateLimited},
+		{FromStatus(&http.Response{StatusCode: 500, Status: "500 Internal Server Error"}), Unknown},
+	}
+	for _, tt := range tests {
+		if kind := KindOf(tt.err); kind != tt.kind {
+			t.Errorf("%v: expected %s, got %s", tt.err, tt.kind, kind)
+		}
+	}
+
+	if err := New(NotFound, "Request failed: bad id parameter"); err.Error() != "Request failed: bad id parameter" {
+		t.Errorf("message changed: %q", err)
+	}
+	if Wrap(Network, nil) != nil {
+		t.Error("wrapped nil error is not nil")
+	}
+}
@@ -2,6 +2,8 @@ package gazelle
 
 import (
 	"encoding/json"
+	"errors"
+	"io"
 	"io/ioutil"
 	"net/http"
 	"net/http/cookiejar"
@@ -9,8 +11,8 @@ import (
 	"strconv"
 	"strings"
 
+	"github.com/emotionaldots/arbitrage/pkg/api/apierr"
 	"github.com/emotionaldots/arbitrage/pkg/model"
-	"github.com/emotionaldots/arbitrage/pkg/model/fixes"
 )
 
 func NewAPI(url, agent string) (*API, error) {
@@ -31,9 +33,16 @@ type API struct {
 	client    *http.Client
 	authkey   string
 	passkey   string
+	userID    int
 	loggedIn  bool
 }
 
+// SetTransport replaces the HTTP transport of the API client, e.g. with a
+// record/replay transport for offline testing.
+func (w *API) SetTransport(rt http.RoundTripper) {
+	w.client.Transport = rt
+}
+
 func (w *API) GetJSON(requestURL string, responseObj interface{}) error {
 	if !w.loggedIn {
 		return errRequestFailedLogin
@@ -46,27 +55,30 @@ func (w *API) GetJSON(requestURL string, responseObj interface{}) error {
 	}
 	resp, err := w.client.Do(req)
 	if err != nil {
-		return err
+		return apierr.Wrap(apierr.Network, err)
 	}
 
 	defer resp.Body.Close()
 	if resp.StatusCode != 200 {
-		return errRequestFailedReason("Status Code " + resp.Status)
+		return apierr.FromStatus(resp)
+	}
+	if resp.Request != nil && strings.HasSuffix(resp.Request.URL.Path, "login.php") {
+		return apierr.New(apierr.LoggedOut, "Request failed: redirected to login")
 	}
 	body, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		return err
+		return apierr.Wrap(apierr.Network, err)
 	}
 
 	var st Response
 	if err := json.Unmarshal(body, &st); err != nil {
-		return err
+		return apierr.Wrap(apierr.ParseFailure, err)
 	}
 
 	if err := checkResponseStatus(st.Status, st.Error); err != nil {
 		return err
 	}
-	return json.Unmarshal([]byte(*st.Result), responseObj)
+	return apierr.Wrap(apierr.ParseFailure, json.Unmarshal([]byte(*st.Result), responseObj))
 }
 
 type Response struct {
@@ -76,13 +88,18 @@ type Response struct {
 }
 
 func (w *API) Do(action string, params url.Values, result interface{}) error {
-	requestURL, err := buildURL(w.baseURL, "ajax.php", action, params)
+	requestURL, err := w.RequestURL(action, params)
 	if err != nil {
 		return err
 	}
 	return w.GetJSON(requestURL, result)
 }
 
+// RequestURL returns the ajax.php URL that is requested for an action.
+func (w *API) RequestURL(action string, params url.Values) (string, error) {
+	return buildURL(w.baseURL, "ajax.php", action, params)
+}
+
 func (w *API) CreateDownloadURL(id int) (string, error) {
 	if !w.loggedIn {
 		return "", errRequestFailedLogin
@@ -100,6 +117,51 @@ func (w *API) CreateDownloadURL(id int) (string, error) {
 	return downloadURL, nil
 }
 
+func (w *API) DownloadTorrent(id int) (io.ReadCloser, error) {
+	u, err := w.CreateDownloadURL(id)
+	if err != nil {
+		return nil, err
+	}
+	return w.download(u)
+}
+
+// DownloadTorrentWithToken downloads a torrent and spends a freeleech token
+// on it.
+func (w *API) DownloadTorrentWithToken(id int) (io.ReadCloser, error) {
+	u, err := w.CreateDownloadURL(id)
+	if err != nil {
+		return nil, err
+	}
+	body, err := w.download(u + "&usetoken=1")
+	if err == errNotATorrent {
+		return nil, ErrTokenFailed
+	}
+	return body, err
+}
+
+func (w *API) download(u string) (io.ReadCloser, error) {
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", w.userAgent)
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != 200 {
+		resp.Body.Close()
+		return nil, errors.New("unexpected status: " + resp.Status)
+	}
+	// Gazelle reports errors, e.g. no tokens left, as HTML pages
+	if strings.HasPrefix(resp.Header.Get("Content-Type"), "text/html") {
+		resp.Body.Close()
+		return nil, errNotATorrent
+	}
+
+	return resp.Body, nil
+}
+
 func (w *API) Login(username, password string) error {
 	params := url.Values{}
 	params.Set("username", username)
@@ -123,10 +185,16 @@ func (w *API) Login(username, password string) error {
 	if err != nil {
 		return err
 	}
-	w.authkey, w.passkey = account.AuthKey, account.PassKey
+	w.authkey, w.passkey = string(account.AuthKey), string(account.PassKey)
+	w.userID = int(account.ID)
 	return nil
 }
 
+// UserID returns the ID of the logged in user.
+func (w *API) UserID() int {
+	return w.userID
+}
+
 func (w *API) Logout() error {
 	params := url.Values{"auth": {w.authkey}}
 	requestURL, err := buildURL(w.baseURL, "logout.php", "", params)
@@ -137,7 +205,7 @@ func (w *API) Logout() error {
 	if err != nil {
 		return err
 	}
-	w.loggedIn, w.authkey, w.passkey = false, "", ""
+	w.loggedIn, w.authkey, w.passkey, w.userID = false, "", "", 0
 	return nil
 }
 
@@ -162,8 +230,39 @@ func (w *API) GetTorrentGroup(id int, params url.Values) (model.GroupAndTorrents
 }
 
 func (w *API) GetCollage(id int, params url.Values) (model.CollageWithGroups, error) {
-	var result fixes.CollageWithStringedGroups
+	var result model.CollageWithGroups
 	params.Set("id", strconv.Itoa(id))
 	err := w.Do("collage", params, &result)
-	return result.Fix(), err
+	return result, err
+}
+
+// GetUserTorrents returns a page of a user's torrent list, typ is one of
+// seeding, leeching, uploaded or snatched.
+func (w *API) GetUserTorrents(userID int, typ string, limit, offset int) ([]model.UserTorrent, error) {
+	params := url.Values{}
+	params.Set("id", strconv.Itoa(userID))
+	params.Set("type", typ)
+	params.Set("limit", strconv.Itoa(limit))
+	params.Set("offset", strconv.Itoa(offset))
+
+	var result map[string][]model.UserTorrent
+	if err := w.Do("user_torrents", params, &result); err != nil {
+		return nil, err
+	}
+	return result[typ], nil
+}
+
+// GetBrowse returns a page of search results, by default the most recent
+// uploads.
+func (w *API) GetBrowse(params url.Values) (model.Browse, error) {
+	var result model.Browse
+	err := w.Do("browse", params, &result)
+	return result, err
+}
+
+func (w *API) GetArtist(id int, params url.Values) (model.ArtistWithGroups, error) {
+	var result model.ArtistWithGroups
+	params.Set("id", strconv.Itoa(id))
+	err := w.Do("artist", params, &result)
+	return result, err
 }
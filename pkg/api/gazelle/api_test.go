@@ -0,0 +1,144 @@
+package gazelle
+
+import (
+	"io/ioutil"
+	"net/url"
+	"testing"
+
+	"github.com/emotionaldots/arbitrage/pkg/api/apierr"
+	"github.com/emotionaldots/arbitrage/pkg/api/replay"
+)
+
+func newReplayAPI(t *testing.T) *API {
+	rt, err := replay.New(replay.ModeReplay, "testdata")
+	if err != nil {
+		t.Fatal(err)
+	}
+	w, err := NewAPI("https://tracker.test/", "arbitrage/test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.SetTransport(rt)
+	if err := w.Login("user", "secret"); err != nil {
+		t.Fatal("login:", err)
+	}
+	return w
+}
+
+func TestLogin(t *testing.T) {
+	w := newReplayAPI(t)
+	if w.authkey != replay.Redacted || w.passkey != replay.Redacted {
+		t.Errorf("expected redacted keys, got %q %q", w.authkey, w.passkey)
+	}
+
+	account, err := w.GetAccount()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if account.Username != "tester" || account.UserStats.RequiredRatio != 0.6 {
+		t.Errorf("unexpected account: %+v", account)
+	}
+}
+
+func TestGetTorrent(t *testing.T) {
+	w := newReplayAPI(t)
+
+	gt, err := w.GetTorrent(1234, url.Values{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gt.Torrent.ID != 1234 || gt.Group.ID != 100 {
+		t.Errorf("unexpected ids: torrent %d, group %d", gt.Torrent.ID, gt.Group.ID)
+	}
+	if gt.Group.Name != "The What CD" || gt.Torrent.Format != "FLAC" || !gt.Torrent.HasLog {
+		t.Errorf("unexpected release: %v", gt.Torrent)
+	}
+	if len(gt.Group.MusicInfo.Artists) != 1 || len(gt.Group.Tags) != 2 {
+		t.Errorf("unexpected group metadata: %v", gt.Group)
+	}
+
+	if _, err := w.GetTorrent(1, url.Values{}); err == nil || err.Error() != "Request failed: bad id parameter" {
+		t.Errorf("expected bad id error, got %v", err)
+	}
+	if _, err := w.GetTorrent(1, url.Values{}); !apierr.Is(err, apierr.NotFound) {
+		t.Errorf("expected not found error, got %v (%s)", err, apierr.KindOf(err))
+	}
+}
+
+func TestDownloadTorrent(t *testing.T) {
+	w := newReplayAPI(t)
+
+	body, err := w.DownloadTorrent(1234)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer body.Close()
+	raw, err := ioutil.ReadAll(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(raw) != "d4:infod4:name9:What CD 1ee" {
+		t.Errorf("unexpected torrent file: %q", raw)
+	}
+}
+
+func TestDownloadTorrentWithToken(t *testing.T) {
+	w := newReplayAPI(t)
+
+	if _, err := w.DownloadTorrentWithToken(1234); err != ErrTokenFailed {
+		t.Errorf("expected ErrTokenFailed without tokens left, got %v", err)
+	}
+}
+
+func TestGetCollage(t *testing.T) {
+	w := newReplayAPI(t)
+
+	c, err := w.GetCollage(7, url.Values{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.ID != 7 || len(c.TorrentGroupIDList) != 2 || len(c.TorrentGroups) != 2 {
+		t.Fatalf("unexpected collage: %+v", c.Collage)
+	}
+
+	g := c.TorrentGroups[1]
+	if g.ID != 101 || g.Year != 1999 || g.Name != "1999" || g.CatalogueNumber != "1234" || !bool(g.VanityHouse) {
+		t.Errorf("stringly-typed group not decoded: %+v", g.Group)
+	}
+	if g := c.TorrentGroups[0]; len(g.Torrents) != 1 || g.Torrents[0].TorrentID != 1234 || g.TagList != "electronic compilation" {
+		t.Errorf("unexpected collage torrents: %+v", g)
+	}
+}
+
+func TestGetUserTorrents(t *testing.T) {
+	w := newReplayAPI(t)
+	if w.UserID() != 42 {
+		t.Fatalf("expected user id 42, got %d", w.UserID())
+	}
+
+	list, err := w.GetUserTorrents(w.UserID(), "seeding", 2, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 2 || list[0].TorrentID != 1234 || list[1].TorrentID != 1240 || list[1].GroupID != 101 {
+		t.Errorf("unexpected user torrents: %+v", list)
+	}
+}
+
+func TestGetBrowse(t *testing.T) {
+	w := newReplayAPI(t)
+
+	b, err := w.GetBrowse(url.Values{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(b.Results) != 3 || b.Results[1].TorrentID != 1305 {
+		t.Fatalf("unexpected browse results: %+v", b)
+	}
+	if id := b.MaxTorrentID(); id != 1310 {
+		t.Errorf("expected newest torrent 1310, got %d", id)
+	}
+	if id := b.MaxGroupID(); id != 103 {
+		t.Errorf("expected newest group 103, got %d", id)
+	}
+}
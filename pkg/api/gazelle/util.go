@@ -4,16 +4,24 @@ import (
 	"errors"
 	"fmt"
 	"net/url"
+	"strings"
+
+	"github.com/emotionaldots/arbitrage/pkg/api/apierr"
 )
 
 var (
 	errLoginFailed         = errors.New("Login failed")
 	errRequestFailed       = errors.New("Request failed")
-	errRequestFailedLogin  = errors.New("Request failed: not logged in")
-	errRequestFailedReason = func(err string) error { return fmt.Errorf("Request failed: %s", err) }
+	errRequestFailedLogin  = apierr.New(apierr.LoggedOut, "Request failed: not logged in")
+	errRequestFailedReason = func(err string) error { return apierr.Errorf(reasonKind(err), "Request failed: %s", err) }
+	errNotATorrent         = errors.New("Download failed: tracker returned a page instead of a torrent")
 	debugMode              = false
 )
 
+// ErrTokenFailed is returned by DownloadTorrentWithToken if the tracker
+// refused to spend a freeleech token, e.g. because none are left.
+var ErrTokenFailed = errors.New("Download failed: could not use freeleech token")
+
 func buildURL(baseURL, path, action string, params url.Values) (string, error) {
 	u, err := url.Parse(baseURL)
 	if err != nil {
@@ -36,6 +44,22 @@ func buildURL(baseURL, path, action string, params url.Values) (string, error) {
 	return u.String(), nil
 }
 
+// reasonKind classifies the error messages of the Gazelle API.
+func reasonKind(reason string) apierr.Kind {
+	reason = strings.ToLower(reason)
+	switch {
+	case strings.Contains(reason, "bad id"), reason == "bad parameters", strings.Contains(reason, "not found"):
+		return apierr.NotFound
+	case strings.Contains(reason, "deleted"):
+		return apierr.Deleted
+	case strings.Contains(reason, "rate limit"):
+		return apierr.RateLimited
+	case strings.Contains(reason, "maintenance"):
+		return apierr.Maintenance
+	}
+	return apierr.Unknown
+}
+
 func checkResponseStatus(status, errorStr string) error {
 	if status != "success" {
 		if errorStr != "" {
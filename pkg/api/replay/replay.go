@@ -0,0 +1,290 @@
+// Package replay implements a record/replay http.RoundTripper, so tracker
+// scrapers can be tested and developed without a live tracker account.
+//
+// In record mode every request/response pair is passed through to the
+// tracker and saved as a JSON fixture, with passwords, auth keys, passkeys
+// and cookies redacted. In replay mode no network access happens at all,
+// responses are served from the fixture directory instead.
+package replay
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+const (
+	ModeOff    = ""
+	ModeRecord = "record"
+	ModeReplay = "replay"
+)
+
+// Redacted replaces all secrets in stored fixtures.
+const Redacted = "REDACTED"
+
+// SecretParams are query and form parameters that are never written to disk.
+var SecretParams = []string{
+	"username", "password", "_username", "_password",
+	"auth", "authkey", "passkey", "torrent_pass",
+}
+
+var reSecretBody = regexp.MustCompile(`(?i)("(?:authkey|passkey)"\s*:\s*")[^"]*(")|((?:authkey|passkey|torrent_pass)=)[0-9a-z]+`)
+
+type Request struct {
+	Method string `json:"method"`
+	URL    string `json:"url"`
+	Body   string `json:"body,omitempty"`
+}
+
+type Response struct {
+	StatusCode int         `json:"status"`
+	Header     http.Header `json:"header,omitempty"`
+	Body       string      `json:"body"`
+}
+
+// Fixture is a single recorded request/response pair as stored on disk.
+type Fixture struct {
+	Request  Request  `json:"request"`
+	Response Response `json:"response"`
+}
+
+// Key returns the lookup key of a fixture. It only depends on the redacted
+// request, so secrets do not have to match between recording and replay.
+func (f Fixture) Key() string {
+	return f.Request.Method + " " + f.Request.URL + "\n" + f.Request.Body
+}
+
+// Transport records or replays HTTP interactions in a fixture directory.
+type Transport struct {
+	Mode string
+	Dir  string
+	// Transport is used for live requests in record mode,
+	// http.DefaultTransport if nil.
+	Transport http.RoundTripper
+
+	mu       sync.Mutex
+	fixtures map[string]Fixture
+}
+
+// New creates a transport for the given mode and loads all existing fixtures
+// from dir.
+func New(mode, dir string) (*Transport, error) {
+	if mode != ModeRecord && mode != ModeReplay {
+		return nil, fmt.Errorf("replay: unknown mode %q", mode)
+	}
+	if dir == "" {
+		return nil, errors.New("replay: no fixture directory given")
+	}
+	t := &Transport{Mode: mode, Dir: dir}
+	return t, t.Load()
+}
+
+// Load (re)reads all fixtures from the fixture directory.
+func (t *Transport) Load() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.fixtures = make(map[string]Fixture)
+	err := filepath.Walk(t.Dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || filepath.Ext(path) != ".json" {
+			return nil
+		}
+		raw, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		var f Fixture
+		if err := json.Unmarshal(raw, &f); err != nil {
+			return fmt.Errorf("replay: %s: %s", path, err)
+		}
+		t.fixtures[f.Key()] = f
+		return nil
+	})
+	if os.IsNotExist(err) && t.Mode == ModeRecord {
+		return nil
+	}
+	return err
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := readBody(req)
+	if err != nil {
+		return nil, err
+	}
+	key := NewFixture(req, body, 0, nil, nil).Key()
+
+	if t.Mode == ModeReplay {
+		t.mu.Lock()
+		f, ok := t.fixtures[key]
+		t.mu.Unlock()
+		if !ok {
+			return nil, fmt.Errorf("replay: no fixture for %s %s", req.Method, RedactURL(req.URL))
+		}
+		return f.Response.toHTTP(req), nil
+	}
+
+	rt := t.Transport
+	if rt == nil {
+		rt = http.DefaultTransport
+	}
+	resp, err := rt.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	f := NewFixture(req, body, resp.StatusCode, resp.Header, respBody)
+	if err := t.Save(f); err != nil {
+		return nil, err
+	}
+
+	resp.Body = ioutil.NopCloser(bytes.NewReader(respBody))
+	return resp, nil
+}
+
+// Save writes a fixture to the fixture directory, grouped by host.
+func (t *Transport) Save(f Fixture) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	u, err := url.Parse(f.Request.URL)
+	if err != nil {
+		return err
+	}
+	dir := filepath.Join(t.Dir, u.Host)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	raw, err := json.MarshalIndent(f, "", "\t")
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, fixtureName(f)), raw, 0644); err != nil {
+		return err
+	}
+	if t.fixtures != nil {
+		t.fixtures[f.Key()] = f
+	}
+	return nil
+}
+
+// NewFixture creates a redacted fixture from a request/response pair.
+func NewFixture(req *http.Request, reqBody []byte, status int, header http.Header, body []byte) Fixture {
+	f := Fixture{}
+	f.Request.Method = req.Method
+	f.Request.URL = RedactURL(req.URL)
+	f.Request.Body = redactForm(string(reqBody))
+
+	f.Response.StatusCode = status
+	f.Response.Body = RedactBody(string(body))
+	if len(header) > 0 {
+		f.Response.Header = make(http.Header)
+		for k, v := range header {
+			if k == "Set-Cookie" || k == "Cookie" {
+				continue
+			}
+			f.Response.Header[k] = v
+		}
+	}
+	return f
+}
+
+// RedactURL returns the URL with all secret query parameters redacted.
+func RedactURL(u *url.URL) string {
+	c := *u
+	c.RawQuery = redactForm(u.RawQuery)
+	return c.String()
+}
+
+// RedactBody removes auth keys and passkeys from response bodies.
+func RedactBody(body string) string {
+	return reSecretBody.ReplaceAllString(body, "${1}${3}"+Redacted+"${2}")
+}
+
+func redactForm(raw string) string {
+	if raw == "" {
+		return ""
+	}
+	values, err := url.ParseQuery(raw)
+	if err != nil {
+		return raw
+	}
+	for _, p := range SecretParams {
+		if _, ok := values[p]; ok {
+			values.Set(p, Redacted)
+		}
+	}
+	return values.Encode()
+}
+
+func readBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil {
+		return nil, nil
+	}
+	body, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		return nil, err
+	}
+	req.Body.Close()
+	req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	return body, nil
+}
+
+func (r Response) toHTTP(req *http.Request) *http.Response {
+	header := make(http.Header)
+	for k, v := range r.Header {
+		header[k] = v
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", r.StatusCode, http.StatusText(r.StatusCode)),
+		StatusCode:    r.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          ioutil.NopCloser(strings.NewReader(r.Body)),
+		ContentLength: int64(len(r.Body)),
+		Request:       req,
+	}
+}
+
+var reUnsafe = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// fixtureName returns a readable, unique file name for a fixture,
+// e.g. "ajax.php-action-torrent-id-1234-5f3a9c01.json"
+func fixtureName(f Fixture) string {
+	u, _ := url.Parse(f.Request.URL)
+	name := strings.Trim(u.Path, "/")
+	if name == "" {
+		name = "index"
+	}
+	if q := u.Query(); len(q) > 0 {
+		name += "-" + q.Encode()
+	}
+	name = strings.Trim(reUnsafe.ReplaceAllString(name, "-"), "-")
+	if len(name) > 80 {
+		name = name[:80]
+	}
+
+	sum := sha1.Sum([]byte(f.Key()))
+	return name + "-" + hex.EncodeToString(sum[:4]) + ".json"
+}
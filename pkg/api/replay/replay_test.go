@@ -0,0 +1,147 @@
+package replay
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// secrets that must never end up in a fixture
+var testSecrets = []string{"hunter2", "s3cr3tauth", "s3cr3tpass", "c00kie"}
+
+func newTracker() *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/login.php", func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "c00kie"})
+		w.Write([]byte("welcome " + r.FormValue("username")))
+	})
+	mux.HandleFunc("/ajax.php", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"status": "success", "response": {"authkey": "s3cr3tauth", "passkey": "s3cr3tpass"}}`))
+	})
+	mux.HandleFunc("/torrents.php", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<a href="torrents.php?action=download&id=1&authkey=s3cr3tauth&torrent_pass=s3cr3tpass">DL</a>`))
+	})
+	return httptest.NewServer(mux)
+}
+
+func doRequests(t *testing.T, c *http.Client, base, auth, pass string) []string {
+	var bodies []string
+	read := func(resp *http.Response, err error) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		body, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		bodies = append(bodies, string(body))
+	}
+	read(c.PostForm(base+"/login.php", url.Values{"username": {"user"}, "password": {"hunter2"}}))
+	read(c.Get(base + "/ajax.php?action=index&auth=" + auth))
+	read(c.Get(base + "/torrents.php?action=download&id=1&authkey=" + auth + "&torrent_pass=" + pass))
+	return bodies
+}
+
+func TestRecordReplay(t *testing.T) {
+	srv := newTracker()
+	defer srv.Close()
+	dir, err := ioutil.TempDir("", "replay")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	rec, err := New(ModeRecord, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	live := doRequests(t, &http.Client{Transport: rec}, srv.URL, "s3cr3tauth", "s3cr3tpass")
+	if !strings.Contains(live[1], "s3cr3tauth") {
+		t.Errorf("record mode must pass the live response through, got %q", live[1])
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*", "*.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 3 {
+		t.Fatalf("expected 3 fixtures, got %v", files)
+	}
+	for _, file := range files {
+		raw, err := ioutil.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, s := range append(testSecrets, "Set-Cookie") {
+			if strings.Contains(string(raw), s) {
+				t.Errorf("%s: fixture contains %q:\n%s", filepath.Base(file), s, raw)
+			}
+		}
+	}
+
+	// secrets differ between sessions, replay finds the fixtures by their
+	// redacted requests
+	rep, err := New(ModeReplay, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	replayed := doRequests(t, &http.Client{Transport: rep}, srv.URL, "0therauth", "0therpass")
+	expected := []string{
+		"welcome user",
+		`{"status": "success", "response": {"authkey": "REDACTED", "passkey": "REDACTED"}}`,
+		`<a href="torrents.php?action=download&id=1&authkey=REDACTED&torrent_pass=REDACTED">DL</a>`,
+	}
+	for i, e := range expected {
+		if replayed[i] != e {
+			t.Errorf("replay %d: expected %q, got %q", i, e, replayed[i])
+		}
+	}
+
+	if _, err := (&http.Client{Transport: rep}).Get(srv.URL + "/ajax.php?action=browse"); err == nil {
+		t.Error("expected error for request without fixture")
+	}
+}
+
+func TestRedactURL(t *testing.T) {
+	tests := []struct {
+		url      string
+		expected string
+	}{
+		{"https://tracker.test/ajax.php?action=torrent&id=1", "https://tracker.test/ajax.php?action=torrent&id=1"},
+		{"https://tracker.test/torrents.php?action=download&authkey=a&id=1&torrent_pass=b",
+			"https://tracker.test/torrents.php?action=download&authkey=REDACTED&id=1&torrent_pass=REDACTED"},
+		{"https://tracker.test/feeds.php?passkey=abc&auth=def", "https://tracker.test/feeds.php?auth=REDACTED&passkey=REDACTED"},
+	}
+	for _, test := range tests {
+		u, err := url.Parse(test.url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r := RedactURL(u); r != test.expected {
+			t.Errorf("%s:\nexpected %s\ngot      %s", test.url, test.expected, r)
+		}
+	}
+}
+
+func TestRedactBody(t *testing.T) {
+	tests := []struct {
+		body     string
+		expected string
+	}{
+		{`{"authkey":"abc","PassKey": "def"}`, `{"authkey":"REDACTED","PassKey": "REDACTED"}`},
+		{`href="torrents.php?authkey=abc123&torrent_pass=def456"`, `href="torrents.php?authkey=REDACTED&torrent_pass=REDACTED"`},
+		{`{"id": 1, "name": "passkey"}`, `{"id": 1, "name": "passkey"}`},
+	}
+	for _, test := range tests {
+		if r := RedactBody(test.body); r != test.expected {
+			t.Errorf("%s:\nexpected %s\ngot      %s", test.body, test.expected, r)
+		}
+	}
+}
@@ -0,0 +1,423 @@
+// Package scraper implements a generic tracker backend for HTML-only
+// trackers. Login form, details page, file list and metadata fields are all
+// described by a Config, so new trackers can be added without writing code.
+package scraper
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/emotionaldots/arbitrage/pkg/api/apierr"
+	"github.com/emotionaldots/arbitrage/pkg/model"
+)
+
+var (
+	errLoginFailed         = errors.New("Login failed")
+	errRequestFailedLogin  = apierr.New(apierr.LoggedOut, "Request failed: not logged in")
+	errRequestFailedReason = func(err string) error { return fmt.Errorf("Request failed: %s", err) }
+)
+
+func NewAPI(url, agent string, cfg Config) (*API, error) {
+	w := &API{}
+	w.baseURL = url
+	w.userAgent = agent
+	w.config = cfg
+	w.captures = make(map[string]string)
+	w.regexps = make(map[string]*regexp.Regexp)
+	cookieJar, err := cookiejar.New(nil)
+	if err != nil {
+		return w, err
+	}
+	w.client = &http.Client{Jar: cookieJar}
+
+	for name, f := range cfg.Fields {
+		if err := w.compile(f); err != nil {
+			return w, fmt.Errorf("scraper: field %s: %s", name, err)
+		}
+	}
+	for name, f := range cfg.Login.Captures {
+		if err := w.compile(f); err != nil {
+			return w, fmt.Errorf("scraper: capture %s: %s", name, err)
+		}
+	}
+	return w, nil
+}
+
+// compile validates and caches the regular expression of a field
+func (w *API) compile(f Field) error {
+	if f.Regexp == "" || w.regexps[f.Regexp] != nil {
+		return nil
+	}
+	re, err := regexp.Compile(f.Regexp)
+	if err != nil {
+		return err
+	}
+	w.regexps[f.Regexp] = re
+	return nil
+}
+
+type API struct {
+	baseURL   string
+	userAgent string
+	client    *http.Client
+	config    Config
+	captures  map[string]string
+	regexps   map[string]*regexp.Regexp
+	loggedIn  bool
+}
+
+// SetTransport replaces the HTTP transport of the API client, e.g. with a
+// record/replay transport for offline testing.
+func (w *API) SetTransport(rt http.RoundTripper) {
+	w.client.Transport = rt
+}
+
+func (w *API) Login(username, password string) error {
+	lc := w.config.Login
+	params := url.Values{}
+	for k, v := range lc.Extra {
+		params.Set(k, v)
+	}
+	params.Set(lc.UsernameField, username)
+	params.Set(lc.PasswordField, password)
+
+	reqBody := strings.NewReader(params.Encode())
+	req, err := http.NewRequest("POST", w.baseURL+lc.URL, reqBody)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("User-Agent", w.userAgent)
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return errLoginFailed
+	}
+	if lc.SuccessSelector == "" && resp.Request.URL.Path == req.URL.Path {
+		// not redirected away from the login form; with a success selector
+		// the page itself decides, as some trackers render the logged-in
+		// page at the login URL
+		return errLoginFailed
+	}
+
+	doc, err := goquery.NewDocumentFromReader(resp.Body)
+	if err != nil {
+		return err
+	}
+	if lc.SuccessSelector != "" && doc.Find(lc.SuccessSelector).Length() == 0 {
+		return errLoginFailed
+	}
+	for name, f := range lc.Captures {
+		v, err := w.extract(doc.Selection, f)
+		if err != nil {
+			return apierr.Errorf(apierr.ParseFailure, "Parsing failed: capture %s: %s", name, err)
+		}
+		if v == "" {
+			return apierr.Errorf(apierr.ParseFailure, "Parsing failed: empty capture %s", name)
+		}
+		w.captures[name] = v
+	}
+
+	w.loggedIn = true
+	return nil
+}
+
+// expand replaces all placeholders in a configured URL
+func (w *API) expand(path string, id int) (string, error) {
+	path = strings.Replace(path, "{id}", strconv.Itoa(id), -1)
+	for k, v := range w.captures {
+		path = strings.Replace(path, "{"+k+"}", url.PathEscape(v), -1)
+	}
+	u, err := url.Parse(w.baseURL)
+	if err != nil {
+		return "", err
+	}
+	ref, err := url.Parse(path)
+	if err != nil {
+		return "", err
+	}
+	return u.ResolveReference(ref).String(), nil
+}
+
+// TorrentURL returns the details page URL that is requested for a torrent.
+func (w *API) TorrentURL(id int) (string, error) {
+	return w.expand(w.config.DetailsURL, id)
+}
+
+func (w *API) CreateDownloadURL(id int) (string, error) {
+	if !w.loggedIn {
+		return "", errRequestFailedLogin
+	}
+	if w.config.DownloadURL == "" {
+		return "", errors.New("scraper: no download_url configured")
+	}
+	return w.expand(w.config.DownloadURL, id)
+}
+
+func (w *API) get(requestURL string) (*http.Response, error) {
+	if !w.loggedIn {
+		return nil, errRequestFailedLogin
+	}
+
+	req, err := http.NewRequest("GET", requestURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", w.userAgent)
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return nil, apierr.Wrap(apierr.Network, err)
+	}
+	if resp.StatusCode != 200 {
+		resp.Body.Close()
+		return nil, apierr.FromStatus(resp)
+	}
+	return resp, nil
+}
+
+func (w *API) DoTorrent(id int) ([]byte, error) {
+	u, err := w.TorrentURL(id)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := w.get(u)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return ioutil.ReadAll(resp.Body)
+}
+
+func (w *API) DownloadTorrent(id int) (io.ReadCloser, error) {
+	u, err := w.CreateDownloadURL(id)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := w.get(u)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// ParseTorrent extracts a release from a details page, as described by the
+// field selectors in the config.
+func (w *API) ParseTorrent(body []byte) (model.TorrentAndGroup, error) {
+	r := model.TorrentAndGroup{}
+
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(body))
+	if err != nil {
+		return r, err
+	}
+	if w.config.ValidSelector != "" && doc.Find(w.config.ValidSelector).Length() == 0 {
+		return r, apierr.New(apierr.NotFound, "Parsing failed: no filelist found")
+	}
+
+	files, err := w.parseFileList(doc)
+	if err != nil {
+		return r, err
+	}
+
+	// All fields are collected as strings and decoded by the flexible model
+	// types, just like a stringly-typed Gazelle response.
+	torrent := map[string]interface{}{"fileList": files}
+	group := map[string]interface{}{}
+	musicInfo := map[string]interface{}{}
+	group["musicInfo"] = musicInfo
+
+	for name, f := range w.config.Fields {
+		v, err := w.extract(doc.Selection, f)
+		if err != nil {
+			return r, apierr.Errorf(apierr.ParseFailure, "Parsing failed: %s: %s", name, err)
+		}
+		var value interface{} = v
+		if f.Split != "" {
+			value = splitList(v, f.Split)
+		}
+
+		switch {
+		case name == "group.artists":
+			var artists []map[string]string
+			for _, a := range splitList(v, f.Split) {
+				artists = append(artists, map[string]string{"name": a})
+			}
+			musicInfo["artists"] = artists
+		case name == "group.tags":
+			group["tags"] = splitList(v, f.Split)
+		case strings.HasPrefix(name, "torrent."):
+			setPath(torrent, strings.TrimPrefix(name, "torrent."), value)
+		case strings.HasPrefix(name, "group."):
+			setPath(group, strings.TrimPrefix(name, "group."), value)
+		default:
+			return r, fmt.Errorf("scraper: unknown field %q", name)
+		}
+	}
+
+	raw, err := json.Marshal(map[string]interface{}{"torrent": torrent, "group": group})
+	if err != nil {
+		return r, err
+	}
+	if err := json.Unmarshal(raw, &r); err != nil {
+		return r, apierr.Errorf(apierr.ParseFailure, "Parsing failed: %s", err)
+	}
+
+	if r.Torrent.ID == 0 {
+		return r, apierr.New(apierr.ParseFailure, "Parsing failed: no id found")
+	}
+	if r.Group.ID == 0 {
+		r.Group.ID = r.Torrent.ID
+	}
+	return r, nil
+}
+
+func (w *API) parseFileList(doc *goquery.Document) (string, error) {
+	fc := w.config.FileList
+	if fc.Rows == "" {
+		return "", nil
+	}
+
+	var err error
+	files := make([]string, 0)
+	doc.Find(fc.Rows).Each(func(i int, s *goquery.Selection) {
+		if (i == 0 && fc.SkipHeader) || err != nil {
+			return
+		}
+		name, size := s.Children().First(), s.Children().Last()
+		if fc.Name != "" {
+			name = s.Find(fc.Name)
+		}
+		if fc.Size != "" {
+			size = s.Find(fc.Size)
+		}
+
+		var n int64
+		n, err = parseBytes(strings.TrimSpace(size.Text()))
+		files = append(files, strings.TrimSpace(name.Text())+"{{{"+strconv.FormatInt(n, 10)+"}}}")
+	})
+	if err != nil {
+		return "", err
+	}
+	if len(files) == 0 {
+		return "", apierr.New(apierr.NotFound, "Parsing failed: no filelist found")
+	}
+	return strings.Join(files, "|||"), nil
+}
+
+// extract evaluates a single field selector on a page
+func (w *API) extract(doc *goquery.Selection, f Field) (string, error) {
+	s := doc.Find(f.Selector).First()
+
+	var v string
+	switch {
+	case f.Attr != "":
+		v, _ = s.Attr(f.Attr)
+	case f.HTML:
+		html, err := s.Html()
+		if err != nil {
+			return "", err
+		}
+		v = html
+	default:
+		v = s.Text()
+	}
+	v = strings.TrimSpace(v)
+
+	if f.Param != "" && v != "" {
+		u, err := url.Parse(v)
+		if err != nil {
+			return "", err
+		}
+		v = u.Query().Get(f.Param)
+	}
+	if f.Regexp != "" {
+		m := w.regexps[f.Regexp].FindStringSubmatch(v)
+		v = ""
+		if len(m) > 1 {
+			v = m[1]
+		} else if len(m) == 1 {
+			v = m[0]
+		}
+	}
+	if mapped, ok := f.Map[v]; ok {
+		v = mapped
+	}
+	return v, nil
+}
+
+// setPath sets a value in nested maps, e.g. "bookInfo.isbn" for e-books
+// or "videoInfo.resolution" for videos.
+func setPath(m map[string]interface{}, path string, value interface{}) {
+	parts := strings.Split(path, ".")
+	for _, p := range parts[:len(parts)-1] {
+		child, ok := m[p].(map[string]interface{})
+		if !ok {
+			child = make(map[string]interface{})
+			m[p] = child
+		}
+		m = child
+	}
+	m[parts[len(parts)-1]] = value
+}
+
+func splitList(v, sep string) []string {
+	list := make([]string, 0)
+	if sep == "" {
+		if v != "" {
+			list = append(list, v)
+		}
+		return list
+	}
+	for _, s := range strings.Split(v, sep) {
+		if s = strings.TrimSpace(s); s != "" {
+			list = append(list, s)
+		}
+	}
+	return list
+}
+
+var units = map[string]int64{
+	"b": 1, "bytes": 1,
+	"kb": 1 << 10, "kib": 1 << 10,
+	"mb": 1 << 20, "mib": 1 << 20,
+	"gb": 1 << 30, "gib": 1 << 30,
+	"tb": 1 << 40, "tib": 1 << 40,
+}
+
+func parseBytes(s string) (int64, error) {
+	s = strings.Replace(s, ",", "", -1)
+	parts := strings.Fields(s)
+	if len(parts) == 1 {
+		n, err := strconv.ParseInt(parts[0], 10, 64)
+		if err == nil {
+			return n, nil
+		}
+	}
+	if len(parts) < 2 {
+		return -1, fmt.Errorf("Could not parse byte string %q: invalid parts", s)
+	}
+
+	n, err := strconv.ParseFloat(parts[0], 64)
+	if err != nil {
+		return -1, fmt.Errorf("Could not parse byte string %q: %s", s, err)
+	}
+	unit, ok := units[strings.ToLower(parts[1])]
+	if !ok {
+		return -1, fmt.Errorf("Could not parse byte string %q: unknown unit", s)
+	}
+	return int64(n * float64(unit)), nil
+}
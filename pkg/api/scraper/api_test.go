@@ -0,0 +1,162 @@
+package scraper
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/emotionaldots/arbitrage/pkg/api/replay"
+)
+
+var testConfig = Config{
+	Login: Login{
+		URL:             "login.php",
+		UsernameField:   "username",
+		PasswordField:   "password",
+		Extra:           map[string]string{"keeplogged": "1"},
+		SuccessSelector: "#userinfo",
+		Captures: map[string]Field{
+			"uid": {Selector: "#userinfo a", Attr: "href", Param: "id"},
+		},
+	},
+	DetailsURL:    "torrent.php?id={id}",
+	DownloadURL:   "download.php/{uid}/{id}.torrent",
+	ValidSelector: "table.files",
+	FileList: FileList{
+		Rows:       "table.files tr",
+		SkipHeader: true,
+	},
+	Fields: map[string]Field{
+		"torrent.id":          {Selector: "td:contains('Download') + td a", Attr: "href", Param: "id"},
+		"torrent.format":      {Selector: "h1", Regexp: `/(FLAC|MP3)`},
+		"torrent.hasLog":      {Selector: "h1", Regexp: `/(Log)\]`, Map: map[string]string{"Log": "1"}},
+		"torrent.freeTorrent": {Selector: "td:contains('Free') + td", Map: map[string]string{"Yes": "1", "No": "0"}},
+		"group.name":          {Selector: "h1", Regexp: ` - (.+) \[`},
+		"group.year":          {Selector: "h1", Regexp: `\[(\d{4})`},
+		"group.artists":       {Selector: "td:contains('Artist') + td a"},
+		"group.tags":          {Selector: "td:contains('Tags') + td", Split: ","},
+	},
+}
+
+func newReplayAPI(t *testing.T) *API {
+	rt, err := replay.New(replay.ModeReplay, "testdata")
+	if err != nil {
+		t.Fatal(err)
+	}
+	w, err := NewAPI("https://books.test/", "arbitrage/test", testConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.SetTransport(rt)
+	if err := w.Login("user", "secret"); err != nil {
+		t.Fatal("login:", err)
+	}
+	return w
+}
+
+func TestParseTorrent(t *testing.T) {
+	w := newReplayAPI(t)
+
+	body, err := w.DoTorrent(4321)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := w.ParseTorrent(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if r.Torrent.ID != 4321 || r.Group.ID != 4321 || r.Group.Name != "Geogaddi" || r.Group.Year != 2002 {
+		t.Errorf("unexpected release: %+v", r.Group)
+	}
+	if r.Torrent.Format != "FLAC" || !bool(r.Torrent.HasLog) || !bool(r.Torrent.FreeTorrent) {
+		t.Errorf("unexpected torrent: %+v", r.Torrent)
+	}
+	if len(r.Group.MusicInfo.Artists) != 1 || r.Group.MusicInfo.Artists[0].Name != "Boards of Canada" {
+		t.Errorf("unexpected artists: %v", r.Group.MusicInfo.Artists)
+	}
+	if len(r.Group.Tags) != 2 || r.Group.Tags[1] != "idm" {
+		t.Errorf("unexpected tags: %v", r.Group.Tags)
+	}
+	want := "01 - Ready Lets Go.flac{{{1572864}}}|||02 - Music Is Math.flac{{{40108032}}}|||Geogaddi.log{{{4096}}}"
+	if string(r.Torrent.FileList) != want {
+		t.Errorf("unexpected filelist:\n%s\n%s", r.Torrent.FileList, want)
+	}
+
+	u, err := w.CreateDownloadURL(4321)
+	if err != nil || u != "https://books.test/download.php/5/4321.torrent" {
+		t.Errorf("unexpected download url %q: %v", u, err)
+	}
+}
+
+func TestNotFound(t *testing.T) {
+	w := newReplayAPI(t)
+
+	body, err := w.DoTorrent(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.ParseTorrent(body); err == nil {
+		t.Error("expected error for missing torrent")
+	}
+}
+
+func TestNewAPIInvalidRegexp(t *testing.T) {
+	cfg := testConfig
+	cfg.Login.Captures = map[string]Field{"uid": {Selector: "a", Regexp: "id=(\\d+"}}
+	if _, err := NewAPI("https://books.test/", "arbitrage/test", cfg); err == nil {
+		t.Error("expected error for invalid capture regexp")
+	}
+}
+
+func TestLoginRedirect(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/tracker/login.php" {
+			http.Redirect(w, r, "/", http.StatusFound)
+			return
+		}
+		w.Write([]byte("<html><body>Welcome</body></html>"))
+	}))
+	defer srv.Close()
+
+	// the final URL is shorter than the base URL
+	w, err := NewAPI(srv.URL+"/tracker/", "arbitrage/test", testConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Login("user", "secret"); err != errLoginFailed {
+		t.Errorf("expected login to fail, got %v", err)
+	}
+}
+
+func TestLoginInPlace(t *testing.T) {
+	rt, err := replay.New(replay.ModeReplay, "testdata")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// inplace.test renders the logged-in page at the login URL
+	w, err := NewAPI("https://inplace.test/", "arbitrage/test", testConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.SetTransport(rt)
+	if err := w.Login("user", "secret"); err != nil {
+		t.Fatal("login:", err)
+	}
+	if u, err := w.CreateDownloadURL(1); err != nil || u != "https://inplace.test/download.php/7/1.torrent" {
+		t.Errorf("unexpected download url %q: %v", u, err)
+	}
+
+	// without success selector, staying on the login page means failure
+	cfg := testConfig
+	cfg.Login.SuccessSelector = ""
+	cfg.Login.Captures = nil
+	w, err = NewAPI("https://inplace.test/", "arbitrage/test", cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.SetTransport(rt)
+	if err := w.Login("user", "secret"); err != errLoginFailed {
+		t.Errorf("expected login to fail, got %v", err)
+	}
+}
@@ -0,0 +1,97 @@
+package scraper
+
+// Config describes how to scrape a tracker that only offers HTML pages.
+// It is read from the per-source "scraper" section in config.toml, e.g. for
+// a waffles-like tracker:
+//
+//	[sources.wfl.scraper]
+//	details_url = "details.php?id={id}&filelist=1"
+//	download_url = "download.php/{uid}/{id}/name.torrent"
+//	valid_selector = "a[name=filelist]"
+//
+//	[sources.wfl.scraper.login]
+//	url = "login_check"
+//	username_field = "_username"
+//	password_field = "_password"
+//	success_selector = "span.hname"
+//
+//	[sources.wfl.scraper.login.captures.uid]
+//	selector = "span.hname a"
+//	attr = "href"
+//	param = "id"
+//
+//	[sources.wfl.scraper.filelist]
+//	rows = "tr:has(a[name=filelist]) table tr"
+//	skip_header = true
+//
+//	[sources.wfl.scraper.fields."torrent.id"]
+//	selector = "td:contains('Snatched') + td a"
+//	attr = "href"
+//	param = "id"
+//
+//	[sources.wfl.scraper.fields."group.name"]
+//	selector = "h1"
+//	regexp = ' - (.+) \['
+type Config struct {
+	Login Login `toml:"login"`
+
+	// DetailsURL and DownloadURL are relative to the source URL. They may
+	// contain the placeholder {id} for the torrent ID and any value captured
+	// during login, e.g. {uid}.
+	DetailsURL  string `toml:"details_url"`
+	DownloadURL string `toml:"download_url"`
+
+	// ValidSelector must match on every valid details page. If it does not,
+	// the torrent is considered as deleted or not found.
+	ValidSelector string `toml:"valid_selector,omitempty"`
+
+	FileList FileList `toml:"filelist"`
+
+	// Fields maps model fields to selectors on the details page. Keys are
+	// the JSON names of the model, prefixed with "torrent." or "group.",
+	// e.g. "torrent.format" or "group.year". The special key "group.artists"
+	// fills the main artists, "group.tags" the tags. Typed metadata of other
+	// categories can be set with nested keys, e.g. "group.bookInfo.isbn" or
+	// "group.videoInfo.resolution".
+	Fields map[string]Field `toml:"fields"`
+}
+
+type Login struct {
+	URL           string            `toml:"url"`
+	UsernameField string            `toml:"username_field"`
+	PasswordField string            `toml:"password_field"`
+	Extra         map[string]string `toml:"extra,omitempty"`
+
+	// SuccessSelector must match on the page after logging in
+	SuccessSelector string `toml:"success_selector,omitempty"`
+
+	// Captures extract values from the page after logging in, that can be
+	// used as placeholders in URLs.
+	Captures map[string]Field `toml:"captures,omitempty"`
+}
+
+type FileList struct {
+	// Rows selects one element per file
+	Rows       string `toml:"rows"`
+	SkipHeader bool   `toml:"skip_header,omitempty"`
+	// Name and Size select the columns within a row, defaults to the first
+	// and last child.
+	Name string `toml:"name,omitempty"`
+	Size string `toml:"size,omitempty"`
+}
+
+type Field struct {
+	Selector string `toml:"selector"`
+	// Attr reads an attribute instead of the text content
+	Attr string `toml:"attr,omitempty"`
+	// HTML reads the inner HTML instead of the text content
+	HTML bool `toml:"html,omitempty"`
+	// Param parses the value as URL and returns a query parameter
+	Param string `toml:"param,omitempty"`
+	// Regexp returns the first submatch of the expression
+	Regexp string `toml:"regexp,omitempty"`
+	// Split splits the value into a list, e.g. for tags
+	Split string `toml:"split,omitempty"`
+	// Map translates values, e.g. "Yes" => "1"
+	Map map[string]string `toml:"map,omitempty"`
+}
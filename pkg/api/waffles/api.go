@@ -14,13 +14,14 @@ import (
 	"strings"
 
 	"github.com/PuerkitoBio/goquery"
+	"github.com/emotionaldots/arbitrage/pkg/api/apierr"
 	"github.com/emotionaldots/arbitrage/pkg/model"
 )
 
 var (
 	errLoginFailed         = errors.New("Login failed")
 	errRequestFailed       = errors.New("Request failed")
-	errRequestFailedLogin  = errors.New("Request failed: not logged in")
+	errRequestFailedLogin  = apierr.New(apierr.LoggedOut, "Request failed: not logged in")
 	errRequestFailedReason = func(err string) error { return fmt.Errorf("Request failed: %s", err) }
 )
 
@@ -45,6 +46,12 @@ type API struct {
 	loggedIn  bool
 }
 
+// SetTransport replaces the HTTP transport of the API client, e.g. with a
+// record/replay transport for offline testing.
+func (w *API) SetTransport(rt http.RoundTripper) {
+	w.client.Transport = rt
+}
+
 func (w *API) Login(username, password string) error {
 	params := url.Values{}
 	params.Set("_username", username)
@@ -73,7 +80,7 @@ func (w *API) Login(username, password string) error {
 	}
 	uidString, ok := doc.Find("span.hname a").Attr("href")
 	if !ok {
-		return errors.New("Parsing failed: could not find uid field")
+		return apierr.New(apierr.ParseFailure, "Parsing failed: could not find uid field")
 	}
 	uidURL, err := url.Parse(uidString)
 	if err != nil {
@@ -81,7 +88,7 @@ func (w *API) Login(username, password string) error {
 	}
 	uid := uidURL.Query().Get("id")
 	if uid == "" {
-		return errors.New("Parsing failed: empty userid")
+		return apierr.New(apierr.ParseFailure, "Parsing failed: empty userid")
 	}
 
 	w.loggedIn = true
@@ -100,12 +107,12 @@ func (w *API) doRequest(requestURL string) ([]byte, error) {
 	}
 	resp, err := w.client.Do(req)
 	if err != nil {
-		return nil, err
+		return nil, apierr.Wrap(apierr.Network, err)
 	}
 
 	defer resp.Body.Close()
 	if resp.StatusCode != 200 {
-		return nil, errRequestFailedReason("Status Code " + resp.Status)
+		return nil, apierr.FromStatus(resp)
 	}
 
 	body, err := ioutil.ReadAll(resp.Body)
@@ -117,15 +124,20 @@ func (w *API) doRequest(requestURL string) ([]byte, error) {
 }
 
 func (w *API) DoTorrent(id int, params url.Values) ([]byte, error) {
-	params.Set("id", strconv.Itoa(id))
-	params.Set("filelist", "1")
-	requestURL, err := buildURL(w.baseURL, "details.php", "", params)
+	requestURL, err := w.TorrentURL(id, params)
 	if err != nil {
 		return nil, err
 	}
 	return w.doRequest(requestURL)
 }
 
+// TorrentURL returns the details page URL that is requested for a torrent.
+func (w *API) TorrentURL(id int, params url.Values) (string, error) {
+	params.Set("id", strconv.Itoa(id))
+	params.Set("filelist", "1")
+	return buildURL(w.baseURL, "details.php", "", params)
+}
+
 func buildURL(baseURL, path, action string, params url.Values) (string, error) {
 	u, err := url.Parse(baseURL)
 	if err != nil {
@@ -162,7 +174,7 @@ func (w *API) ParseTorrent(body []byte) (model.TorrentAndGroup, error) {
 	// Filelist
 	row := doc.Find("a[name=filelist]").Parent().Parent()
 	if row.Length() == 0 {
-		return r, errors.New("Parsing failed: no filelist found")
+		return r, apierr.New(apierr.NotFound, "Parsing failed: no filelist found")
 	}
 
 	files := ""
@@ -179,7 +191,7 @@ func (w *API) ParseTorrent(body []byte) (model.TorrentAndGroup, error) {
 	if err != nil {
 		return r, err
 	}
-	r.Torrent.FileList = files
+	r.Torrent.FileList = model.FlexString(files)
 
 	mainTable := row.Closest("tbody")
 	tbl := tabular(mainTable)
@@ -187,27 +199,28 @@ func (w *API) ParseTorrent(body []byte) (model.TorrentAndGroup, error) {
 	// Torrent ID
 	snatchedField, ok := tbl["Snatched"]
 	if !ok {
-		return r, errors.New("Parsing failed: snatched not found")
+		return r, apierr.New(apierr.ParseFailure, "Parsing failed: snatched not found")
 	}
 	snatchedUrl, _ := snatchedField.Find("a").Attr("href")
 	u, err := url.Parse(snatchedUrl)
 	if err != nil {
-		return r, errors.New("Parsing failed: snatched not found: " + err.Error())
+		return r, apierr.New(apierr.ParseFailure, "Parsing failed: snatched not found: "+err.Error())
 	}
-	r.Torrent.ID, err = strconv.Atoi(u.Query().Get("id"))
+	id, err := strconv.Atoi(u.Query().Get("id"))
 	if err != nil {
-		return r, errors.New("Parsing failed: no id found")
+		return r, apierr.New(apierr.ParseFailure, "Parsing failed: no id found")
 	}
+	r.Torrent.ID = model.FlexInt(id)
 	r.Group.ID = r.Torrent.ID
 
 	// Artist
 	artistField, ok := tbl["Artist"]
 	if !ok {
-		return r, errors.New("Parsing failed: no artist found")
+		return r, apierr.New(apierr.ParseFailure, "Parsing failed: no artist found")
 	}
 	artistText := artistField.Find("a").Text()
 	r.Group.MusicInfo.Artists = append(r.Group.MusicInfo.Artists, model.ArtistLink{
-		Name: artistText,
+		Name: model.FlexString(artistText),
 	})
 
 	// Description
@@ -215,12 +228,12 @@ func (w *API) ParseTorrent(body []byte) (model.TorrentAndGroup, error) {
 	if err != nil {
 		return r, err
 	}
-	r.Group.WikiBody = desc
+	r.Group.WikiBody = model.FlexString(desc)
 
 	// Torrent Info
 	title := mainTable.Parent().PrevAllFiltered("h1").Text()
 	if title == "" {
-		return r, errors.New("Parsing failed: torrent title not found")
+		return r, apierr.New(apierr.ParseFailure, "Parsing failed: torrent title not found")
 	}
 	if err := ParseTitle(artistText, title, &r); err != nil {
 		return r, err
@@ -236,7 +249,7 @@ var reTitle = regexp.MustCompile(`(.+) \[([^\]]+)\]$`)
 
 func ParseTitle(artist, title string, r *model.TorrentAndGroup) error {
 	if title == artist {
-		r.Group.Name = title
+		r.Group.Name = model.FlexString(title)
 		return nil
 	}
 	if strings.HasPrefix(title, artist+" - ") {
@@ -253,26 +266,26 @@ func ParseTitle(artist, title string, r *model.TorrentAndGroup) error {
 	}
 	matches := reTitle.FindStringSubmatch(title)
 	if matches == nil {
-		r.Group.Name = title
+		r.Group.Name = model.FlexString(title)
 		return nil
 	}
 
-	r.Group.Name = matches[1]
+	r.Group.Name = model.FlexString(matches[1])
 	tags := strings.Split(matches[2], "/")
 	for _, tag := range tags {
 		switch {
 		case tag == "MP3" || tag == "FLAC" || tag == "DTS" || tag == "AC3":
-			r.Torrent.Format = tag
+			r.Torrent.Format = model.FlexString(tag)
 		case tag == "CD" || tag == "Vinyl" || tag == "Cassette" || tag == "Web":
-			r.Torrent.Media = tag
+			r.Torrent.Media = model.FlexString(tag)
 		case tag == "Log":
 			r.Torrent.HasLog = true
 		case isEncoding(tag):
-			r.Torrent.Encoding = tag
+			r.Torrent.Encoding = model.FlexString(tag)
 		case len(tag) == 4:
 			year, err := strconv.Atoi(tag)
 			if err == nil {
-				r.Group.Year = year
+				r.Group.Year = model.FlexInt(year)
 			}
 		}
 	}
@@ -0,0 +1,46 @@
+package waffles
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/emotionaldots/arbitrage/pkg/api/replay"
+)
+
+func TestParseTorrent(t *testing.T) {
+	rt, err := replay.New(replay.ModeReplay, "testdata")
+	if err != nil {
+		t.Fatal(err)
+	}
+	w, err := NewAPI("https://waffles.test/", "arbitrage/test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.SetTransport(rt)
+	if err := w.Login("user", "secret"); err != nil {
+		t.Fatal("login:", err)
+	}
+
+	body, err := w.DoTorrent(4321, url.Values{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := w.ParseTorrent(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if r.Torrent.ID != 4321 || r.Group.Name != "Geogaddi" || r.Group.Year != 2002 {
+		t.Errorf("unexpected release: %+v", r.Group)
+	}
+	if r.Torrent.Format != "FLAC" || r.Torrent.Media != "CD" || r.Torrent.Encoding != "Lossless" || !r.Torrent.HasLog {
+		t.Errorf("unexpected torrent: %+v", r.Torrent)
+	}
+	if len(r.Group.MusicInfo.Artists) != 1 || r.Group.MusicInfo.Artists[0].Name != "Boards of Canada" {
+		t.Errorf("unexpected artists: %v", r.Group.MusicInfo.Artists)
+	}
+	want := "01 - Ready Lets Go.flac{{{1572864}}}|||02 - Music Is Math.flac{{{40108032}}}|||Geogaddi.log{{{4096}}}"
+	if string(r.Torrent.FileList) != want {
+		t.Errorf("unexpected filelist:\n%s\n%s", r.Torrent.FileList, want)
+	}
+}
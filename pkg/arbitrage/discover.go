@@ -0,0 +1,164 @@
+// Author: EmotionalDots @ PTH
+//
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package arbitrage
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Files that make a directory a release: audio, video and e-books
+var reMedia = regexp.MustCompile(`(?i)\.(flac|mp3|m4a|aac|ogg|opus|wav|aiff?|ape|wv|dsf|dff|mkv|avi|mp4|m4v|wmv|ts|m2ts|iso|epub|mobi|azw3?|pdf|djvu|cbz|cbr|m4b)$`)
+
+// Subdirectories of a multi-disc release, e.g. "CD1", "Disc 2", "DVD-1"
+var reDisc = regexp.MustCompile(`(?i)^(cd|disc|disk|dvd|side|vinyl|lp)[ ._-]*\d+\b`)
+
+// DiscoverOptions configures the release discovery.
+type DiscoverOptions struct {
+	// Include and Exclude are glob patterns that are matched against both
+	// the base name and the path relative to the scanned directory.
+	// Excluded directories are not descended into.
+	Include []string
+	Exclude []string
+
+	// SingleFiles treats loose media files in the scanned directory as
+	// single-file releases instead of a single release. Loose files next to
+	// other releases are always single-file releases.
+	SingleFiles bool
+}
+
+func (opts DiscoverOptions) match(patterns []string, rel string) bool {
+	for _, p := range patterns {
+		if ok, _ := filepath.Match(p, filepath.Base(rel)); ok {
+			return true
+		}
+		if ok, _ := filepath.Match(p, rel); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (opts DiscoverOptions) included(rel string) bool {
+	if len(opts.Include) > 0 && !opts.match(opts.Include, rel) {
+		return false
+	}
+	return !opts.match(opts.Exclude, rel)
+}
+
+// IsMediaFile returns whether a file is an audio, video or e-book file.
+func IsMediaFile(name string) bool {
+	return reMedia.MatchString(name)
+}
+
+// FindReleases walks a library and returns the paths of all release roots,
+// at any depth. Libraries may be laid out as "Artist/Album/",
+// "Label/Year/Album/" or flat. A directory is a release if it holds media
+// files or multi-disc subdirectories ("CD1", "CD2") with media files.
+// Loose media files next to other releases are single-file releases.
+func FindReleases(root string, opts DiscoverOptions) ([]string, error) {
+	fi, err := os.Stat(root)
+	if err != nil {
+		return nil, err
+	}
+	if !fi.IsDir() {
+		return []string{root}, nil
+	}
+
+	releases := make([]string, 0)
+	err = discover(root, root, opts, &releases)
+	return releases, err
+}
+
+type dirInfo struct {
+	media   []string
+	discs   int
+	subdirs []string
+}
+
+func scanDir(dir string) (dirInfo, error) {
+	info := dirInfo{}
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return info, err
+	}
+	for _, e := range entries {
+		if strings.HasPrefix(e.Name(), ".") {
+			continue
+		}
+		path := filepath.Join(dir, e.Name())
+		if e.IsDir() {
+			info.subdirs = append(info.subdirs, path)
+			if reDisc.MatchString(e.Name()) && containsMedia(path) {
+				info.discs++
+			}
+		} else if IsMediaFile(e.Name()) {
+			info.media = append(info.media, path)
+		}
+	}
+	return info, nil
+}
+
+// containsMedia returns whether a directory holds media files at any depth.
+func containsMedia(dir string) bool {
+	found := false
+	filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || found {
+			return filepath.SkipDir
+		}
+		if !info.IsDir() && IsMediaFile(info.Name()) {
+			found = true
+		}
+		return nil
+	})
+	return found
+}
+
+func discover(root, dir string, opts DiscoverOptions, releases *[]string) error {
+	rel, _ := filepath.Rel(root, dir)
+	if dir != root && opts.match(opts.Exclude, rel) {
+		return nil
+	}
+
+	info, err := scanDir(dir)
+	if err != nil {
+		return err
+	}
+
+	// A directory of media files is a release, unless it also holds other
+	// releases, e.g. a download directory with loose files
+	nested := false
+	if len(info.media) > 0 || info.discs > 0 {
+		for _, sub := range info.subdirs {
+			if !reDisc.MatchString(filepath.Base(sub)) && containsMedia(sub) {
+				nested = true
+				break
+			}
+		}
+		if !nested && !(opts.SingleFiles && dir == root && info.discs == 0) {
+			if opts.included(rel) {
+				*releases = append(*releases, dir)
+			}
+			return nil
+		}
+	}
+
+	for _, f := range info.media {
+		frel, _ := filepath.Rel(root, f)
+		if opts.included(frel) {
+			*releases = append(*releases, f)
+		}
+	}
+	for _, sub := range info.subdirs {
+		if err := discover(root, sub, opts, releases); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package arbitrage
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func writeFiles(t *testing.T, root string, files []string) {
+	for _, f := range files {
+		path := filepath.Join(root, f)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(f), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestFindReleases(t *testing.T) {
+	root, err := ioutil.TempDir("", "arbitrage-discover")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	writeFiles(t, root, []string{
+		"Artist/Album (2001)/01 - Intro.flac",
+		"Artist/Album (2001)/Scans/front.jpg",
+		"Artist/Live Album/01 - Live.flac",
+		"Label/2010/Double Album/CD1/01 - One.flac",
+		"Label/2010/Double Album/CD2/01 - Two.flac",
+		"Label/2010/Double Album/folder.jpg",
+		"Movie.2010.1080p.BluRay.x264.mkv",
+		"Notes/readme.txt",
+	})
+
+	found, err := FindReleases(root, DiscoverOptions{Exclude: []string{"*Live*"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		filepath.Join(root, "Movie.2010.1080p.BluRay.x264.mkv"),
+		filepath.Join(root, "Artist/Album (2001)"),
+		filepath.Join(root, "Label/2010/Double Album"),
+	}
+	if !reflect.DeepEqual(found, expected) {
+		t.Errorf("Unexpected releases:\n%v\nexpected\n%v", found, expected)
+	}
+
+	r, err := FromFile(expected[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.FilePath != "Movie.2010.1080p.BluRay.x264.mkv" || r.FileList[0].Name != r.FilePath {
+		t.Errorf("Unexpected single-file release: %+v", r)
+	}
+}
+
+func TestFindReleasesSingleFiles(t *testing.T) {
+	root, err := ioutil.TempDir("", "arbitrage-discover")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	writeFiles(t, root, []string{
+		"Artist/Album (2001)/01 - Intro.flac",
+		"Artist/Album (2001)/02 - Outro.flac",
+		"Double Album/CD1/01 - One.flac",
+		"Double Album/CD2/01 - Two.flac",
+		"Loose/01 - Single.flac",
+		"Loose/02 - B-Side.flac",
+	})
+	opts := DiscoverOptions{SingleFiles: true}
+
+	// albums are never split into single files
+	found, err := FindReleases(root, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		filepath.Join(root, "Artist/Album (2001)"),
+		filepath.Join(root, "Double Album"),
+		filepath.Join(root, "Loose"),
+	}
+	if !reflect.DeepEqual(found, expected) {
+		t.Errorf("Unexpected releases:\n%v\nexpected\n%v", found, expected)
+	}
+
+	// loose files in the scanned directory are
+	found, err = FindReleases(filepath.Join(root, "Loose"), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = []string{
+		filepath.Join(root, "Loose/01 - Single.flac"),
+		filepath.Join(root, "Loose/02 - B-Side.flac"),
+	}
+	if !reflect.DeepEqual(found, expected) {
+		t.Errorf("Unexpected releases:\n%v\nexpected\n%v", found, expected)
+	}
+
+	found, err = FindReleases(filepath.Join(root, "Loose"), DiscoverOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(found) != 1 || found[0] != filepath.Join(root, "Loose") {
+		t.Errorf("Unexpected releases without -single: %v", found)
+	}
+}
@@ -5,7 +5,11 @@
 
 package arbitrage
 
-import "time"
+import (
+	"fmt"
+	"strings"
+	"time"
+)
 
 type Info struct {
 	Version     int                    `yaml:"version"`
@@ -16,6 +20,7 @@ type Info struct {
 
 type InfoRelease struct {
 	TorrentId int    `yaml:"torrent_id"`
+	Category  string `yaml:"category,omitempty"`
 	Format    string `yaml:"format"`
 	FilePath  string `yaml:"file_path"`
 
@@ -26,17 +31,37 @@ type InfoRelease struct {
 	Edition         string `yaml:"edition"`
 
 	Composers []string `yaml:"composers,omitempty,flow"`
-	Artists   []string `yaml:"artists"`
+	Artists   []string `yaml:"artists,omitempty"`
 	With      []string `yaml:"with,omitempty,flow"`
 	DJ        []string `yaml:"dj,omitempty,flow"`
 	RemixedBy []string `yaml:"remixed_by,omitempty,flow"`
 	Producer  []string `yaml:"producer,omitempty,flow"`
 
+	Book  *InfoBook  `yaml:"book,omitempty"`
+	Video *InfoVideo `yaml:"video,omitempty"`
+
 	Tags        []string `yaml:"tags,flow"`
 	Description string   `yaml:"description,omitempty"`
 	Image       string   `yaml:"image,omitempty"`
 }
 
+type InfoBook struct {
+	Authors   []string `yaml:"authors,flow"`
+	ISBN      string   `yaml:"isbn,omitempty"`
+	Publisher string   `yaml:"publisher,omitempty"`
+	Language  string   `yaml:"language,omitempty"`
+	Pages     int      `yaml:"pages,omitempty"`
+}
+
+type InfoVideo struct {
+	Resolution string `yaml:"resolution,omitempty"`
+	Codec      string `yaml:"codec,omitempty"`
+	Source     string `yaml:"source,omitempty"`
+	Container  string `yaml:"container,omitempty"`
+	Season     int    `yaml:"season,omitempty"`
+	Episode    int    `yaml:"episode,omitempty"`
+}
+
 func concat(s, del, extra, pre, suf string) string {
 	if extra == "" {
 		return s
@@ -49,7 +74,11 @@ func concat(s, del, extra, pre, suf string) string {
 
 func (i InfoRelease) String() string {
 	str := ""
-	if len(i.Artists) == 1 {
+	if i.Book != nil && len(i.Book.Authors) > 0 {
+		str = strings.Join(i.Book.Authors, ", ")
+	} else if i.Video != nil {
+		// videos are named by their title only
+	} else if len(i.Artists) == 1 {
 		str = i.Artists[0]
 	} else if len(i.Artists) > 1 {
 		str = "Various Artists"
@@ -58,6 +87,9 @@ func (i InfoRelease) String() string {
 	}
 
 	str = concat(str, " - ", i.Name, "", "")
+	if i.Video != nil && i.Video.Season > 0 {
+		str = concat(str, " ", fmt.Sprintf("S%02dE%02d", i.Video.Season, i.Video.Episode), "", "")
+	}
 	str = concat(str, " ", i.Format, "[", "]")
 	str = concat(str, " ", i.CatalogueNumber, "{", "}")
 	return str
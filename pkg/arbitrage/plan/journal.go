@@ -0,0 +1,260 @@
+// Author: EmotionalDots @ PTH
+//
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package plan
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Journal records the actions of a single "apply" run. It is saved after
+// every action, so that even an interrupted run can be reverted.
+type Journal struct {
+	Plan     string    `json:"plan"`
+	Started  time.Time `json:"started"`
+	Finished time.Time `json:"finished,omitempty"`
+	Entries  []Entry   `json:"entries"`
+
+	path string
+}
+
+type Entry struct {
+	Action
+	Started time.Time `json:"started,omitempty"`
+	Applied time.Time `json:"applied,omitempty"`
+	Undone  time.Time `json:"undone,omitempty"`
+	Error   string    `json:"error,omitempty"`
+}
+
+// NewJournal creates a journal for a plan in a journal directory.
+func NewJournal(dir, plan string) (*Journal, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	j := &Journal{
+		Plan:    plan,
+		Started: now,
+		Entries: make([]Entry, 0),
+		path:    filepath.Join(dir, now.Format("20060102-150405.000")+".json"),
+	}
+	return j, j.Save()
+}
+
+func LoadJournal(path string) (*Journal, error) {
+	j := &Journal{path: path}
+	return j, readJSON(path, j)
+}
+
+// LatestJournal returns the path of the most recent journal in a directory.
+func LatestJournal(dir string) (string, error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return "", err
+	}
+	names := make([]string, 0)
+	for _, f := range files {
+		if !f.IsDir() && strings.HasSuffix(f.Name(), ".json") {
+			names = append(names, f.Name())
+		}
+	}
+	if len(names) == 0 {
+		return "", fmt.Errorf("no journals found in %s", dir)
+	}
+	sort.Strings(names)
+	return filepath.Join(dir, names[len(names)-1]), nil
+}
+
+func (j *Journal) Path() string {
+	return j.path
+}
+
+func (j *Journal) Save() error {
+	return writeJSON(j.path, j)
+}
+
+// Apply checks a plan and executes its actions in order, recording each one
+// in the journal. Nothing is executed if the check fails. Execution stops at
+// the first failing action.
+// Each action is recorded as started before it is executed, so that Undo
+// can also clean up after runs that were interrupted.
+func Apply(p *Plan, j *Journal) error {
+	if errs := p.Check(); len(errs) > 0 {
+		return fmt.Errorf("plan check failed with %d errors, first: %s", len(errs), errs[0])
+	}
+
+	for _, a := range p.Actions {
+		// plans written by hand or by older versions may be relative to
+		// the working directory, which may differ when undoing
+		a, err := a.Abs()
+		if err != nil {
+			return err
+		}
+		j.Entries = append(j.Entries, Entry{Action: a, Started: time.Now()})
+		if err := j.Save(); err != nil {
+			return err
+		}
+		e := &j.Entries[len(j.Entries)-1]
+		err = apply(a)
+		if err != nil {
+			e.Error = err.Error()
+		} else {
+			e.Applied = time.Now()
+		}
+		if serr := j.Save(); serr != nil {
+			return serr
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %s", a, err)
+		}
+	}
+	j.Finished = time.Now()
+	return j.Save()
+}
+
+func apply(a Action) error {
+	// checked again right before each action, the plan check might be stale
+	if exists(a.Target) {
+		return fmt.Errorf("target already exists")
+	}
+
+	switch a.Op {
+	case OpRename:
+		return os.Rename(a.Source, a.Target)
+	case OpSymlink:
+		src, err := filepath.Abs(a.Source)
+		if err != nil {
+			return err
+		}
+		return os.Symlink(src, a.Target)
+	case OpHardlink:
+		return copyTree(a.Source, a.Target, os.Link)
+	case OpReflink:
+		return copyTree(a.Source, a.Target, reflink)
+	}
+	return fmt.Errorf("unknown op %q", a.Op)
+}
+
+// copyTree recreates the directory structure of src at dst and links every
+// file with the given function. If linking fails, the partial copy is
+// removed again, dst must not exist before.
+func copyTree(src, dst string, link func(src, dst string) error) error {
+	err := filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		if info.IsDir() {
+			return os.Mkdir(target, info.Mode().Perm())
+		}
+		return link(path, target)
+	})
+	if err != nil && exists(dst) {
+		if rerr := os.RemoveAll(dst); rerr != nil {
+			return fmt.Errorf("%s, could not remove partial target: %s", err, rerr)
+		}
+	}
+	return err
+}
+
+// Undo reverts all applied actions of a journal in reverse order, as well
+// as partial targets of failed or interrupted actions. Targets that were
+// modified since are left alone and reported as errors.
+func Undo(j *Journal) []error {
+	errs := make([]error, 0)
+	for i := len(j.Entries) - 1; i >= 0; i-- {
+		e := &j.Entries[i]
+		if !e.Undone.IsZero() || (e.Applied.IsZero() && !e.partial()) {
+			continue
+		}
+		if err := undo(e.Action); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %s", e.Action, err))
+			continue
+		}
+		e.Undone = time.Now()
+		if err := j.Save(); err != nil {
+			return append(errs, err)
+		}
+	}
+	return errs
+}
+
+// partial returns whether an action that did not finish may have left its
+// target behind: a partial link tree, or the result of an atomic rename or
+// symlink if the run was interrupted right after it.
+func (e Entry) partial() bool {
+	if e.Started.IsZero() || !e.Applied.IsZero() || !exists(e.Target) {
+		return false
+	}
+	switch e.Op {
+	case OpHardlink, OpReflink:
+		return true
+	}
+	return e.Error == ""
+}
+
+func undo(a Action) error {
+	switch a.Op {
+	case OpRename:
+		if exists(a.Source) {
+			return fmt.Errorf("source %q exists again", a.Source)
+		}
+		if !exists(a.Target) {
+			return fmt.Errorf("target %q is gone", a.Target)
+		}
+		return os.Rename(a.Target, a.Source)
+	case OpSymlink:
+		dest, err := os.Readlink(a.Target)
+		if err != nil {
+			return err
+		}
+		src, _ := filepath.Abs(a.Source)
+		if dest != src {
+			return fmt.Errorf("symlink %q was changed", a.Target)
+		}
+		return os.Remove(a.Target)
+	case OpHardlink, OpReflink:
+		if err := compareTree(a.Source, a.Target, a.Op == OpHardlink); err != nil {
+			return err
+		}
+		return os.RemoveAll(a.Target)
+	}
+	return fmt.Errorf("unknown op %q", a.Op)
+}
+
+// compareTree verifies that every file in dst still corresponds to a file
+// of the same size (or the same inode, for hardlinks) in src.
+func compareTree(src, dst string, sameFile bool) error {
+	return filepath.Walk(dst, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		rel, _ := filepath.Rel(dst, path)
+		orig, err := os.Lstat(filepath.Join(src, rel))
+		if err != nil {
+			return fmt.Errorf("%q has no counterpart in source, refusing to remove", path)
+		}
+		if sameFile && !os.SameFile(orig, info) {
+			return fmt.Errorf("%q is no longer a hardlink of the source", path)
+		}
+		if orig.Size() != info.Size() {
+			return fmt.Errorf("%q was modified", path)
+		}
+		return nil
+	})
+}
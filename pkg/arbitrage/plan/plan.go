@@ -0,0 +1,166 @@
+// Author: EmotionalDots @ PTH
+//
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+// Package plan executes file operations planned by "downthemall" (renames
+// and links of local releases to the names of matching torrents) and keeps
+// a journal so that every run can be reverted.
+package plan
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	OpRename   = "rename"
+	OpHardlink = "hardlink"
+	OpSymlink  = "symlink"
+	OpReflink  = "reflink"
+)
+
+var Ops = []string{OpRename, OpHardlink, OpSymlink, OpReflink}
+
+var errReflinkUnsupported = errors.New("reflinks are not supported on this platform or filesystem")
+
+// Action moves or links a local release (Source) to the name expected by a
+// torrent (Target).
+type Action struct {
+	Op      string `json:"op"`
+	Source  string `json:"source"`
+	Target  string `json:"target"`
+	Torrent string `json:"torrent,omitempty"`
+}
+
+func (a Action) String() string {
+	return fmt.Sprintf("%s %q -> %q", a.Op, a.Source, a.Target)
+}
+
+// Abs returns the action with absolute source and target paths, so that it
+// can be undone from any working directory.
+func (a Action) Abs() (Action, error) {
+	var err error
+	if a.Source, err = filepath.Abs(a.Source); err != nil {
+		return a, err
+	}
+	a.Target, err = filepath.Abs(a.Target)
+	return a, err
+}
+
+type Plan struct {
+	Created time.Time `json:"created"`
+	Actions []Action  `json:"actions"`
+}
+
+func readJSON(path string, v interface{}) error {
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(raw, v); err != nil {
+		return fmt.Errorf("%s: %s", path, err)
+	}
+	return nil
+}
+
+// writeJSON atomically replaces a file.
+func writeJSON(path string, v interface{}) error {
+	raw, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, raw, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// Load reads a plan file, or returns an empty plan if it does not exist.
+func Load(path string) (*Plan, error) {
+	p := &Plan{Created: time.Now()}
+	if err := readJSON(path, p); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (p *Plan) Save(path string) error {
+	return writeJSON(path, p)
+}
+
+// Add appends an action with absolute paths, unless the same target is
+// already planned.
+func (p *Plan) Add(a Action) bool {
+	if abs, err := a.Abs(); err == nil {
+		a = abs
+	}
+	for _, other := range p.Actions {
+		if other.Target == a.Target {
+			return false
+		}
+	}
+	p.Actions = append(p.Actions, a)
+	return true
+}
+
+func exists(path string) bool {
+	_, err := os.Lstat(path)
+	return err == nil || !os.IsNotExist(err)
+}
+
+// Check validates a plan without modifying anything: all sources must exist,
+// no target may exist or be planned twice, and every target directory must
+// exist.
+func (p *Plan) Check() []error {
+	errs := make([]error, 0)
+	targets := make(map[string]bool)
+	renamed := make(map[string]bool)
+
+	for i, a := range p.Actions {
+		fail := func(format string, args ...interface{}) {
+			errs = append(errs, fmt.Errorf("action %d (%s): %s", i+1, a, fmt.Sprintf(format, args...)))
+		}
+
+		switch a.Op {
+		case OpRename, OpHardlink, OpSymlink, OpReflink:
+		default:
+			fail("unknown op %q, expected one of %v", a.Op, Ops)
+			continue
+		}
+		if a.Source == "" || a.Target == "" {
+			fail("empty source or target")
+			continue
+		}
+
+		src, tgt := filepath.Clean(a.Source), filepath.Clean(a.Target)
+		if src == tgt {
+			fail("source and target are the same")
+		}
+		if renamed[src] {
+			fail("source was already renamed by an earlier action")
+		} else if !exists(src) {
+			fail("source does not exist")
+		}
+		if targets[tgt] {
+			fail("target is planned more than once")
+		} else if exists(tgt) {
+			fail("target already exists")
+		}
+		if fi, err := os.Stat(filepath.Dir(tgt)); err != nil || !fi.IsDir() {
+			fail("target directory does not exist")
+		}
+
+		targets[tgt] = true
+		if a.Op == OpRename {
+			renamed[src] = true
+		}
+	}
+	return errs
+}
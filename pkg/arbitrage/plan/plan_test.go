@@ -0,0 +1,244 @@
+package plan
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeFile(t *testing.T, path, content string) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestApplyUndo(t *testing.T) {
+	dir, err := ioutil.TempDir("", "arbitrage-plan")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	writeFile(t, filepath.Join(dir, "Album/01.flac"), "one")
+	writeFile(t, filepath.Join(dir, "Other/01.flac"), "two")
+	writeFile(t, filepath.Join(dir, "Third/01.flac"), "three")
+
+	p := &Plan{}
+	p.Add(Action{Op: OpRename, Source: filepath.Join(dir, "Album"), Target: filepath.Join(dir, "Album [FLAC]")})
+	p.Add(Action{Op: OpHardlink, Source: filepath.Join(dir, "Other"), Target: filepath.Join(dir, "Other [FLAC]")})
+	p.Add(Action{Op: OpSymlink, Source: filepath.Join(dir, "Third"), Target: filepath.Join(dir, "Third [FLAC]")})
+
+	j, err := NewJournal(filepath.Join(dir, "journal"), "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Apply(p, j); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"Album [FLAC]/01.flac", "Other [FLAC]/01.flac", "Third [FLAC]/01.flac", "Other/01.flac"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Errorf("Expected %s after apply: %s", name, err)
+		}
+	}
+
+	latest, err := LatestJournal(filepath.Join(dir, "journal"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadJournal(latest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if errs := Undo(loaded); len(errs) > 0 {
+		t.Fatal(errs)
+	}
+	for _, name := range []string{"Album [FLAC]", "Other [FLAC]", "Third [FLAC]"} {
+		if _, err := os.Lstat(filepath.Join(dir, name)); !os.IsNotExist(err) {
+			t.Errorf("Expected %s to be removed by undo", name)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "Album/01.flac")); err != nil {
+		t.Errorf("Expected rename to be reverted: %s", err)
+	}
+}
+
+func TestCheckRefusesOverwrite(t *testing.T) {
+	dir, err := ioutil.TempDir("", "arbitrage-plan")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	writeFile(t, filepath.Join(dir, "A/01.flac"), "a")
+	writeFile(t, filepath.Join(dir, "B/01.flac"), "b")
+
+	p := &Plan{Actions: []Action{
+		{Op: OpRename, Source: filepath.Join(dir, "A"), Target: filepath.Join(dir, "B")},
+		{Op: OpRename, Source: filepath.Join(dir, "Missing"), Target: filepath.Join(dir, "C")},
+		{Op: "copy", Source: filepath.Join(dir, "A"), Target: filepath.Join(dir, "D")},
+	}}
+	if errs := p.Check(); len(errs) != 3 {
+		t.Errorf("Expected 3 errors, got %v", errs)
+	}
+
+	j, err := NewJournal(filepath.Join(dir, "journal"), "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Apply(p, j); err == nil {
+		t.Error("Expected apply to refuse the plan")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "A/01.flac")); err != nil {
+		t.Error("Expected nothing to be modified")
+	}
+}
+
+func TestCopyTreeRemovesPartialTarget(t *testing.T) {
+	dir, err := ioutil.TempDir("", "arbitrage-plan")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	writeFile(t, filepath.Join(dir, "Album/01.flac"), "one")
+	writeFile(t, filepath.Join(dir, "Album/CD2/02.flac"), "two")
+
+	linked := 0
+	failing := func(src, dst string) error {
+		if linked++; linked > 1 {
+			return errors.New("no space left on device")
+		}
+		return os.Link(src, dst)
+	}
+	target := filepath.Join(dir, "Album [FLAC]")
+	if err := copyTree(filepath.Join(dir, "Album"), target, failing); err == nil {
+		t.Fatal("Expected link error")
+	}
+	if _, err := os.Lstat(target); !os.IsNotExist(err) {
+		t.Errorf("Expected partial target to be removed: %v", err)
+	}
+}
+
+func TestUndoInterrupted(t *testing.T) {
+	dir, err := ioutil.TempDir("", "arbitrage-plan")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	writeFile(t, filepath.Join(dir, "Album/01.flac"), "one")
+	writeFile(t, filepath.Join(dir, "Album/02.flac"), "two")
+	writeFile(t, filepath.Join(dir, "Other/01.flac"), "three")
+
+	// the run was killed while linking Album, after renaming Other
+	target := filepath.Join(dir, "Album [FLAC]")
+	if err := os.Mkdir(target, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Link(filepath.Join(dir, "Album/01.flac"), filepath.Join(target, "01.flac")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(filepath.Join(dir, "Other"), filepath.Join(dir, "Other [FLAC]")); err != nil {
+		t.Fatal(err)
+	}
+
+	j, err := NewJournal(filepath.Join(dir, "journal"), "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	j.Entries = []Entry{
+		{Action: Action{Op: OpRename, Source: filepath.Join(dir, "Other"), Target: filepath.Join(dir, "Other [FLAC]")}, Started: now},
+		{Action: Action{Op: OpHardlink, Source: filepath.Join(dir, "Album"), Target: target}, Started: now},
+		{Action: Action{Op: OpSymlink, Source: filepath.Join(dir, "Album"), Target: filepath.Join(dir, "Never")}, Started: now, Error: "failed"},
+	}
+	if errs := Undo(j); len(errs) > 0 {
+		t.Fatal(errs)
+	}
+	if _, err := os.Lstat(target); !os.IsNotExist(err) {
+		t.Errorf("Expected partial target to be removed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "Other/01.flac")); err != nil {
+		t.Errorf("Expected interrupted rename to be reverted: %s", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "Album/02.flac")); err != nil {
+		t.Errorf("Expected source to be kept: %s", err)
+	}
+	if !j.Entries[2].Undone.IsZero() {
+		t.Error("Expected failed action without target to be skipped")
+	}
+}
+
+func TestUndoFromOtherDirectory(t *testing.T) {
+	dir, err := ioutil.TempDir("", "arbitrage-plan")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	// resolve symlinked temp dirs, Getwd returns the real path
+	if dir, err = filepath.EvalSymlinks(dir); err != nil {
+		t.Fatal(err)
+	}
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(cwd)
+
+	writeFile(t, filepath.Join(dir, "music/Album/01.flac"), "one")
+	writeFile(t, filepath.Join(dir, "music/Third/01.flac"), "three")
+	if err := os.Mkdir(filepath.Join(dir, "elsewhere"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	// as planned by "arbitrage downthemall red ." in the music directory
+	if err := os.Chdir(filepath.Join(dir, "music")); err != nil {
+		t.Fatal(err)
+	}
+	p := &Plan{}
+	p.Add(Action{Op: OpRename, Source: "Album", Target: "Album [FLAC]"})
+	if a := p.Actions[0]; a.Source != filepath.Join(dir, "music/Album") {
+		t.Errorf("expected absolute source in plan, got %q", a.Source)
+	}
+	// actions of older plans are still relative
+	p.Actions = append(p.Actions, Action{Op: OpSymlink, Source: "Third", Target: "Third [FLAC]"})
+
+	j, err := NewJournal(filepath.Join(dir, "journal"), "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Apply(p, j); err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range j.Entries {
+		if !filepath.IsAbs(e.Source) || !filepath.IsAbs(e.Target) {
+			t.Errorf("expected absolute paths in journal, got %s", e.Action)
+		}
+	}
+
+	if err := os.Chdir(filepath.Join(dir, "elsewhere")); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadJournal(j.Path())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if errs := Undo(loaded); len(errs) > 0 {
+		t.Fatal(errs)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "music/Album/01.flac")); err != nil {
+		t.Errorf("expected rename to be reverted: %s", err)
+	}
+	if _, err := os.Lstat(filepath.Join(dir, "music/Third [FLAC]")); !os.IsNotExist(err) {
+		t.Error("expected symlink to be removed by undo")
+	}
+	if files, _ := ioutil.ReadDir(filepath.Join(dir, "elsewhere")); len(files) != 0 {
+		t.Errorf("undo touched the working directory: %v", files)
+	}
+}
@@ -0,0 +1,43 @@
+// Author: EmotionalDots @ PTH
+//
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package plan
+
+import (
+	"os"
+	"syscall"
+)
+
+// FICLONE ioctl, see ioctl_ficlone(2)
+const ficlone = 0x40049409
+
+// reflink creates a copy-on-write clone of a file (btrfs, xfs).
+func reflink(src, dst string) error {
+	s, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	fi, err := s.Stat()
+	if err != nil {
+		return err
+	}
+	d, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, fi.Mode().Perm())
+	if err != nil {
+		return err
+	}
+
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, d.Fd(), ficlone, s.Fd())
+	d.Close()
+	if errno != 0 {
+		os.Remove(dst)
+		if errno == syscall.EOPNOTSUPP || errno == syscall.EXDEV || errno == syscall.EINVAL {
+			return errReflinkUnsupported
+		}
+		return errno
+	}
+	return nil
+}
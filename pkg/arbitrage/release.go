@@ -14,6 +14,7 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"time"
 )
 
 type Release struct {
@@ -80,7 +81,12 @@ func FromFile(root string) (*Release, error) {
 			return err
 		}
 		if !info.IsDir() {
-			path, _ = filepath.Rel(root, path)
+			if path == root {
+				// single-file release
+				path = filepath.Base(root)
+			} else {
+				path, _ = filepath.Rel(root, path)
+			}
 			files = append(files, File{
 				Name: path,
 				Size: info.Size(),
@@ -100,6 +106,23 @@ func FromFile(root string) (*Release, error) {
 	return r, nil
 }
 
+// ModTime returns the newest modification time of a release and all files
+// and directories below it, which changes whenever files are edited, added,
+// renamed or removed.
+func ModTime(root string) (time.Time, error) {
+	var newest time.Time
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.ModTime().After(newest) {
+			newest = info.ModTime()
+		}
+		return nil
+	})
+	return newest, err
+}
+
 func ParseFileList(filestr string) []File {
 	if filestr == "" {
 		return []File{}
@@ -0,0 +1,167 @@
+// Author: EmotionalDots @ PTH
+//
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package arbitrage
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// State is the checkpoint of a "downthemall" run. It records the hash,
+// matches and downloaded torrents of every local release so that
+// interrupted runs can be resumed.
+type State struct {
+	Source   string                   `json:"source"`
+	Updated  time.Time                `json:"updated"`
+	Releases map[string]*StateRelease `json:"releases"`
+
+	mu     sync.Mutex
+	saveMu sync.Mutex // serializes writes of the state file
+}
+
+type StateRelease struct {
+	Path       string       `json:"path"`
+	Name       string       `json:"name"`
+	Hash       string       `json:"hash,omitempty"`
+	Size       int64        `json:"size,omitempty"`
+	ModTime    time.Time    `json:"modTime"` // see ModTime, when the release was hashed
+	Queried    bool         `json:"queried"`
+	Matches    []StateMatch `json:"matches,omitempty"`
+	Downloaded []int64      `json:"downloaded,omitempty"`
+	Errors     []string     `json:"errors,omitempty"`
+}
+
+type StateMatch struct {
+	Id       int64  `json:"id"`
+	FilePath string `json:"filePath"`
+	Status   string `json:"status"`
+	Have     string `json:"have,omitempty"` // user torrent list the match is on, e.g. seeding
+}
+
+// Done returns whether the release was queried and either had no matches,
+// at least one matching torrent was downloaded or is already on one of the
+// user's torrent lists.
+func (r *StateRelease) Done() bool {
+	if !r.Queried {
+		return false
+	}
+	if len(r.Matches) == 0 || len(r.Downloaded) > 0 {
+		return true
+	}
+	for _, m := range r.Matches {
+		if m.Have != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// Changed returns whether the release has to be hashed again, because it
+// was not hashed yet or was modified since.
+func (r *StateRelease) Changed(modTime time.Time) bool {
+	return r.Hash == "" || !r.ModTime.Equal(modTime)
+}
+
+func (r *StateRelease) HasDownloaded(id int64) bool {
+	for _, d := range r.Downloaded {
+		if d == id {
+			return true
+		}
+	}
+	return false
+}
+
+// LoadState reads the state file of a source, or returns an empty state if
+// it does not exist yet.
+func LoadState(path, source string) (*State, error) {
+	s, err := ReadState(path)
+	if os.IsNotExist(err) {
+		return &State{Source: source, Releases: make(map[string]*StateRelease)}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if s.Source != source {
+		return nil, fmt.Errorf("state %s belongs to source %q, not %q", path, s.Source, source)
+	}
+	return s, nil
+}
+
+// ReadState reads an existing state file.
+func ReadState(path string) (*State, error) {
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	s := &State{}
+	if err := json.Unmarshal(raw, s); err != nil {
+		return nil, fmt.Errorf("state %s: %s", path, err)
+	}
+	if s.Releases == nil {
+		s.Releases = make(map[string]*StateRelease)
+	}
+	return s, nil
+}
+
+// Save atomically writes the state to a file. It may be called
+// concurrently, the last call wins.
+func (s *State) Save(path string) error {
+	s.saveMu.Lock()
+	defer s.saveMu.Unlock()
+
+	s.mu.Lock()
+	s.Updated = time.Now()
+	raw, err := json.MarshalIndent(s, "", "  ")
+	s.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, raw, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// Get returns a copy of the state of a release.
+func (s *State) Get(path string) (StateRelease, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r, ok := s.Releases[path]
+	if !ok {
+		return StateRelease{Path: path}, false
+	}
+	return *r, true
+}
+
+// Update modifies the state of a release while holding the state lock.
+func (s *State) Update(path string, fn func(r *StateRelease)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r, ok := s.Releases[path]
+	if !ok {
+		r = &StateRelease{Path: path}
+		s.Releases[path] = r
+	}
+	fn(r)
+}
+
+// Sorted returns all releases sorted by path.
+func (s *State) Sorted() []StateRelease {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	list := make([]StateRelease, 0, len(s.Releases))
+	for _, r := range s.Releases {
+		list = append(list, *r)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Path < list[j].Path })
+	return list
+}
@@ -0,0 +1,162 @@
+// Author: EmotionalDots @ PTH
+//
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package arbitrage
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestStateReleaseDone(t *testing.T) {
+	tests := []struct {
+		name     string
+		release  StateRelease
+		expected bool
+	}{
+		{"not queried", StateRelease{Hash: "h"}, false},
+		{"no matches", StateRelease{Queried: true}, true},
+		{"pending match", StateRelease{Queried: true, Matches: []StateMatch{{Id: 1}}}, false},
+		{"downloaded", StateRelease{Queried: true, Matches: []StateMatch{{Id: 1}, {Id: 2}}, Downloaded: []int64{2}}, true},
+		{"seeding", StateRelease{Queried: true, Matches: []StateMatch{{Id: 1}, {Id: 2, Have: "seeding"}}}, true},
+		{"downloaded before query", StateRelease{Downloaded: []int64{2}}, false},
+	}
+	for _, test := range tests {
+		if done := test.release.Done(); done != test.expected {
+			t.Errorf("%s: expected Done() %v, got %v", test.name, test.expected, done)
+		}
+	}
+}
+
+func TestStateResume(t *testing.T) {
+	dir, err := ioutil.TempDir("", "arbitrage-state")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "arbitrage-red.json")
+
+	s, err := LoadState(path, "red")
+	if err != nil || len(s.Releases) != 0 {
+		t.Fatalf("expected empty state, got %v %v", s, err)
+	}
+
+	mod := time.Date(2017, 4, 8, 12, 0, 0, 123456789, time.Local)
+	s.Update("/music/Album", func(r *StateRelease) {
+		r.Name, r.Hash, r.Size, r.ModTime = "Album", "hash", 1234, mod
+		r.Queried = true
+		r.Matches = []StateMatch{{Id: 1, Status: "ok"}}
+	})
+	s.Update("/music/Other", func(r *StateRelease) {
+		r.Errors = append(r.Errors, "permission denied")
+	})
+	if err := s.Save(path); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err = LoadState(path, "red")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, ok := s.Get("/music/Album")
+	if !ok || r.Hash != "hash" || r.Size != 1234 || r.Done() {
+		t.Errorf("unexpected release after resume: %+v", r)
+	}
+	if r.Changed(mod) {
+		t.Error("expected release to be unchanged after resume")
+	}
+	if !r.Changed(mod.Add(time.Second)) {
+		t.Error("expected release to be changed with newer modification time")
+	}
+	if r, ok := s.Get("/music/Other"); !ok || !r.Changed(time.Time{}) {
+		t.Errorf("expected release without hash to be changed: %+v", r)
+	}
+	if list := s.Sorted(); len(list) != 2 || list[0].Path != "/music/Album" {
+		t.Errorf("unexpected sorted releases: %+v", list)
+	}
+
+	if _, err := LoadState(path, "apl"); err == nil {
+		t.Error("expected error for state of other source")
+	}
+}
+
+func TestStateConcurrentSave(t *testing.T) {
+	dir, err := ioutil.TempDir("", "arbitrage-state")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "arbitrage-red.json")
+
+	s, err := LoadState(path, "red")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// downthemall saves from every worker and the query loop
+	const n = 20
+	errs := make(chan error, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			s.Update(fmt.Sprintf("/music/%02d", i), func(r *StateRelease) {
+				r.Hash = "hash"
+			})
+			errs <- s.Save(path)
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Error(err)
+		}
+	}
+
+	s, err = LoadState(path, "red")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s.Releases) != n {
+		t.Errorf("expected %d releases after concurrent saves, got %d", n, len(s.Releases))
+	}
+}
+
+func TestModTime(t *testing.T) {
+	dir, err := ioutil.TempDir("", "arbitrage-modtime")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	old := time.Now().Add(-time.Hour).Truncate(time.Second)
+	file := filepath.Join(dir, "01.flac")
+	if err := ioutil.WriteFile(file, []byte("flac"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range []string{file, dir} {
+		if err := os.Chtimes(p, old, old); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	mod, err := ModTime(dir)
+	if err != nil || !mod.Equal(old) {
+		t.Fatalf("expected %s, got %s %v", old, mod, err)
+	}
+	newer := old.Add(time.Minute)
+	if err := os.Chtimes(file, newer, newer); err != nil {
+		t.Fatal(err)
+	}
+	if mod, _ := ModTime(dir); !mod.Equal(newer) {
+		t.Errorf("expected edited file to change the release, got %s", mod)
+	}
+}
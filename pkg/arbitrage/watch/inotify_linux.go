@@ -0,0 +1,129 @@
+// Author: EmotionalDots @ PTH
+//
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package watch
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"unsafe"
+)
+
+const inotifyMask = syscall.IN_CREATE | syscall.IN_MOVED_TO | syscall.IN_CLOSE_WRITE |
+	syscall.IN_MODIFY | syscall.IN_DELETE
+
+// inotify watches the roots and, recursively, every new directory below
+// them so that writes into new downloads delay the settle timer.
+type inotify struct {
+	w    *Watcher
+	fd   int
+	file *os.File
+
+	mu    sync.Mutex
+	paths map[int32]string
+}
+
+func newBackend(w *Watcher) (backend, error) {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return nil, os.NewSyscallError("inotify_init1", err)
+	}
+	in := &inotify{
+		w:     w,
+		fd:    fd,
+		file:  os.NewFile(uintptr(fd), "inotify"),
+		paths: make(map[int32]string),
+	}
+	for _, root := range w.roots {
+		if err := in.add(root); err != nil {
+			in.close()
+			return nil, err
+		}
+	}
+	go in.readLoop()
+	return in, nil
+}
+
+func (in *inotify) add(path string) error {
+	wd, err := syscall.InotifyAddWatch(in.fd, path, inotifyMask)
+	if err != nil {
+		return &os.PathError{Op: "inotify_add_watch", Path: path, Err: err}
+	}
+	in.mu.Lock()
+	in.paths[int32(wd)] = path
+	in.mu.Unlock()
+	return nil
+}
+
+// addTree watches a new directory and all directories below it.
+func (in *inotify) addTree(root string) {
+	filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err == nil && info.IsDir() {
+			if err := in.add(path); err != nil {
+				in.w.error(err)
+			}
+		}
+		return nil
+	})
+}
+
+func (in *inotify) close() error {
+	return in.file.Close()
+}
+
+func (in *inotify) readLoop() {
+	buf := make([]byte, 64*(syscall.SizeofInotifyEvent+syscall.NAME_MAX+1))
+	for {
+		n, err := in.file.Read(buf)
+		if err != nil {
+			select {
+			case <-in.w.done:
+			default:
+				in.w.error(err)
+			}
+			return
+		}
+
+		for offset := 0; offset+syscall.SizeofInotifyEvent <= n; {
+			ev := (*syscall.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+			nameBytes := buf[offset+syscall.SizeofInotifyEvent : offset+syscall.SizeofInotifyEvent+int(ev.Len)]
+			offset += syscall.SizeofInotifyEvent + int(ev.Len)
+
+			if ev.Mask&syscall.IN_Q_OVERFLOW != 0 {
+				in.w.error(os.NewSyscallError("inotify", syscall.EOVERFLOW))
+				continue
+			}
+			in.mu.Lock()
+			dir, ok := in.paths[ev.Wd]
+			if ev.Mask&syscall.IN_IGNORED != 0 {
+				delete(in.paths, ev.Wd)
+			}
+			in.mu.Unlock()
+			if !ok {
+				continue
+			}
+
+			path := dir
+			if name := cstring(nameBytes); name != "" {
+				path = filepath.Join(dir, name)
+			}
+			if ev.Mask&syscall.IN_ISDIR != 0 && ev.Mask&(syscall.IN_CREATE|syscall.IN_MOVED_TO) != 0 {
+				in.addTree(path)
+			}
+			in.w.changed(path)
+		}
+	}
+}
+
+func cstring(b []byte) string {
+	for i, c := range b {
+		if c == 0 {
+			return string(b[:i])
+		}
+	}
+	return string(b)
+}
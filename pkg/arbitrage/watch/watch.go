@@ -0,0 +1,135 @@
+// Author: EmotionalDots @ PTH
+//
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+// Package watch reports new files and directories in a set of directories,
+// e.g. downloads moved there by a torrent client, once they have settled.
+package watch
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+var ErrUnsupported = errors.New("watching directories is not supported on this platform")
+
+// Watcher emits the paths of new entries in the watched directories after
+// no changes were seen in them for the settle delay.
+type Watcher struct {
+	Settle time.Duration
+	Paths  chan string
+	Errors chan error
+
+	roots   []string
+	mu      sync.Mutex
+	pending map[string]time.Time
+	done    chan struct{}
+	backend backend
+}
+
+type backend interface {
+	close() error
+}
+
+// New starts watching the given directories. Entries that already exist
+// are ignored until they change.
+func New(dirs []string, settle time.Duration) (*Watcher, error) {
+	w := &Watcher{
+		Settle:  settle,
+		Paths:   make(chan string),
+		Errors:  make(chan error),
+		pending: make(map[string]time.Time),
+		done:    make(chan struct{}),
+	}
+	for _, d := range dirs {
+		abs, err := filepath.Abs(d)
+		if err != nil {
+			return nil, err
+		}
+		w.roots = append(w.roots, abs)
+	}
+
+	b, err := newBackend(w)
+	if err != nil {
+		return nil, err
+	}
+	w.backend = b
+	go w.settleLoop()
+	return w, nil
+}
+
+// Close stops watching.
+func (w *Watcher) Close() error {
+	close(w.done)
+	return w.backend.close()
+}
+
+// entry returns the top-level entry of a watched directory that contains
+// the changed path.
+func (w *Watcher) entry(path string) string {
+	for _, root := range w.roots {
+		rel, err := filepath.Rel(root, path)
+		if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+			continue
+		}
+		return filepath.Join(root, strings.SplitN(rel, string(filepath.Separator), 2)[0])
+	}
+	return ""
+}
+
+// changed marks the entry containing path as modified now.
+func (w *Watcher) changed(path string) {
+	e := w.entry(path)
+	if e == "" || strings.HasPrefix(filepath.Base(e), ".") {
+		return
+	}
+	w.mu.Lock()
+	w.pending[e] = time.Now()
+	w.mu.Unlock()
+}
+
+func (w *Watcher) settleLoop() {
+	interval := w.Settle / 4
+	if interval < 100*time.Millisecond {
+		interval = 100 * time.Millisecond
+	}
+	tick := time.NewTicker(interval)
+	defer tick.Stop()
+
+	for {
+		select {
+		case <-w.done:
+			return
+		case <-tick.C:
+		}
+
+		settled := make([]string, 0)
+		w.mu.Lock()
+		for path, last := range w.pending {
+			if time.Since(last) >= w.Settle {
+				settled = append(settled, path)
+				delete(w.pending, path)
+			}
+		}
+		w.mu.Unlock()
+
+		for _, path := range settled {
+			select {
+			case w.Paths <- path:
+			case <-w.done:
+				return
+			}
+		}
+	}
+}
+
+func (w *Watcher) error(err error) {
+	select {
+	case w.Errors <- err:
+	case <-w.done:
+	}
+}
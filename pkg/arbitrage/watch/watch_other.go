@@ -0,0 +1,13 @@
+// Author: EmotionalDots @ PTH
+//
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+//go:build !linux
+// +build !linux
+
+package watch
+
+func newBackend(w *Watcher) (backend, error) {
+	return nil, ErrUnsupported
+}
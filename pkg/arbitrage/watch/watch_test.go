@@ -0,0 +1,52 @@
+package watch
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWatchSettle(t *testing.T) {
+	dir, err := ioutil.TempDir("", "arbitrage-watch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	w, err := New([]string{dir}, 300*time.Millisecond)
+	if err == ErrUnsupported {
+		t.Skip(err)
+	} else if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	release := filepath.Join(dir, "Album")
+	if err := os.Mkdir(release, 0755); err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		time.Sleep(150 * time.Millisecond)
+		name := filepath.Join(release, string('1'+byte(i))+".flac")
+		if err := ioutil.WriteFile(name, []byte("data"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	select {
+	case path := <-w.Paths:
+		if path != release {
+			t.Errorf("Expected %s, got %s", release, path)
+		}
+		if time.Since(start) < 750*time.Millisecond {
+			t.Errorf("Release was reported before it settled")
+		}
+	case err := <-w.Errors:
+		t.Fatal(err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for settled release")
+	}
+}
@@ -8,6 +8,8 @@ import (
 	"net/url"
 	"strings"
 	"time"
+
+	"github.com/emotionaldots/arbitrage/pkg/model"
 )
 
 type Client struct {
@@ -30,6 +32,18 @@ type Release struct {
 	Id       int64  `json:"id"`
 	Hash     string `json:"hash"`
 	FilePath string `json:"filePath"`
+
+	// Torrent metadata, only set if the torrent is in the server's index
+	Media       string `json:"media"`
+	Format      string `json:"format"`
+	Encoding    string `json:"encoding"`
+	Size        int64  `json:"size"`
+	Seeders     int    `json:"seeders"`
+	Snatched    int    `json:"snatched"`
+	FreeTorrent bool   `json:"freeTorrent"`
+	Remastered  bool   `json:"remastered"`
+	Edition     string `json:"edition"`
+	Time        string `json:"time"`
 }
 
 type queryRequest struct {
@@ -105,3 +119,51 @@ func (c *Client) Query(source string, hashes []string) ([]Release, error) {
 	c.LastTime = time.Now()
 	return qresult.Torrents, err
 }
+
+// Torrent fetches a torrent and its group from the archive of a source.
+func (c *Client) Torrent(source string, id int64) (*model.TorrentAndGroup, error) {
+	tg := &model.TorrentAndGroup{}
+	return tg, c.ajax(source, "torrent", id, tg)
+}
+
+// TorrentGroup fetches a group and all of its torrents from the archive of
+// a source.
+func (c *Client) TorrentGroup(source string, id int64) (*model.GroupAndTorrents, error) {
+	gt := &model.GroupAndTorrents{}
+	return gt, c.ajax(source, "torrentgroup", id, gt)
+}
+
+func (c *Client) ajax(source, action string, id int64, v interface{}) error {
+	time.Sleep(c.LastTime.Add(2500 * time.Millisecond).Sub(time.Now()))
+	c.LastTime = time.Now()
+
+	params := url.Values{}
+	params.Set("action", action)
+	params.Set("id", fmt.Sprintf("%d", id))
+	req, err := http.NewRequest("GET", c.Url+"/"+source+"/ajax.php?"+params.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("User-Agent", c.UserAgent)
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+	var result Response
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		if resp.StatusCode != 200 {
+			err = fmt.Errorf("api %s: unexpected status code %d: %s", action, resp.StatusCode, http.StatusText(resp.StatusCode))
+		}
+		return err
+	}
+	if result.Status != "success" {
+		var msg string
+		if result.Result != nil {
+			json.Unmarshal(*result.Result, &msg)
+		}
+		return errors.New("API returned error: " + msg)
+	}
+	return json.Unmarshal(*result.Result, v)
+}
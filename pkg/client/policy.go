@@ -0,0 +1,69 @@
+package client
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Policies to choose between several torrents matching the same release.
+const (
+	// PolicyFreeleech prefers freeleech torrents, then the most seeders
+	PolicyFreeleech = "freeleech"
+	// PolicySeeders prefers the most seeders, then freeleech torrents
+	PolicySeeders = "seeders"
+	// PolicyOriginal prefers the original release over remasters, then the
+	// earliest upload
+	PolicyOriginal = "original"
+)
+
+var Policies = []string{PolicyFreeleech, PolicySeeders, PolicyOriginal}
+
+func ValidPolicy(policy string) error {
+	for _, p := range Policies {
+		if p == policy {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown policy %q, expected one of %v", policy, Policies)
+}
+
+// Rank returns a copy of releases ordered by preference according to the
+// given policy, the first one should be downloaded.
+func Rank(releases []Release, policy string) []Release {
+	ranked := make([]Release, len(releases))
+	copy(ranked, releases)
+
+	less := func(a, b Release) bool { return a.Id < b.Id }
+	switch policy {
+	case PolicyFreeleech:
+		less = func(a, b Release) bool {
+			if a.FreeTorrent != b.FreeTorrent {
+				return a.FreeTorrent
+			}
+			if a.Seeders != b.Seeders {
+				return a.Seeders > b.Seeders
+			}
+			return a.Id < b.Id
+		}
+	case PolicySeeders:
+		less = func(a, b Release) bool {
+			if a.Seeders != b.Seeders {
+				return a.Seeders > b.Seeders
+			}
+			if a.FreeTorrent != b.FreeTorrent {
+				return a.FreeTorrent
+			}
+			return a.Id < b.Id
+		}
+	case PolicyOriginal:
+		less = func(a, b Release) bool {
+			if a.Remastered != b.Remastered {
+				return !a.Remastered
+			}
+			return a.Id < b.Id
+		}
+	}
+
+	sort.SliceStable(ranked, func(i, j int) bool { return less(ranked[i], ranked[j]) })
+	return ranked
+}
@@ -0,0 +1,29 @@
+package client
+
+import "testing"
+
+func TestRank(t *testing.T) {
+	releases := []Release{
+		{Id: 30, Seeders: 50, Remastered: true},
+		{Id: 20, Seeders: 5, FreeTorrent: true, Remastered: true},
+		{Id: 10, Seeders: 10},
+	}
+	expected := map[string][]int64{
+		PolicyFreeleech: {20, 30, 10},
+		PolicySeeders:   {30, 10, 20},
+		PolicyOriginal:  {10, 20, 30},
+	}
+
+	for policy, ids := range expected {
+		ranked := Rank(releases, policy)
+		for i, id := range ids {
+			if ranked[i].Id != id {
+				t.Errorf("%s: expected %v, got %+v", policy, ids, ranked)
+				break
+			}
+		}
+	}
+	if releases[0].Id != 30 {
+		t.Error("Rank modified its input")
+	}
+}
@@ -0,0 +1,317 @@
+// Author: EmotionalDots @ PTH
+//
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+// Package integrity verifies local releases with the checksum files that
+// come with them (SFV, MD5, FFP), their cue sheets and the audio MD5 stored
+// in FLAC files.
+package integrity
+
+import (
+	"bufio"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/emotionaldots/arbitrage/pkg/arbitrage"
+)
+
+const (
+	StatusOK           = "ok"
+	StatusMismatch     = "mismatch"
+	StatusMissing      = "missing"
+	StatusError        = "error"
+	StatusUnverifiable = "unverifiable"
+)
+
+// Result is the outcome of a single check of a file.
+type Result struct {
+	File   string // path relative to the release root
+	Kind   string // sfv, md5, ffp, cue or flac
+	Source string // checksum file or cue sheet the check came from
+	Status string
+	Detail string
+}
+
+func (r Result) Failed() bool {
+	return r.Status != StatusOK && r.Status != StatusUnverifiable
+}
+
+type Options struct {
+	// Audio decodes FLAC files and verifies their STREAMINFO MD5.
+	Audio bool
+	// Workers is the number of FLAC files decoded in parallel.
+	Workers int
+}
+
+// Check verifies all checksum files, cue sheets and, optionally, FLAC
+// files of a release.
+func Check(root string, opts Options) ([]Result, error) {
+	r, err := arbitrage.FromFile(root)
+	if err != nil {
+		return nil, err
+	}
+	fi, err := os.Stat(root)
+	if err != nil {
+		return nil, err
+	}
+	dir := root
+	if !fi.IsDir() {
+		dir = filepath.Dir(root)
+	}
+
+	results := make([]Result, 0)
+	flacs := make([]string, 0)
+	for _, f := range r.FileList {
+		path := filepath.Join(dir, f.Name)
+		var res []Result
+		switch strings.ToLower(filepath.Ext(f.Name)) {
+		case ".sfv":
+			res, err = checkList(dir, path, "sfv", parseSFV, crc32File)
+		case ".md5":
+			res, err = checkList(dir, path, "md5", parseMD5, md5File)
+		case ".ffp":
+			res, err = checkList(dir, path, "ffp", parseFFP, flacFingerprint)
+		case ".cue":
+			res, err = checkCue(dir, path)
+		case ".flac":
+			flacs = append(flacs, path)
+		}
+		if err != nil {
+			res = []Result{{File: f.Name, Kind: strings.TrimPrefix(filepath.Ext(f.Name), "."), Status: StatusError, Detail: err.Error()}}
+		}
+		results = append(results, res...)
+	}
+
+	if opts.Audio {
+		results = append(results, checkFLACs(dir, flacs, opts.Workers)...)
+	}
+	sort.SliceStable(results, func(i, j int) bool { return results[i].File < results[j].File })
+	return results, nil
+}
+
+// entry is a line of a checksum file.
+type entry struct {
+	Name string
+	Sum  string
+}
+
+func readLines(path string) ([]string, error) {
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	text := strings.TrimPrefix(string(raw), "\ufeff")
+	lines := make([]string, 0)
+	s := bufio.NewScanner(strings.NewReader(text))
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines, s.Err()
+}
+
+// parseSFV parses "name CRC32" lines, ";" starts a comment.
+func parseSFV(lines []string) []entry {
+	entries := make([]entry, 0)
+	for _, l := range lines {
+		if strings.HasPrefix(l, ";") {
+			continue
+		}
+		i := strings.LastIndexAny(l, " \t")
+		if i < 0 {
+			continue
+		}
+		entries = append(entries, entry{strings.TrimSpace(l[:i]), strings.ToLower(l[i+1:])})
+	}
+	return entries
+}
+
+// parseMD5 parses md5sum output: "hash  name" or "hash *name".
+func parseMD5(lines []string) []entry {
+	entries := make([]entry, 0)
+	for _, l := range lines {
+		if strings.HasPrefix(l, "#") || strings.HasPrefix(l, ";") {
+			continue
+		}
+		parts := strings.SplitN(l, " ", 2)
+		if len(parts) != 2 || len(parts[0]) != 32 {
+			continue
+		}
+		name := strings.TrimLeft(parts[1], " *")
+		entries = append(entries, entry{name, strings.ToLower(parts[0])})
+	}
+	return entries
+}
+
+// parseFFP parses FLAC fingerprints: "name:md5".
+func parseFFP(lines []string) []entry {
+	entries := make([]entry, 0)
+	for _, l := range lines {
+		if strings.HasPrefix(l, "#") || strings.HasPrefix(l, ";") {
+			continue
+		}
+		i := strings.LastIndex(l, ":")
+		if i < 0 {
+			continue
+		}
+		entries = append(entries, entry{l[:i], strings.ToLower(strings.TrimSpace(l[i+1:]))})
+	}
+	return entries
+}
+
+// resolve finds a referenced file relative to dir. Windows path separators
+// and differences in case are tolerated.
+func resolve(dir, name string) (string, bool) {
+	name = strings.Replace(name, "\\", "/", -1)
+	path := filepath.Join(dir, filepath.FromSlash(name))
+	if _, err := os.Stat(path); err == nil {
+		return path, true
+	}
+
+	parent := filepath.Dir(path)
+	files, err := ioutil.ReadDir(parent)
+	if err != nil {
+		return path, false
+	}
+	for _, f := range files {
+		if strings.EqualFold(f.Name(), filepath.Base(path)) {
+			return filepath.Join(parent, f.Name()), true
+		}
+	}
+	return path, false
+}
+
+func rel(dir, path string) string {
+	r, err := filepath.Rel(dir, path)
+	if err != nil {
+		return path
+	}
+	return r
+}
+
+func checkList(dir, listPath, kind string, parse func([]string) []entry, sum func(string) (string, error)) ([]Result, error) {
+	lines, err := readLines(listPath)
+	if err != nil {
+		return nil, err
+	}
+	base := filepath.Dir(listPath)
+
+	results := make([]Result, 0)
+	for _, e := range parse(lines) {
+		path, ok := resolve(base, e.Name)
+		res := Result{File: rel(dir, path), Kind: kind, Source: rel(dir, listPath)}
+		if !ok {
+			res.Status = StatusMissing
+			results = append(results, res)
+			continue
+		}
+
+		actual, err := sum(path)
+		switch {
+		case err == errNoMD5:
+			res.Status, res.Detail = StatusUnverifiable, err.Error()
+		case err != nil:
+			res.Status, res.Detail = StatusError, err.Error()
+		case actual != e.Sum:
+			res.Status, res.Detail = StatusMismatch, "expected "+e.Sum+", got "+actual
+		default:
+			res.Status = StatusOK
+		}
+		results = append(results, res)
+	}
+	return results, nil
+}
+
+// cueFile extracts the file name of a FILE command in a cue sheet.
+func cueFile(line string) (string, bool) {
+	if !strings.HasPrefix(strings.ToUpper(line), "FILE ") {
+		return "", false
+	}
+	rest := strings.TrimSpace(line[5:])
+	if strings.HasPrefix(rest, "\"") {
+		if end := strings.Index(rest[1:], "\""); end >= 0 {
+			return rest[1 : end+1], true
+		}
+		return "", false
+	}
+	// unquoted: FILE name.wav WAVE
+	if i := strings.LastIndex(rest, " "); i > 0 {
+		return rest[:i], true
+	}
+	return rest, true
+}
+
+var audioExtensions = []string{".flac", ".wav", ".ape", ".wv", ".m4a", ".mp3", ".aiff", ".tta"}
+
+// checkCue confirms that every file referenced in a cue sheet is present.
+// Cue sheets often reference the ripped ".wav" files, so the same name with
+// another audio extension is accepted.
+func checkCue(dir, cuePath string) ([]Result, error) {
+	lines, err := readLines(cuePath)
+	if err != nil {
+		return nil, err
+	}
+	base := filepath.Dir(cuePath)
+
+	results := make([]Result, 0)
+	for _, l := range lines {
+		name, ok := cueFile(l)
+		if !ok {
+			continue
+		}
+		path, found := resolve(base, name)
+		res := Result{File: rel(dir, path), Kind: "cue", Source: rel(dir, cuePath), Status: StatusOK}
+		if !found {
+			res.Status = StatusMissing
+			stem := strings.TrimSuffix(name, filepath.Ext(name))
+			for _, ext := range audioExtensions {
+				if alt, ok := resolve(base, stem+ext); ok {
+					res.File, res.Status = rel(dir, alt), StatusOK
+					res.Detail = "referenced as " + filepath.Base(name)
+					break
+				}
+			}
+		}
+		results = append(results, res)
+	}
+	return results, nil
+}
+
+func checkFLACs(dir string, paths []string, workers int) []Result {
+	if workers < 1 {
+		workers = 1
+	}
+	results := make([]Result, len(paths))
+	in := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range in {
+				res := Result{File: rel(dir, paths[i]), Kind: "flac", Status: StatusOK}
+				if err := verifyFLAC(paths[i]); err == errNoMD5 {
+					res.Status, res.Detail = StatusUnverifiable, err.Error()
+				} else if err == errMD5Mismatch {
+					res.Status, res.Detail = StatusMismatch, err.Error()
+				} else if err != nil {
+					res.Status, res.Detail = StatusError, err.Error()
+				}
+				results[i] = res
+			}
+		}()
+	}
+	for i := range paths {
+		in <- i
+	}
+	close(in)
+	wg.Wait()
+	return results
+}
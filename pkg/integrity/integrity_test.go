@@ -0,0 +1,151 @@
+package integrity
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCheck(t *testing.T) {
+	dir, err := ioutil.TempDir("", "arbitrage-integrity")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"01 - One.mp3":   "one",
+		"02 - Two.mp3":   "two",
+		"CD2/03.wv":      "three",
+		"album.sfv":      "; generated\r\n01 - One.mp3 7A6C86F1\r\n02 - Two.mp3 00000000\r\n04 - Four.mp3 12345678\r\n",
+		"album.md5":      "f97c5d29941bfb1b2fdab0874906ab82 *01 - One.mp3\nb8a9f715dbb64fd5c56e7783c6820a61  CD2\\03.wv\n",
+		"album.cue":      "PERFORMER \"Artist\"\nFILE \"01 - One.wav\" WAVE\n  TRACK 01 AUDIO\nFILE \"05 - Five.wav\" WAVE\n  TRACK 02 AUDIO\n",
+		"ignored.txt":    "notes",
+		"CD2/nested.md5": "4a9c8ad8a6e2e8d7ae7d4b1c3c2d3e1f  03.wv\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	results, err := Check(dir, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]string{
+		"sfv 01 - One.mp3":  StatusOK,
+		"sfv 02 - Two.mp3":  StatusMismatch,
+		"sfv 04 - Four.mp3": StatusMissing,
+		"md5 01 - One.mp3":  StatusOK,
+		"md5 CD2/03.wv":     StatusMismatch,
+		"cue 01 - One.mp3":  StatusOK,
+		"cue 05 - Five.wav": StatusMissing,
+	}
+	got := make(map[string]string)
+	for _, r := range results {
+		got[r.Kind+" "+r.File] = r.Status
+	}
+	for key, status := range expected {
+		if got[key] != status {
+			t.Errorf("%s: expected %s, got %q", key, status, got[key])
+		}
+	}
+}
+
+// testdata/tone.flac is a 128 sample mono stream, tone-corrupt.flac has a
+// flipped bit in the audio of its second frame.
+const toneMD5 = "9add1a45e868c62ce8975d8b47ea5df3"
+
+func TestVerifyFLAC(t *testing.T) {
+	if err := verifyFLAC("testdata/tone.flac"); err != nil {
+		t.Errorf("tone.flac: %v", err)
+	}
+	if err := verifyFLAC("testdata/tone-corrupt.flac"); err == nil {
+		t.Error("tone-corrupt.flac: expected error")
+	}
+	if sum, err := flacFingerprint("testdata/tone.flac"); err != nil || sum != toneMD5 {
+		t.Errorf("expected fingerprint %s, got %s %v", toneMD5, sum, err)
+	}
+
+	raw, err := ioutil.ReadFile("testdata/tone.flac")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir, err := ioutil.TempDir("", "arbitrage-integrity")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// the MD5 is stored in the last 16 bytes of STREAMINFO
+	md5 := bytes.Index(raw, []byte{0x9a, 0xdd, 0x1a, 0x45})
+	if md5 < 0 {
+		t.Fatal("MD5 not found in STREAMINFO")
+	}
+	variants := map[string]error{"wrong-md5.flac": errMD5Mismatch, "no-md5.flac": errNoMD5}
+	for name, expected := range variants {
+		modified := append([]byte{}, raw...)
+		for i := md5; i < md5+16; i++ {
+			if name == "no-md5.flac" {
+				modified[i] = 0
+			} else {
+				modified[i] ^= 0xff
+			}
+		}
+		path := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(path, modified, 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := verifyFLAC(path); err != expected {
+			t.Errorf("%s: expected %v, got %v", name, expected, err)
+		}
+	}
+}
+
+func TestCheckFLAC(t *testing.T) {
+	dir, err := ioutil.TempDir("", "arbitrage-integrity")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for name, fixture := range map[string]string{"01 - Tone.flac": "tone.flac", "02 - Corrupt.flac": "tone-corrupt.flac"} {
+		raw, err := ioutil.ReadFile(filepath.Join("testdata", fixture))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(dir, name), raw, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ffp := "01 - Tone.flac:" + toneMD5 + "\r\n02 - Corrupt.flac:00000000000000000000000000000000\r\n"
+	if err := ioutil.WriteFile(filepath.Join(dir, "album.ffp"), []byte(ffp), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	results, err := Check(dir, Options{Audio: true, Workers: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{
+		"ffp 01 - Tone.flac":     StatusOK,
+		"ffp 02 - Corrupt.flac":  StatusMismatch,
+		"flac 01 - Tone.flac":    StatusOK,
+		"flac 02 - Corrupt.flac": StatusError,
+	}
+	got := make(map[string]string)
+	for _, r := range results {
+		got[r.Kind+" "+r.File] = r.Status
+	}
+	for key, status := range expected {
+		if got[key] != status {
+			t.Errorf("%s: expected %s, got %q", key, status, got[key])
+		}
+	}
+}
@@ -0,0 +1,95 @@
+// Author: EmotionalDots @ PTH
+//
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package integrity
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"os"
+
+	"github.com/mewkiz/flac"
+)
+
+var (
+	errNoMD5       = errors.New("no audio MD5 in STREAMINFO")
+	errMD5Mismatch = errors.New("decoded audio does not match STREAMINFO MD5")
+)
+
+func crc32File(path string) (string, error) {
+	sum, err := hashFile(path, crc32.NewIEEE())
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(sum), nil
+}
+
+func md5File(path string) (string, error) {
+	sum, err := hashFile(path, md5.New())
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(sum), nil
+}
+
+// flacFingerprint returns the audio MD5 from the STREAMINFO block, as
+// listed in FLAC fingerprint (.ffp) files.
+func flacFingerprint(path string) (string, error) {
+	stream, err := flac.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer stream.Close()
+	return hex.EncodeToString(stream.Info.MD5sum[:]), nil
+}
+
+// verifyFLAC decodes all audio frames of a FLAC file and compares their MD5
+// with the one stored in STREAMINFO by the encoder.
+func verifyFLAC(path string) error {
+	stream, err := flac.Open(path)
+	if err != nil {
+		return err
+	}
+	defer stream.Close()
+
+	if stream.Info.MD5sum == [md5.Size]byte{} {
+		return errNoMD5
+	}
+
+	h := md5.New()
+	for {
+		frame, err := stream.ParseNext()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return fmt.Errorf("decoding failed: %s", err)
+		}
+		frame.Hash(h)
+	}
+
+	var sum [md5.Size]byte
+	copy(sum[:], h.Sum(nil))
+	if sum != stream.Info.MD5sum {
+		return errMD5Mismatch
+	}
+	return nil
+}
+
+func hashFile(path string, h hash.Hash) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	if _, err := io.Copy(h, f); err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
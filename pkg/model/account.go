@@ -1,21 +1,22 @@
 package model
 
 type Account struct {
-	Username      string `json:"username"`
-	ID            int    `json:"id"`
-	AuthKey       string `json:"authKey"`
-	PassKey       string `json:"passKey"`
+	Username      FlexString `json:"username"`
+	ID            FlexInt    `json:"id"`
+	AuthKey       FlexString `json:"authKey"`
+	PassKey       FlexString `json:"passKey"`
 	Notifications struct {
-		Messages       int  `json:"messages"`
-		Notifications  int  `json:"notifications"`
-		NewAnnouncment bool `json:"newAnnouncment"`
-		NewBlog        bool `json:"newBlog"`
+		Messages         FlexInt  `json:"messages"`
+		Notifications    FlexInt  `json:"notifications"`
+		NewAnnouncment   FlexBool `json:"newAnnouncement"`
+		NewBlog          FlexBool `json:"newBlog"`
+		NewSubscriptions FlexBool `json:"newSubscriptions"`
 	} `json:"notifications"`
 	UserStats struct {
-		Uploaded      int64   `json:"uploaded"`
-		Downloaded    int64   `json:"downloaded"`
-		Ratio         float64 `json:"ratio"`
-		RequiredRatio float64 `json:"requiredRatio"`
-		Class         string  `json:"class"`
+		Uploaded      FlexInt    `json:"uploaded"`
+		Downloaded    FlexInt    `json:"downloaded"`
+		Ratio         FlexFloat  `json:"ratio"`
+		RequiredRatio FlexFloat  `json:"requiredRatio"`
+		Class         FlexString `json:"class"`
 	} `json:"userstats"`
 }
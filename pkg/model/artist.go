@@ -0,0 +1,70 @@
+package model
+
+import "fmt"
+
+type Artist struct {
+	ID          FlexInt    `json:"id"`
+	Name        FlexString `json:"name"`
+	Image       FlexString `json:"image"`
+	Body        FlexString `json:"body" gorm:"type:text"`
+	VanityHouse FlexBool   `json:"vanityHouse"`
+}
+
+func (a Artist) String() string {
+	return fmt.Sprintf("artist %d: %s", a.ID, a.Name)
+}
+
+// ArtistGroup is a torrent group in the discography of an artist.
+type ArtistGroup struct {
+	GroupID              FlexInt    `json:"groupId"`
+	GroupName            FlexString `json:"groupName"`
+	GroupYear            FlexInt    `json:"groupYear"`
+	GroupRecordLabel     FlexString `json:"groupRecordLabel"`
+	GroupCatalogueNumber FlexString `json:"groupCatalogueNumber"`
+	Tags                 []string   `json:"tags"`
+	ReleaseType          FlexInt    `json:"releaseType"`
+	GroupVanityHouse     FlexBool   `json:"groupVanityHouse"`
+	// Role of the artist in the group, only set by the index
+	Role    string    `json:"role,omitempty"`
+	Torrent []Torrent `json:"torrent"`
+}
+
+type ArtistWithGroups struct {
+	Artist
+	TorrentGroup []ArtistGroup `json:"torrentgroup" sql:"-"`
+}
+
+// Roles of an artist in a torrent group.
+const (
+	RoleMain      = "main"
+	RoleGuest     = "guest"
+	RoleComposer  = "composer"
+	RoleConductor = "conductor"
+	RoleDJ        = "dj"
+	RoleRemixer   = "remixer"
+	RoleProducer  = "producer"
+)
+
+// Roles lists all roles, main artists first.
+var Roles = []string{RoleMain, RoleGuest, RoleComposer, RoleConductor, RoleDJ, RoleRemixer, RoleProducer}
+
+// GroupArtist links an artist to a torrent group in a role.
+type GroupArtist struct {
+	ID       int
+	GroupID  int `gorm:"index"`
+	ArtistID int `gorm:"index"`
+	Role     string
+}
+
+// Credits returns the artists of the group by role, in the order of Roles.
+func (m MusicInfo) Credits() map[string][]ArtistLink {
+	return map[string][]ArtistLink{
+		RoleMain:      m.Artists,
+		RoleGuest:     m.With,
+		RoleComposer:  m.Composers,
+		RoleConductor: m.Conductor,
+		RoleDJ:        m.DJ,
+		RoleRemixer:   m.RemixedBy,
+		RoleProducer:  m.Producer,
+	}
+}
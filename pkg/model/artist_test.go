@@ -0,0 +1,48 @@
+package model
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestMusicInfoCredits(t *testing.T) {
+	var g Group
+	err := json.Unmarshal([]byte(`{"id": 1, "musicInfo": {
+		"artists": [{"id": 1, "name": "Main"}, {"id": "2", "name": "Second"}],
+		"with": [{"id": 3, "name": "Guest"}],
+		"composers": [{"id": 4, "name": "Composer"}],
+		"conductor": null,
+		"dj": [{"id": 5, "name": "DJ"}],
+		"remixedBy": [{"id": 6, "name": "Remixer"}],
+		"producer": [{"id": 1, "name": "Main"}]
+	}}`), &g)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	credits := g.MusicInfo.Credits()
+	expected := map[string][]FlexInt{
+		RoleMain:      {1, 2},
+		RoleGuest:     {3},
+		RoleComposer:  {4},
+		RoleConductor: nil,
+		RoleDJ:        {5},
+		RoleRemixer:   {6},
+		RoleProducer:  {1},
+	}
+	if len(credits) != len(Roles) {
+		t.Errorf("expected all %d roles, got %v", len(Roles), credits)
+	}
+	for _, role := range Roles {
+		links := credits[role]
+		if len(links) != len(expected[role]) {
+			t.Errorf("%s: expected %v, got %v", role, expected[role], links)
+			continue
+		}
+		for i, id := range expected[role] {
+			if links[i].ID != id {
+				t.Errorf("%s: expected %v, got %v", role, expected[role], links)
+			}
+		}
+	}
+}
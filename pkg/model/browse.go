@@ -0,0 +1,48 @@
+package model
+
+// BrowseTorrent is a torrent of a group in the browse results.
+type BrowseTorrent struct {
+	TorrentID FlexInt `json:"torrentId"`
+}
+
+// BrowseResult is a torrent group in the browse results. Non-music groups
+// only have a single torrent, which is returned inline.
+type BrowseResult struct {
+	GroupID   FlexInt         `json:"groupId"`
+	GroupName FlexString      `json:"groupName"`
+	TorrentID FlexInt         `json:"torrentId"`
+	Torrents  []BrowseTorrent `json:"torrents"`
+}
+
+type Browse struct {
+	CurrentPage FlexInt        `json:"currentPage"`
+	Pages       FlexInt        `json:"pages"`
+	Results     []BrowseResult `json:"results"`
+}
+
+// MaxTorrentID returns the highest torrent ID in the results.
+func (b Browse) MaxTorrentID() int {
+	max := 0
+	for _, r := range b.Results {
+		if int(r.TorrentID) > max {
+			max = int(r.TorrentID)
+		}
+		for _, t := range r.Torrents {
+			if int(t.TorrentID) > max {
+				max = int(t.TorrentID)
+			}
+		}
+	}
+	return max
+}
+
+// MaxGroupID returns the highest torrent group ID in the results.
+func (b Browse) MaxGroupID() int {
+	max := 0
+	for _, r := range b.Results {
+		if int(r.GroupID) > max {
+			max = int(r.GroupID)
+		}
+	}
+	return max
+}
@@ -0,0 +1,138 @@
+package model
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Category is the kind of release described by a group. Most Gazelle
+// trackers are music trackers, but groups may also hold e-books or videos,
+// which carry their own metadata instead of MusicInfo.
+type Category string
+
+const (
+	CategoryMusic     Category = "music"
+	CategoryEBook     Category = "ebook"
+	CategoryAudiobook Category = "audiobook"
+	CategoryVideo     Category = "video"
+	CategoryOther     Category = "other"
+)
+
+// Default category IDs of the Gazelle upload form
+var gazelleCategories = map[int]Category{
+	1: CategoryMusic,
+	3: CategoryEBook,
+	4: CategoryAudiobook,
+	5: CategoryVideo,
+	7: CategoryEBook, // Comics
+}
+
+// BookInfo holds the metadata of an e-book (or audiobook) release.
+type BookInfo struct {
+	Authors   []string   `json:"authors"`
+	ISBN      FlexString `json:"isbn"`
+	Publisher FlexString `json:"publisher"`
+	Language  FlexString `json:"language,omitempty"`
+	Pages     FlexInt    `json:"pages,omitempty"`
+}
+
+// VideoInfo holds the metadata of a movie or TV release.
+type VideoInfo struct {
+	Resolution FlexString `json:"resolution"`
+	Codec      FlexString `json:"codec"`
+	Source     FlexString `json:"source"`
+	Container  FlexString `json:"container,omitempty"`
+	Season     FlexInt    `json:"season,omitempty"`
+	Episode    FlexInt    `json:"episode,omitempty"`
+}
+
+// DetectCategory determines the category of a group, either by its typed
+// metadata, the category name or the Gazelle category ID.
+func DetectCategory(g Group) Category {
+	switch {
+	case g.Category != "":
+		return g.Category
+	case g.BookInfo != nil:
+		return CategoryEBook
+	case g.VideoInfo != nil:
+		return CategoryVideo
+	}
+
+	name := strings.ToLower(string(g.CategoryName))
+	switch {
+	case name == "music":
+		return CategoryMusic
+	case strings.Contains(name, "audiobook"):
+		return CategoryAudiobook
+	case strings.Contains(name, "book") || strings.Contains(name, "comic"):
+		return CategoryEBook
+	case strings.Contains(name, "video") || strings.Contains(name, "movie") ||
+		strings.Contains(name, "tv") || strings.Contains(name, "film"):
+		return CategoryVideo
+	case name != "":
+		return CategoryOther
+	}
+
+	if c, ok := gazelleCategories[int(g.CategoryID)]; ok {
+		return c
+	}
+	return CategoryMusic
+}
+
+var (
+	reEpisode    = regexp.MustCompile(`(?i)\bS(\d{1,2})(?:E(\d{1,3}))?\b`)
+	reResolution = regexp.MustCompile(`(?i)\b(2160p|1080[pi]|720p|576[pi]|480[pi]|4K|UHD)\b`)
+	reCodec      = regexp.MustCompile(`(?i)\b(x264|x265|[hH]\.?26[45]|HEVC|AVC|XviD|DivX|AV1|VC-1|MPEG-?2)\b`)
+	reSource     = regexp.MustCompile(`(?i)\b(Blu-?Ray|Remux|WEB-?DL|WEBRip|WEB|HDTV|DVDRip|DVD\d?|BDRip|HDRip)\b`)
+	reContainer  = regexp.MustCompile(`(?i)\.(mkv|mp4|avi|m2ts|ts|wmv)$`)
+	reISBN       = regexp.MustCompile(`(?i)\bISBN(?:-1[03])?:?\s*((?:97[89][- ]?)?(?:\d[- ]?){9}[\dX])\b`)
+)
+
+// ParseVideoInfo extracts video metadata from a release or file name,
+// e.g. "Show.Name.S01E02.1080p.WEB-DL.x264.mkv".
+func ParseVideoInfo(name string) VideoInfo {
+	v := VideoInfo{}
+	if m := reEpisode.FindStringSubmatch(name); m != nil {
+		season, _ := strconv.Atoi(m[1])
+		episode, _ := strconv.Atoi(m[2])
+		v.Season, v.Episode = FlexInt(season), FlexInt(episode)
+	}
+	if m := reResolution.FindString(name); m != "" {
+		v.Resolution = FlexString(strings.ToLower(m))
+	}
+	if m := reCodec.FindString(name); m != "" {
+		v.Codec = FlexString(m)
+	}
+	if m := reSource.FindString(name); m != "" {
+		v.Source = FlexString(m)
+	}
+	if m := reContainer.FindStringSubmatch(name); m != nil {
+		v.Container = FlexString(strings.ToLower(m[1]))
+	}
+	return v
+}
+
+// ParseBookInfo extracts e-book metadata from a group. Authors are taken
+// from the artists or, as is common for e-book trackers, from a group name
+// in the form "Author - Title". The ISBN is searched in the description.
+func ParseBookInfo(g Group) BookInfo {
+	b := BookInfo{Publisher: g.RecordLabel}
+	for _, a := range g.MusicInfo.Artists {
+		b.Authors = append(b.Authors, string(a.Name))
+	}
+	if len(b.Authors) == 0 {
+		if parts := strings.SplitN(string(g.Name), " - ", 2); len(parts) == 2 {
+			for _, a := range strings.Split(parts[0], ",") {
+				b.Authors = append(b.Authors, strings.TrimSpace(a))
+			}
+		}
+	}
+	if m := reISBN.FindStringSubmatch(string(g.WikiBody)); m != nil {
+		b.ISBN = FlexString(strings.NewReplacer("-", "", " ", "").Replace(m[1]))
+	}
+	if b.ISBN == "" {
+		b.ISBN = g.CatalogueNumber
+	}
+	return b
+}
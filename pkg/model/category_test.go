@@ -0,0 +1,93 @@
+package model
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDetectCategory(t *testing.T) {
+	tests := []struct {
+		group    Group
+		expected Category
+	}{
+		{Group{Category: CategoryVideo, CategoryID: 1}, CategoryVideo},
+		{Group{BookInfo: &BookInfo{}}, CategoryEBook},
+		{Group{VideoInfo: &VideoInfo{}, CategoryID: 1}, CategoryVideo},
+		{Group{CategoryName: "Music", CategoryID: 5}, CategoryMusic},
+		{Group{CategoryName: "Audiobooks"}, CategoryAudiobook},
+		{Group{CategoryName: "E-Books"}, CategoryEBook},
+		{Group{CategoryName: "Comics"}, CategoryEBook},
+		{Group{CategoryName: "TV"}, CategoryVideo},
+		{Group{CategoryName: "Movies"}, CategoryVideo},
+		{Group{CategoryName: "Applications"}, CategoryOther},
+		{Group{CategoryName: "Comedy"}, CategoryOther},
+		{Group{CategoryID: 3}, CategoryEBook},
+		{Group{CategoryID: 5}, CategoryVideo},
+		{Group{CategoryID: 2}, CategoryMusic},
+		{Group{}, CategoryMusic},
+	}
+	for _, test := range tests {
+		if c := DetectCategory(test.group); c != test.expected {
+			t.Errorf("%q/%d: expected %s, got %s", test.group.CategoryName, test.group.CategoryID, test.expected, c)
+		}
+	}
+}
+
+func TestParseVideoInfo(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected VideoInfo
+	}{
+		{"Show.Name.S01E02.1080p.WEB-DL.x264.mkv", VideoInfo{
+			Resolution: "1080p", Codec: "x264", Source: "WEB-DL", Container: "mkv", Season: 1, Episode: 2,
+		}},
+		{"The.Matrix.1999.2160p.UHD.BluRay.x265.HDR-GROUP", VideoInfo{
+			Resolution: "2160p", Codec: "x265", Source: "BluRay",
+		}},
+		{"Planet Earth II S01 720p BluRay DTS x264", VideoInfo{
+			Resolution: "720p", Codec: "x264", Source: "BluRay", Season: 1,
+		}},
+		{"Amelie (2001) [DVDRip XviD].avi", VideoInfo{
+			Codec: "XviD", Source: "DVDRip", Container: "avi",
+		}},
+		{"Some Series S02E10 HDTV h.264", VideoInfo{
+			Codec: "h.264", Source: "HDTV", Season: 2, Episode: 10,
+		}},
+		{"Boards of Canada - Geogaddi (2002) [FLAC]", VideoInfo{}},
+	}
+	for _, test := range tests {
+		if v := ParseVideoInfo(test.name); v != test.expected {
+			t.Errorf("%s:\nexpected %+v\ngot      %+v", test.name, test.expected, v)
+		}
+	}
+}
+
+func TestParseBookInfo(t *testing.T) {
+	tests := []struct {
+		group    Group
+		expected BookInfo
+	}{
+		{
+			Group{Name: "Terry Pratchett - Small Gods", RecordLabel: "Corgi", WikiBody: "Paperback edition.\nISBN: 978-0-552-13890-4"},
+			BookInfo{Authors: []string{"Terry Pratchett"}, ISBN: "9780552138904", Publisher: "Corgi"},
+		},
+		{
+			Group{Name: "Good Omens", MusicInfo: MusicInfo{Artists: []ArtistLink{{Name: "Neil Gaiman"}, {Name: "Terry Pratchett"}}},
+				WikiBody: "First edition, ISBN-10 0-575-04800-X"},
+			BookInfo{Authors: []string{"Neil Gaiman", "Terry Pratchett"}, ISBN: "057504800X"},
+		},
+		{
+			Group{Name: "Douglas Preston, Lincoln Child - Relic", CatalogueNumber: "0812543262"},
+			BookInfo{Authors: []string{"Douglas Preston", "Lincoln Child"}, ISBN: "0812543262"},
+		},
+		{
+			Group{Name: "Relic"},
+			BookInfo{},
+		},
+	}
+	for _, test := range tests {
+		if b := ParseBookInfo(test.group); !reflect.DeepEqual(b, test.expected) {
+			t.Errorf("%s:\nexpected %+v\ngot      %+v", test.group.Name, test.expected, b)
+		}
+	}
+}
@@ -3,19 +3,19 @@ package model
 import "fmt"
 
 type Collage struct {
-	ID                  int      `json:"id"`
-	Name                string   `json:"name"`
-	Description         string   `json:"description"`
-	CreatorID           int      `json:"creatorID"`
-	Deleted             bool     `json:"deleted"`
-	CollageCategoryId   int      `json:"collageCategoryId"`
-	CollageCategoryName string   `json:"collageCategoryName"`
-	Locked              bool     `json:"locked"`
-	MaxGroups           int      `json:"maxGroups"`
-	MaxGroupsPerUser    int      `json:"maxGroupsPerUser"`
-	HasBookmarked       bool     `json:"hasBookmarked"`
-	SubscriberCount     int      `json:"subscriberCount"`
-	TorrentGroupIDList  []string `json:"torrentGroupIDList,string" sql:"-"`
+	ID                  FlexInt    `json:"id"`
+	Name                FlexString `json:"name"`
+	Description         FlexString `json:"description"`
+	CreatorID           FlexInt    `json:"creatorID"`
+	Deleted             FlexBool   `json:"deleted"`
+	CollageCategoryId   FlexInt    `json:"collageCategoryId"`
+	CollageCategoryName FlexString `json:"collageCategoryName"`
+	Locked              FlexBool   `json:"locked"`
+	MaxGroups           FlexInt    `json:"maxGroups"`
+	MaxGroupsPerUser    FlexInt    `json:"maxGroupsPerUser"`
+	HasBookmarked       FlexBool   `json:"hasBookmarked"`
+	SubscriberCount     FlexInt    `json:"subscriberCount"`
+	TorrentGroupIDList  []FlexInt  `json:"torrentGroupIDList" sql:"-"`
 }
 
 type CollageWithGroups struct {
@@ -23,6 +23,21 @@ type CollageWithGroups struct {
 	TorrentGroups []GroupWithTorrents `json:"torrentgroups" sql:"-"`
 }
 
+// GroupIDs returns the IDs of all torrent groups in the collage.
+func (c CollageWithGroups) GroupIDs() []int {
+	ids := make([]int, 0, len(c.TorrentGroupIDList))
+	for _, id := range c.TorrentGroupIDList {
+		ids = append(ids, int(id))
+	}
+	if len(ids) > 0 {
+		return ids
+	}
+	for _, g := range c.TorrentGroups {
+		ids = append(ids, int(g.ID))
+	}
+	return ids
+}
+
 func (c Collage) String() string {
 	return fmt.Sprintf("collage %d: %s", c.ID, c.Name)
 }
@@ -0,0 +1,29 @@
+package model
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestCollageGroupIDs(t *testing.T) {
+	tests := []struct {
+		json     string
+		expected []int
+	}{
+		{`{"id": 1, "torrentGroupIDList": ["10", 11], "torrentgroups": [{"id": 12}]}`, []int{10, 11}},
+		{`{"id": 1, "torrentgroups": [{"id": "12"}, {"id": 13}]}`, []int{12, 13}},
+		{`{"id": 1, "torrentGroupIDList": [], "torrentgroups": []}`, []int{}},
+		{`{"id": 1, "deleted": "1"}`, []int{}},
+	}
+	for _, test := range tests {
+		var c CollageWithGroups
+		if err := json.Unmarshal([]byte(test.json), &c); err != nil {
+			t.Errorf("%s: %s", test.json, err)
+			continue
+		}
+		if ids := c.GroupIDs(); !reflect.DeepEqual(ids, test.expected) {
+			t.Errorf("%s: expected %v, got %v", test.json, test.expected, ids)
+		}
+	}
+}
@@ -3,6 +3,7 @@ package model
 import (
 	"errors"
 	"fmt"
+	"strings"
 )
 
 type TorrentAndGroup struct {
@@ -53,12 +54,19 @@ func NormalizeTorrentGroups(v interface{}) ([]GroupAndTorrents, error) {
 		return gs, fmt.Errorf("normalize: unsupported type %T", m)
 	}
 
-	for _, g := range gs {
+	for i, g := range gs {
 		if g.Group.ID == 0 {
 			return gs, errors.New("normalize: no group id")
 		}
+		if len(g.Group.Tags) == 0 && g.Group.TagList != "" {
+			gs[i].Group.Tags = strings.Fields(string(g.Group.TagList))
+		}
+		gs[i].Group = describeGroup(g.Group, g.Torrents)
 		for i, t := range g.Torrents {
-			t.GroupID = int(g.Group.ID)
+			t.GroupID = g.Group.ID
+			if t.ID == 0 {
+				t.ID = t.TorrentID
+			}
 			g.Torrents[i] = t
 			if t.ID == 0 {
 				return gs, errors.New("normalize: no torrent id")
@@ -69,20 +77,43 @@ func NormalizeTorrentGroups(v interface{}) ([]GroupAndTorrents, error) {
 	return gs, nil
 }
 
+// describeGroup fills in the category and the typed metadata for non-music
+// releases, if the tracker did not return them already.
+func describeGroup(g Group, torrents []Torrent) Group {
+	g.Category = DetectCategory(g)
+	switch g.Category {
+	case CategoryEBook, CategoryAudiobook:
+		if g.BookInfo == nil {
+			b := ParseBookInfo(g)
+			g.BookInfo = &b
+		}
+	case CategoryVideo:
+		if g.VideoInfo == nil {
+			name := string(g.Name)
+			if len(torrents) > 0 && torrents[0].FilePath != "" {
+				name = string(torrents[0].FilePath)
+			}
+			v := ParseVideoInfo(name)
+			g.VideoInfo = &v
+		}
+	}
+	return g
+}
+
 func (gt GroupAndTorrents) String() string {
 	str := ""
 	numArtists := len(gt.Group.MusicInfo.Artists)
 	if numArtists == 1 {
-		str += gt.Group.MusicInfo.Artists[0].Name + " - "
+		str += string(gt.Group.MusicInfo.Artists[0].Name) + " - "
 	} else if numArtists > 1 {
 		str += "Various Artists - "
 	}
-	str += gt.Group.Name + " ["
+	str += string(gt.Group.Name) + " ["
 	for i, t := range gt.Torrents {
 		if i > 0 {
 			str += ", "
 		}
-		str += t.Media + "-" + t.Format
+		str += string(t.Media + "-" + t.Format)
 	}
 	return str + "]"
 }
@@ -0,0 +1,65 @@
+package model
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Formats that are usually expected for every music edition, ordered from
+// the best source quality to the worst.
+var Formats = []string{"FLAC 24bit Lossless", "FLAC Lossless", "MP3 320", "MP3 V0 (VBR)"}
+
+// FormatName returns the format and encoding of a torrent, e.g. "MP3 320".
+func FormatName(t Torrent) string {
+	return strings.TrimSpace(string(t.Format) + " " + string(t.Encoding))
+}
+
+// EditionKey identifies the edition of a torrent within its group: the
+// media and, for remasters, the remaster details.
+func EditionKey(t Torrent) string {
+	if !t.Remastered {
+		return string(t.Media) + "|original"
+	}
+	return fmt.Sprintf("%s|%d|%s|%s|%s", t.Media, t.RemasterYear, t.RemasterTitle,
+		t.RemasterRecordLabel, t.RemasterCatalogueNumber)
+}
+
+// Edition returns all torrents of a group in the same edition as t.
+func (g GroupAndTorrents) Edition(t Torrent) []Torrent {
+	key := EditionKey(t)
+	edition := make([]Torrent, 0)
+	for _, other := range g.Torrents {
+		if EditionKey(other) == key {
+			edition = append(edition, other)
+		}
+	}
+	return edition
+}
+
+// DerivableFormats returns the formats that may be transcoded from a
+// torrent. Only lossless sources can be transcoded.
+func DerivableFormats(t Torrent) []string {
+	name := FormatName(t)
+	for i, f := range Formats {
+		if f == name && strings.HasPrefix(f, "FLAC") {
+			return Formats[i+1:]
+		}
+	}
+	return nil
+}
+
+// MissingFormats returns the formats that can be made from t but are not
+// yet present in its edition.
+func (g GroupAndTorrents) MissingFormats(t Torrent) []string {
+	present := make(map[string]bool)
+	for _, other := range g.Edition(t) {
+		present[FormatName(other)] = true
+	}
+	missing := make([]string, 0)
+	for _, f := range DerivableFormats(t) {
+		if !present[f] {
+			missing = append(missing, f)
+		}
+	}
+	return missing
+}
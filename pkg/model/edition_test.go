@@ -0,0 +1,76 @@
+package model
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestEditionKey(t *testing.T) {
+	original := Torrent{Media: "CD", Format: "FLAC", Encoding: "Lossless"}
+	remaster := Torrent{Media: "CD", Remastered: true, RemasterYear: 2011, RemasterTitle: "Deluxe", RemasterRecordLabel: "Warp", RemasterCatalogueNumber: "WARP101"}
+
+	tests := []struct {
+		a, b     Torrent
+		expected bool
+	}{
+		{original, Torrent{Media: "CD", Format: "MP3", Encoding: "320"}, true},
+		{original, Torrent{Media: "Vinyl"}, false},
+		{original, remaster, false},
+		{remaster, Torrent{Media: "CD", Remastered: true, RemasterYear: 2011, RemasterTitle: "Deluxe", RemasterRecordLabel: "Warp", RemasterCatalogueNumber: "WARP101", Format: "MP3"}, true},
+		{remaster, Torrent{Media: "CD", Remastered: true, RemasterYear: 2011, RemasterTitle: "Deluxe", RemasterRecordLabel: "Warp"}, false},
+		{remaster, Torrent{Media: "WEB", Remastered: true, RemasterYear: 2011, RemasterTitle: "Deluxe", RemasterRecordLabel: "Warp", RemasterCatalogueNumber: "WARP101"}, false},
+	}
+	for i, test := range tests {
+		if same := EditionKey(test.a) == EditionKey(test.b); same != test.expected {
+			t.Errorf("%d: expected same edition %v for %q and %q", i, test.expected, EditionKey(test.a), EditionKey(test.b))
+		}
+	}
+}
+
+func TestDerivableFormats(t *testing.T) {
+	tests := []struct {
+		format, encoding string
+		expected         []string
+	}{
+		{"FLAC", "24bit Lossless", []string{"FLAC Lossless", "MP3 320", "MP3 V0 (VBR)"}},
+		{"FLAC", "Lossless", []string{"MP3 320", "MP3 V0 (VBR)"}},
+		{"MP3", "320", nil},
+		{"MP3", "V0 (VBR)", nil},
+		{"AAC", "256", nil},
+	}
+	for _, test := range tests {
+		formats := DerivableFormats(Torrent{Format: FlexString(test.format), Encoding: FlexString(test.encoding)})
+		if !reflect.DeepEqual(formats, test.expected) {
+			t.Errorf("%s %s: expected %v, got %v", test.format, test.encoding, test.expected, formats)
+		}
+	}
+}
+
+func TestMissingFormats(t *testing.T) {
+	flac := Torrent{ID: 1, Media: "CD", Format: "FLAC", Encoding: "Lossless"}
+	v0 := Torrent{ID: 2, Media: "CD", Format: "MP3", Encoding: "V0 (VBR)"}
+	vinyl320 := Torrent{ID: 3, Media: "Vinyl", Format: "MP3", Encoding: "320"}
+	hires := Torrent{ID: 4, Media: "Vinyl", Format: "FLAC", Encoding: "24bit Lossless"}
+
+	tests := []struct {
+		torrents []Torrent
+		t        Torrent
+		expected []string
+	}{
+		{[]Torrent{flac}, flac, []string{"MP3 320", "MP3 V0 (VBR)"}},
+		{[]Torrent{flac, v0}, flac, []string{"MP3 320"}},
+		{[]Torrent{flac, v0, vinyl320}, flac, []string{"MP3 320"}},
+		{[]Torrent{flac, v0, vinyl320, hires}, hires, []string{"FLAC Lossless", "MP3 V0 (VBR)"}},
+		{[]Torrent{flac, v0}, v0, []string{}},
+	}
+	for i, test := range tests {
+		g := GroupAndTorrents{Torrents: test.torrents}
+		if missing := g.MissingFormats(test.t); !reflect.DeepEqual(missing, test.expected) {
+			t.Errorf("%d: expected %v, got %v", i, test.expected, missing)
+		}
+	}
+	g := GroupAndTorrents{Torrents: []Torrent{flac, v0, vinyl320, hires}}
+	if edition := g.Edition(hires); len(edition) != 2 || edition[0].ID != 3 {
+		t.Errorf("unexpected edition: %v", edition)
+	}
+}
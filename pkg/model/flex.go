@@ -0,0 +1,146 @@
+package model
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// The Gazelle API is not very strict with its JSON types: depending on the
+// endpoint the same field is returned as a number, a numeric string, a
+// boolean or a stringly-typed "0"/"1" boolean (see the collage API).
+// The flexible types below accept all of these transparently and always
+// encode to their canonical JSON type again.
+
+// FlexInt is an int that can be decoded from numbers, numeric strings and
+// booleans.
+type FlexInt int
+
+// FlexFloat is a float64 that can be decoded from numbers and numeric strings.
+type FlexFloat float64
+
+// FlexBool is a bool that can be decoded from booleans, numbers and
+// strings like "0", "1", "true" or "false".
+type FlexBool bool
+
+// FlexString is a string that can be decoded from strings, numbers and
+// booleans.
+type FlexString string
+
+// flexLiteral returns the raw JSON value as unquoted string and whether it
+// was quoted. JSON null is returned as empty string.
+func flexLiteral(b []byte) (string, bool, error) {
+	b = bytes.TrimSpace(b)
+	if len(b) == 0 || string(b) == "null" {
+		return "", false, nil
+	}
+	if b[0] != '"' {
+		return string(b), false, nil
+	}
+	var s string
+	err := json.Unmarshal(b, &s)
+	return s, true, err
+}
+
+func (i *FlexInt) UnmarshalJSON(b []byte) error {
+	s, _, err := flexLiteral(b)
+	if err != nil {
+		return err
+	}
+	s = strings.TrimSpace(s)
+	switch s {
+	case "", "false":
+		*i = 0
+		return nil
+	case "true":
+		*i = 1
+		return nil
+	}
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+		*i = FlexInt(n)
+		return nil
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return fmt.Errorf("model: cannot decode %s as int", b)
+	}
+	*i = FlexInt(f)
+	return nil
+}
+
+func (f *FlexFloat) UnmarshalJSON(b []byte) error {
+	s, _, err := flexLiteral(b)
+	if err != nil {
+		return err
+	}
+	s = strings.TrimSpace(s)
+	if s == "" {
+		*f = 0
+		return nil
+	}
+	n, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return fmt.Errorf("model: cannot decode %s as float", b)
+	}
+	*f = FlexFloat(n)
+	return nil
+}
+
+func (v *FlexBool) UnmarshalJSON(b []byte) error {
+	s, _, err := flexLiteral(b)
+	if err != nil {
+		return err
+	}
+	s = strings.TrimSpace(s)
+	switch strings.ToLower(s) {
+	case "", "0", "false", "no", "off":
+		*v = false
+	case "1", "true", "yes", "on":
+		*v = true
+	default:
+		n, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return fmt.Errorf("model: cannot decode %s as bool", b)
+		}
+		*v = n != 0
+	}
+	return nil
+}
+
+// Scan implements sql.Scanner, as some databases such as SQLite store
+// booleans as integers.
+func (v *FlexBool) Scan(src interface{}) error {
+	switch s := src.(type) {
+	case nil:
+		*v = false
+	case bool:
+		*v = FlexBool(s)
+	case int64:
+		*v = s != 0
+	case []byte:
+		return v.UnmarshalJSON(s)
+	case string:
+		return v.UnmarshalJSON([]byte(s))
+	default:
+		return fmt.Errorf("model: cannot scan %T as bool", src)
+	}
+	return nil
+}
+
+func (v *FlexString) UnmarshalJSON(b []byte) error {
+	s, quoted, err := flexLiteral(b)
+	if err != nil {
+		return err
+	}
+	if !quoted && (strings.HasPrefix(s, "{") || strings.HasPrefix(s, "[")) {
+		return fmt.Errorf("model: cannot decode %s as string", b)
+	}
+	*v = FlexString(s)
+	return nil
+}
+
+func (v FlexString) String() string {
+	return string(v)
+}
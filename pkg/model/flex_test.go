@@ -0,0 +1,159 @@
+package model
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jinzhu/gorm"
+	_ "github.com/jinzhu/gorm/dialects/sqlite"
+)
+
+func openTestIndex(t *testing.T) (*gorm.DB, func()) {
+	dir, err := ioutil.TempDir("", "model")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := gorm.Open("sqlite3", filepath.Join(dir, "index.db"))
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	return db, func() {
+		db.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+func TestFlexUnmarshal(t *testing.T) {
+	tests := []struct {
+		json   string
+		int    FlexInt
+		float  FlexFloat // booleans are no valid floats
+		bool   FlexBool
+		string FlexString
+	}{
+		{`42`, 42, 42, true, "42"},
+		{`"42"`, 42, 42, true, "42"},
+		{`1.5`, 1, 1.5, true, "1.5"},
+		{`"2.75"`, 2, 2.75, true, "2.75"},
+		{`""`, 0, 0, false, ""},
+		{`null`, 0, 0, false, ""},
+		{`true`, 1, -1, true, "true"},
+		{`false`, 0, -1, false, "false"},
+		{`"true"`, 1, -1, true, "true"},
+		{`"0"`, 0, 0, false, "0"},
+		{`"1"`, 1, 1, true, "1"},
+		{`0`, 0, 0, false, "0"},
+	}
+	for _, test := range tests {
+		var i FlexInt
+		var f FlexFloat
+		var b FlexBool
+		var s FlexString
+		raw := []byte(test.json)
+		if err := i.UnmarshalJSON(raw); err != nil || i != test.int {
+			t.Errorf("FlexInt %s: expected %v, got %v (%v)", test.json, test.int, i, err)
+		}
+		if err := f.UnmarshalJSON(raw); test.float < 0 && err == nil {
+			t.Errorf("FlexFloat %s: expected error, got %v", test.json, f)
+		} else if test.float >= 0 && (err != nil || f != test.float) {
+			t.Errorf("FlexFloat %s: expected %v, got %v (%v)", test.json, test.float, f, err)
+		}
+		if err := b.UnmarshalJSON(raw); err != nil || b != test.bool {
+			t.Errorf("FlexBool %s: expected %v, got %v (%v)", test.json, test.bool, b, err)
+		}
+		if err := s.UnmarshalJSON(raw); err != nil || s != test.string {
+			t.Errorf("FlexString %s: expected %q, got %q (%v)", test.json, test.string, s, err)
+		}
+	}
+
+	invalid := []struct {
+		json string
+		v    json.Unmarshaler
+	}{
+		{`"abc"`, new(FlexInt)},
+		{`{}`, new(FlexInt)},
+		{`"abc"`, new(FlexFloat)},
+		{`true`, new(FlexFloat)},
+		{`"maybe"`, new(FlexBool)},
+		{`[1]`, new(FlexBool)},
+		{`{"a": 1}`, new(FlexString)},
+		{`[]`, new(FlexString)},
+		{`"unterminated`, new(FlexString)},
+	}
+	for _, test := range invalid {
+		if err := test.v.UnmarshalJSON([]byte(test.json)); err == nil {
+			t.Errorf("%T %s: expected error", test.v, test.json)
+		}
+	}
+}
+
+func TestIndexRoundTrip(t *testing.T) {
+	db, cleanup := openTestIndex(t)
+	defer cleanup()
+	if err := db.AutoMigrate(Torrent{}, Group{}, Collage{}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	torrent := Torrent{ID: 2, GroupID: 1, Format: "FLAC", Remastered: true, HasLog: true, LogScore: 100}
+	group := Group{ID: 1, Name: "Album", Year: 2017, VanityHouse: true, Tags: []string{"rock"}}
+	collage := Collage{ID: 3, Name: "Best of 2017", Locked: true}
+	for _, m := range []interface{}{&torrent, &group, &collage} {
+		if err := db.Create(m).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var t2 Torrent
+	if err := db.First(&t2, 2).Error; err != nil {
+		t.Fatal(err)
+	}
+	if !t2.Remastered || !t2.HasLog || t2.Scene || t2.LogScore != 100 || t2.Format != "FLAC" {
+		t.Errorf("unexpected torrent: %#v", t2)
+	}
+
+	var g2 Group
+	if err := db.First(&g2, 1).Error; err != nil {
+		t.Fatal(err)
+	}
+	if !g2.VanityHouse || g2.IsBookmarked || g2.Name != "Album" || len(g2.Tags) != 1 {
+		t.Errorf("unexpected group: %#v", g2)
+	}
+
+	var c2 Collage
+	if err := db.Where("locked = ?", true).First(&c2).Error; err != nil {
+		t.Fatal(err)
+	}
+	if c2.ID != 3 || c2.Deleted {
+		t.Errorf("unexpected collage: %#v", c2)
+	}
+}
+
+func TestFlexBoolScan(t *testing.T) {
+	tests := []struct {
+		src      interface{}
+		expected FlexBool
+	}{
+		{nil, false},
+		{true, true},
+		{int64(0), false},
+		{int64(1), true},
+		{[]byte("1"), true},
+		{"false", false},
+	}
+	for _, test := range tests {
+		var v FlexBool = !test.expected
+		err := v.Scan(test.src)
+		if err != nil || v != test.expected {
+			t.Errorf("Scan(%#v): expected %v, got %v (%v)", test.src, test.expected, v, err)
+		}
+	}
+	for _, src := range []interface{}{"maybe", 1.5} {
+		if err := new(FlexBool).Scan(src); err == nil {
+			t.Errorf("Scan(%#v): expected error", src)
+		}
+	}
+}
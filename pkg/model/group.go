@@ -3,25 +3,35 @@ package model
 import (
 	"encoding/json"
 	"fmt"
+	"strings"
 )
 
 type Group struct {
-	WikiBody            string    `json:"wikiBody"`
-	WikiImage           string    `json:"wikiImage"`
-	ID                  int       `json:"id"`
-	Name                string    `json:"name"`
-	Year                int       `json:"year"`
-	RecordLabel         string    `json:"recordLabel"`
-	CatalogueNumber     string    `json:"catalogueNumber"`
-	ReleaseType         int       `json:"releaseType"`
-	CategoryID          int       `json:"categoryId"`
-	CategoryName        string    `json:"categoryName"`
-	Time                string    `json:"time"`
-	VanityHouse         bool      `json:"vanityHouse"`
-	MusicInfo           MusicInfo `json:"musicInfo" sql:"-"`
-	MusicInfoSerialized []byte    `json:"-" gorm:"type:text"`
-	Tags                []string  `json:"tags" sql:"-"`
-	TagsSerialized      []byte    `json:"-" gorm:"type:text"`
+	WikiBody            FlexString `json:"wikiBody"`
+	WikiImage           FlexString `json:"wikiImage"`
+	ID                  FlexInt    `json:"id"`
+	Name                FlexString `json:"name"`
+	Year                FlexInt    `json:"year"`
+	RecordLabel         FlexString `json:"recordLabel"`
+	CatalogueNumber     FlexString `json:"catalogueNumber"`
+	ReleaseType         FlexInt    `json:"releaseType"`
+	CategoryID          FlexInt    `json:"categoryId"`
+	CategoryName        FlexString `json:"categoryName"`
+	Time                FlexString `json:"time"`
+	VanityHouse         FlexBool   `json:"vanityHouse"`
+	IsBookmarked        FlexBool   `json:"isBookmarked"`
+	Category            Category   `json:"category,omitempty"`
+	MusicInfo           MusicInfo  `json:"musicInfo" sql:"-"`
+	MusicInfoSerialized []byte     `json:"-" gorm:"type:text"`
+	BookInfo            *BookInfo  `json:"bookInfo,omitempty" sql:"-"`
+	BookInfoSerialized  []byte     `json:"-" gorm:"type:text"`
+	VideoInfo           *VideoInfo `json:"videoInfo,omitempty" sql:"-"`
+	VideoInfoSerialized []byte     `json:"-" gorm:"type:text"`
+	Tags                []string   `json:"tags" sql:"-"`
+	TagsSerialized      []byte     `json:"-" gorm:"type:text"`
+
+	// The collage API returns tags as a single space-separated "tagList"
+	TagList FlexString `json:"tagList,omitempty" sql:"-"`
 }
 
 func (g *Group) BeforeSave() (err error) {
@@ -34,6 +44,44 @@ func (g *Group) BeforeSave() (err error) {
 		g.TagsSerialized = nil
 	}
 	g.MusicInfoSerialized, err = json.Marshal(g.MusicInfo)
+	if err != nil {
+		return err
+	}
+	g.BookInfoSerialized, g.VideoInfoSerialized = nil, nil
+	if g.BookInfo != nil {
+		if g.BookInfoSerialized, err = json.Marshal(g.BookInfo); err != nil {
+			return err
+		}
+	}
+	if g.VideoInfo != nil {
+		g.VideoInfoSerialized, err = json.Marshal(g.VideoInfo)
+	}
+	return err
+}
+
+// AfterFind restores the serialized fields after loading a group from the
+// index database.
+func (g *Group) AfterFind() (err error) {
+	if len(g.TagsSerialized) > 0 {
+		if err = json.Unmarshal(g.TagsSerialized, &g.Tags); err != nil {
+			return err
+		}
+	}
+	if len(g.MusicInfoSerialized) > 0 {
+		if err = json.Unmarshal(g.MusicInfoSerialized, &g.MusicInfo); err != nil {
+			return err
+		}
+	}
+	if len(g.BookInfoSerialized) > 0 {
+		g.BookInfo = &BookInfo{}
+		if err = json.Unmarshal(g.BookInfoSerialized, g.BookInfo); err != nil {
+			return err
+		}
+	}
+	if len(g.VideoInfoSerialized) > 0 {
+		g.VideoInfo = &VideoInfo{}
+		err = json.Unmarshal(g.VideoInfoSerialized, g.VideoInfo)
+	}
 	return err
 }
 
@@ -48,17 +96,21 @@ type MusicInfo struct {
 }
 
 type ArtistLink struct {
-	ID   int    `json:"id"`
-	Name string `json:"name"`
+	ID   FlexInt    `json:"id"`
+	Name FlexString `json:"name"`
 }
 
 func (g Group) String() string {
+	if g.BookInfo != nil && len(g.BookInfo.Authors) > 0 {
+		return fmt.Sprintf("group %d: %s - %s", g.ID, strings.Join(g.BookInfo.Authors, ", "), g.Name)
+	}
+
 	artist := "Various Artists"
 	n := len(g.MusicInfo.Artists)
 	if n == 0 {
 		artist = "Unknown"
 	} else if n == 1 {
-		artist = g.MusicInfo.Artists[0].Name
+		artist = string(g.MusicInfo.Artists[0].Name)
 	}
 
 	return fmt.Sprintf("group %d: %s - %s", g.ID, artist, g.Name)
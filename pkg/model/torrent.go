@@ -3,32 +3,40 @@ package model
 import "fmt"
 
 type Torrent struct {
-	ID                      int    `json:"id"`
-	GroupID                 int    `json:"groupID"`
-	Media                   string `json:"media"`
-	Format                  string `json:"format"`
-	Encoding                string `json:"encoding"`
-	Remastered              bool   `json:"remastered"`
-	RemasterYear            int    `json:"remasterYear"`
-	RemasterTitle           string `json:"remasterTitle"`
-	RemasterRecordLabel     string `json:"remasterRecordLabel"`
-	RemasterCatalogueNumber string `json:"remasterCatalogueNumber"`
-	Scene                   bool   `json:"scene"`
-	HasLog                  bool   `json:"hasLog"`
-	HasCue                  bool   `json:"hasCue"`
-	LogScore                int    `json:"logScore"`
-	FileCount               int    `json:"fileCount"`
-	Size                    int    `json:"size"`
-	Seeders                 int    `json:"seeders"`
-	Leechers                int    `json:"leechers"`
-	Snatched                int    `json:"snatched"`
-	FreeTorrent             bool   `json:"freeTorrent"`
-	Time                    string `json:"time"`
-	Description             string `json:"description"`
-	FileList                string `json:"fileList"`
-	FilePath                string `json:"filePath"`
-	UserID                  int    `json:"userID"`
-	Username                string `json:"username"`
+	ID                      FlexInt    `json:"id"`
+	GroupID                 FlexInt    `json:"groupID"`
+	Media                   FlexString `json:"media"`
+	Format                  FlexString `json:"format"`
+	Encoding                FlexString `json:"encoding"`
+	Remastered              FlexBool   `json:"remastered"`
+	RemasterYear            FlexInt    `json:"remasterYear"`
+	RemasterTitle           FlexString `json:"remasterTitle"`
+	RemasterRecordLabel     FlexString `json:"remasterRecordLabel"`
+	RemasterCatalogueNumber FlexString `json:"remasterCatalogueNumber"`
+	Scene                   FlexBool   `json:"scene"`
+	HasLog                  FlexBool   `json:"hasLog"`
+	HasCue                  FlexBool   `json:"hasCue"`
+	LogScore                FlexInt    `json:"logScore"`
+	LogChecksum             FlexBool   `json:"logChecksum"`
+	FileCount               FlexInt    `json:"fileCount"`
+	Size                    FlexInt    `json:"size"`
+	Seeders                 FlexInt    `json:"seeders"`
+	Leechers                FlexInt    `json:"leechers"`
+	Snatched                FlexInt    `json:"snatched"`
+	FreeTorrent             FlexBool   `json:"freeTorrent"`
+	Reported                FlexBool   `json:"reported"`
+	Trumpable               FlexBool   `json:"trumpable"`
+	LossyWebApproved        FlexBool   `json:"lossyWebApproved"`
+	LossyMasterApproved     FlexBool   `json:"lossyMasterApproved"`
+	Time                    FlexString `json:"time"`
+	Description             FlexString `json:"description"`
+	FileList                FlexString `json:"fileList"`
+	FilePath                FlexString `json:"filePath"`
+	UserID                  FlexInt    `json:"userID"`
+	Username                FlexString `json:"username"`
+
+	// The collage API names the torrent ID "torrentid" instead of "id"
+	TorrentID FlexInt `json:"torrentid,omitempty" sql:"-"`
 }
 
 func (t Torrent) String() string {
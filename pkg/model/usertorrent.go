@@ -0,0 +1,15 @@
+package model
+
+// UserTorrent is an entry of a user's seeding, leeching, uploaded or
+// snatched list.
+type UserTorrent struct {
+	GroupID    FlexInt    `json:"groupId"`
+	Name       FlexString `json:"name"`
+	TorrentID  FlexInt    `json:"torrentId"`
+	ArtistName FlexString `json:"artistName"`
+	ArtistID   FlexInt    `json:"artistId"`
+}
+
+// UserTorrentTypes lists the types of user torrent lists, in the order in
+// which they are reported when a torrent is in more than one.
+var UserTorrentTypes = []string{"seeding", "leeching", "uploaded", "snatched"}
@@ -0,0 +1,110 @@
+// Author: EmotionalDots @ PTH
+//
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package report
+
+import (
+	"fmt"
+	html "html/template"
+	"io"
+	"strings"
+	text "text/template"
+)
+
+var funcs = map[string]interface{}{
+	"date": func(r *Report) string { return r.Generated.Format("2006-01-02 15:04") },
+	// md escapes characters with a meaning in Markdown tables
+	"md": func(s string) string {
+		return strings.NewReplacer("|", "\\|", "*", "\\*", "_", "\\_", "[", "\\[", "]", "\\]", "`", "\\`").Replace(s)
+	},
+	"torrent": func(m Match) string { return fmt.Sprintf("%s:%d", m.Source, m.Id) },
+}
+
+var htmlTemplate = html.Must(html.New("html").Funcs(funcs).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #222; }
+table { border-collapse: collapse; width: 100%; margin-bottom: 2em; }
+th, td { border: 1px solid #ccc; padding: 4px 8px; text-align: left; vertical-align: top; }
+th { background: #eee; }
+.ok { color: #2a7a2a; }
+.renamed { color: #b36b00; }
+.no_filepath { color: #777; }
+.error { color: #b00; }
+small { color: #666; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<p><small>Generated {{date .}}: {{len .Matched}} matched, {{len .Unmatched}} unmatched, {{len .Pending}} pending releases</small></p>
+
+<h2>Sources</h2>
+<table>
+<tr><th>Source</th><th>Matches</th><th>Renamed</th><th>No file path</th><th>Downloaded</th></tr>
+{{range .Sources}}<tr><td>{{.Source}}</td><td>{{.Matches}}</td><td>{{.Renamed}}</td><td>{{.NoFilePath}}</td><td>{{.Downloaded}}</td></tr>
+{{end}}</table>
+
+<h2>Matched releases</h2>
+<table>
+<tr><th>Local path</th><th>Torrent</th><th>State</th><th>Torrent path</th></tr>
+{{range .Matched}}{{$r := .}}{{range .Matches}}<tr>
+<td>{{$r.Path}}</td>
+<td>{{if .URL}}<a href="{{.URL}}">{{torrent .}}</a>{{else}}{{torrent .}}{{end}}{{if .Downloaded}} &#10003;{{end}}{{if .Description}}<br><small>{{.Description}}</small>{{end}}</td>
+<td class="{{.Status}}">{{.Status}}</td>
+<td>{{.FilePath}}</td>
+</tr>
+{{end}}{{end}}</table>
+
+<h2>Unmatched releases</h2>
+<table>
+<tr><th>Local path</th><th>Hash</th></tr>
+{{range .Unmatched}}<tr><td>{{.Path}}</td><td><small>{{.Hash}}</small></td></tr>
+{{end}}</table>
+{{if .Pending}}
+<h2>Pending releases</h2>
+<table>
+<tr><th>Local path</th><th>Errors</th></tr>
+{{range .Pending}}<tr><td>{{.Path}}</td><td class="error">{{range .Errors}}{{.}}<br>{{end}}</td></tr>
+{{end}}</table>
+{{end}}
+</body>
+</html>
+`))
+
+var markdownTemplate = text.Must(text.New("md").Funcs(funcs).Parse(`# {{md .Title}}
+
+Generated {{date .}}: {{len .Matched}} matched, {{len .Unmatched}} unmatched, {{len .Pending}} pending releases
+
+## Sources
+
+| Source | Matches | Renamed | No file path | Downloaded |
+|---|---|---|---|---|
+{{range .Sources}}| {{md .Source}} | {{.Matches}} | {{.Renamed}} | {{.NoFilePath}} | {{.Downloaded}} |
+{{end}}
+## Matched releases
+
+| Local path | Torrent | State | Torrent path |
+|---|---|---|---|
+{{range .Matched}}{{$r := .}}{{range .Matches}}| {{md $r.Path}} | {{if .URL}}[{{torrent .}}]({{.URL}}){{else}}{{torrent .}}{{end}}{{if .Downloaded}} ✓{{end}}{{if .Description}}<br>{{md .Description}}{{end}} | {{.Status}} | {{md .FilePath}} |
+{{end}}{{end}}
+## Unmatched releases
+
+{{range .Unmatched}}- {{md .Path}}
+{{end}}{{if .Pending}}
+## Pending releases
+
+{{range .Pending}}- {{md .Path}}{{range .Errors}} ({{md .}}){{end}}
+{{end}}{{end}}`))
+
+func (r *Report) WriteHTML(w io.Writer) error {
+	return htmlTemplate.Execute(w, r)
+}
+
+func (r *Report) WriteMarkdown(w io.Writer) error {
+	return markdownTemplate.Execute(w, r)
+}
@@ -0,0 +1,148 @@
+// Author: EmotionalDots @ PTH
+//
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+// Package report renders the results of library scans ("downthemall" or
+// "lookup" state files) as self-contained HTML or Markdown.
+package report
+
+import (
+	"sort"
+	"time"
+
+	"github.com/emotionaldots/arbitrage/pkg/arbitrage"
+)
+
+type Match struct {
+	Source      string
+	Id          int64
+	FilePath    string
+	Status      string // ok, renamed or no_filepath
+	Downloaded  bool
+	URL         string
+	Description string
+}
+
+type Release struct {
+	Path    string
+	Name    string
+	Hash    string
+	Matches []Match
+	Errors  []string
+}
+
+type SourceStats struct {
+	Source     string
+	Matches    int
+	Renamed    int
+	NoFilePath int
+	Downloaded int
+}
+
+type Report struct {
+	Title     string
+	Generated time.Time
+	Sources   []SourceStats
+
+	Matched   []Release
+	Unmatched []Release
+	Pending   []Release // not yet queried
+}
+
+type Options struct {
+	Title string
+
+	// Link returns the URL of a torrent page on the tracker.
+	Link func(source string, id int64) string
+
+	// Describe returns a description of a torrent, e.g. from the server's
+	// metadata, or an empty string if unknown.
+	Describe func(source string, id int64) string
+}
+
+// New merges the state files of one or more sources into a report.
+func New(states []*arbitrage.State, opts Options) *Report {
+	r := &Report{Title: opts.Title, Generated: time.Now()}
+	if r.Title == "" {
+		r.Title = "arbitrage report"
+	}
+
+	byPath := make(map[string]*Release)
+	queried := make(map[string]bool)
+	stats := make(map[string]*SourceStats)
+
+	for _, st := range states {
+		ss, ok := stats[st.Source]
+		if !ok {
+			ss = &SourceStats{Source: st.Source}
+			stats[st.Source] = ss
+		}
+
+		for _, sr := range st.Sorted() {
+			rel, ok := byPath[sr.Path]
+			if !ok {
+				rel = &Release{Path: sr.Path, Name: sr.Name, Hash: sr.Hash}
+				byPath[sr.Path] = rel
+			}
+			rel.Errors = append(rel.Errors, sr.Errors...)
+			if sr.Queried {
+				queried[sr.Path] = true
+			}
+
+			for _, m := range sr.Matches {
+				match := Match{
+					Source:     st.Source,
+					Id:         m.Id,
+					FilePath:   m.FilePath,
+					Status:     m.Status,
+					Downloaded: sr.HasDownloaded(m.Id),
+				}
+				if match.FilePath == "" {
+					match.Status = "no_filepath"
+				}
+				if opts.Link != nil {
+					match.URL = opts.Link(st.Source, m.Id)
+				}
+				if opts.Describe != nil {
+					match.Description = opts.Describe(st.Source, m.Id)
+				}
+				rel.Matches = append(rel.Matches, match)
+
+				ss.Matches++
+				switch match.Status {
+				case "renamed":
+					ss.Renamed++
+				case "no_filepath":
+					ss.NoFilePath++
+				}
+				if match.Downloaded {
+					ss.Downloaded++
+				}
+			}
+		}
+	}
+
+	paths := make([]string, 0, len(byPath))
+	for p := range byPath {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	for _, p := range paths {
+		rel := byPath[p]
+		switch {
+		case len(rel.Matches) > 0:
+			r.Matched = append(r.Matched, *rel)
+		case queried[p]:
+			r.Unmatched = append(r.Unmatched, *rel)
+		default:
+			r.Pending = append(r.Pending, *rel)
+		}
+	}
+
+	for _, ss := range stats {
+		r.Sources = append(r.Sources, *ss)
+	}
+	sort.Slice(r.Sources, func(i, j int) bool { return r.Sources[i].Source < r.Sources[j].Source })
+	return r
+}
@@ -0,0 +1,54 @@
+package report
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/emotionaldots/arbitrage/pkg/arbitrage"
+)
+
+func TestReport(t *testing.T) {
+	st := &arbitrage.State{Source: "red", Releases: map[string]*arbitrage.StateRelease{
+		"/music/Album": {
+			Path: "/music/Album", Name: "Album", Queried: true,
+			Matches:    []arbitrage.StateMatch{{Id: 12, FilePath: "Artist - Album [FLAC]", Status: "renamed"}},
+			Downloaded: []int64{12},
+		},
+		"/music/<Other>": {Path: "/music/<Other>", Name: "<Other>", Queried: true},
+		"/music/Broken":  {Path: "/music/Broken", Errors: []string{"permission denied"}},
+	}}
+
+	r := New([]*arbitrage.State{st}, Options{
+		Link: func(source string, id int64) string {
+			return fmt.Sprintf("https://tracker.test/torrents.php?torrentid=%d", id)
+		},
+		Describe: func(source string, id int64) string { return "Artist - Album [FLAC]" },
+	})
+	if len(r.Matched) != 1 || len(r.Unmatched) != 1 || len(r.Pending) != 1 {
+		t.Fatalf("Unexpected grouping: %+v", r)
+	}
+	if s := r.Sources[0]; s.Matches != 1 || s.Renamed != 1 || s.Downloaded != 1 {
+		t.Errorf("Unexpected stats: %+v", s)
+	}
+
+	var buf bytes.Buffer
+	if err := r.WriteHTML(&buf); err != nil {
+		t.Fatal(err)
+	}
+	html := buf.String()
+	for _, s := range []string{`href="https://tracker.test/torrents.php?torrentid=12"`, "&lt;Other&gt;", "permission denied", `class="renamed"`} {
+		if !strings.Contains(html, s) {
+			t.Errorf("HTML report is missing %q", s)
+		}
+	}
+
+	buf.Reset()
+	if err := r.WriteMarkdown(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "[red:12](https://tracker.test/torrents.php?torrentid=12) ✓") {
+		t.Errorf("Markdown report is missing link:\n%s", buf.String())
+	}
+}
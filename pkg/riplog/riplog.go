@@ -0,0 +1,325 @@
+// Author: EmotionalDots @ PTH
+//
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+// Package riplog parses EAC and XLD rip logs and scores them similar to
+// the Gazelle log checker.
+package riplog
+
+import (
+	"bytes"
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+const (
+	EAC = "EAC"
+	XLD = "XLD"
+)
+
+var ErrUnknownRipper = errors.New("riplog: not an EAC or XLD log")
+
+type Track struct {
+	Number     int
+	TestCRC    string
+	CopyCRC    string
+	Suspicious bool
+
+	// AccurateRip is true if the track was accurately ripped, Confidence
+	// is the highest reported confidence.
+	AccurateRip bool
+	Confidence  int
+}
+
+// CRCMismatch returns whether test and copy CRC were both recorded and
+// differ.
+func (t Track) CRCMismatch() bool {
+	return t.TestCRC != "" && t.CopyCRC != "" && t.TestCRC != t.CopyCRC
+}
+
+type Deduction struct {
+	Reason string
+	Points int
+}
+
+type Log struct {
+	Ripper  string
+	Version string
+	Drive   string
+
+	ReadMode       string
+	ReadOffset     int
+	HasReadOffset  bool
+	DefeatCache    bool
+	C2Pointers     bool
+	NullSamplesCRC bool
+	Gaps           string
+	RangeRip       bool
+	Checksum       bool
+
+	Tracks []Track
+}
+
+// Decode converts a log to UTF-8. EAC writes UTF-16 logs, with or without
+// byte order mark.
+func Decode(raw []byte) string {
+	switch {
+	case bytes.HasPrefix(raw, []byte{0xff, 0xfe}):
+		return decodeUTF16(raw[2:], false)
+	case bytes.HasPrefix(raw, []byte{0xfe, 0xff}):
+		return decodeUTF16(raw[2:], true)
+	case bytes.HasPrefix(raw, []byte{0xef, 0xbb, 0xbf}):
+		return string(raw[3:])
+	case len(raw) >= 4 && raw[1] == 0 && raw[3] == 0 && raw[0] != 0:
+		return decodeUTF16(raw, false)
+	case len(raw) >= 4 && raw[0] == 0 && raw[2] == 0 && raw[1] != 0:
+		return decodeUTF16(raw, true)
+	}
+	if !utf8.Valid(raw) {
+		// assume Windows-1252/Latin-1 for old logs
+		runes := make([]rune, len(raw))
+		for i, b := range raw {
+			runes[i] = rune(b)
+		}
+		return string(runes)
+	}
+	return string(raw)
+}
+
+func decodeUTF16(raw []byte, bigEndian bool) string {
+	u := make([]uint16, len(raw)/2)
+	for i := range u {
+		if bigEndian {
+			u[i] = uint16(raw[2*i])<<8 | uint16(raw[2*i+1])
+		} else {
+			u[i] = uint16(raw[2*i+1])<<8 | uint16(raw[2*i])
+		}
+	}
+	return string(utf16.Decode(u))
+}
+
+var (
+	reEACTrack    = regexp.MustCompile(`^Track\s+(\d+)$`)
+	reXLDTrack    = regexp.MustCompile(`^Track (\d+)$`)
+	reConfidence  = regexp.MustCompile(`(?i)accurately ripped.*?confidence (\d+)`)
+	reEACVersion  = regexp.MustCompile(`^Exact Audio Copy (V\S+(?: \S+ \d+)?)`)
+	reXLDVersion  = regexp.MustCompile(`^X Lossless Decoder version (\S+)`)
+	reSuspicious  = regexp.MustCompile(`(?i)suspicious position`)
+	reErrorCount  = regexp.MustCompile(`(?i)^(read error|skipped \(treated as error\)|damaged sector count|inconsistency in error sectors)\s*:\s*(\d+)`)
+	reLeadingTabs = regexp.MustCompile(`^[\t ]+`)
+)
+
+// keyValue splits a "Key : Value" line.
+func keyValue(line string) (string, string, bool) {
+	parts := strings.SplitN(line, ":", 2)
+	if len(parts) != 2 {
+		return "", "", false
+	}
+	return strings.ToLower(strings.TrimSpace(parts[0])), strings.TrimSpace(parts[1]), true
+}
+
+func yes(v string) bool {
+	v = strings.ToLower(v)
+	return v == "yes" || v == "ok"
+}
+
+// Parse parses an EAC or XLD log. Logs of several rips in one file are
+// parsed as one.
+func Parse(raw []byte) (*Log, error) {
+	text := strings.Replace(Decode(raw), "\r\n", "\n", -1)
+	lines := strings.Split(text, "\n")
+
+	l := &Log{}
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		if m := reEACVersion.FindStringSubmatch(line); m != nil {
+			l.Ripper, l.Version = EAC, m[1]
+			break
+		}
+		if m := reXLDVersion.FindStringSubmatch(line); m != nil {
+			l.Ripper, l.Version = XLD, m[1]
+			break
+		}
+		if strings.HasPrefix(line, "EAC extraction logfile") {
+			l.Ripper = EAC
+			break
+		}
+		if strings.HasPrefix(line, "XLD extraction logfile") {
+			l.Ripper = XLD
+			break
+		}
+	}
+	if l.Ripper == "" {
+		return nil, ErrUnknownRipper
+	}
+
+	var track *Track
+	for _, line := range lines {
+		line = reLeadingTabs.ReplaceAllString(strings.TrimRight(line, " \t"), "")
+
+		if m := reEACTrack.FindStringSubmatch(line); l.Ripper == EAC && m != nil {
+			n, _ := strconv.Atoi(m[1])
+			l.Tracks = append(l.Tracks, Track{Number: n})
+			track = &l.Tracks[len(l.Tracks)-1]
+			continue
+		}
+		if m := reXLDTrack.FindStringSubmatch(line); l.Ripper == XLD && m != nil {
+			n, _ := strconv.Atoi(m[1])
+			l.Tracks = append(l.Tracks, Track{Number: n})
+			track = &l.Tracks[len(l.Tracks)-1]
+			continue
+		}
+
+		switch {
+		case strings.HasPrefix(line, "Range status and errors"):
+			l.RangeRip = true
+		case strings.HasPrefix(line, "==== Log checksum"), strings.HasPrefix(line, "-----BEGIN XLD SIGNATURE-----"):
+			l.Checksum = true
+		}
+
+		if track != nil {
+			if reSuspicious.MatchString(line) {
+				track.Suspicious = true
+			}
+			if m := reErrorCount.FindStringSubmatch(line); m != nil && m[2] != "0" {
+				track.Suspicious = true
+			}
+			if m := reConfidence.FindStringSubmatch(line); m != nil {
+				track.AccurateRip = true
+				if c, _ := strconv.Atoi(m[1]); c > track.Confidence {
+					track.Confidence = c
+				}
+			}
+			switch {
+			case strings.HasPrefix(line, "Test CRC "):
+				track.TestCRC = strings.TrimSpace(strings.TrimPrefix(line, "Test CRC "))
+				continue
+			case strings.HasPrefix(line, "Copy CRC "):
+				track.CopyCRC = strings.TrimSpace(strings.TrimPrefix(line, "Copy CRC "))
+				continue
+			}
+		}
+
+		key, value, ok := keyValue(line)
+		if !ok {
+			continue
+		}
+		switch key {
+		case "used drive":
+			l.Drive = value
+		case "read mode":
+			l.ReadMode = value
+		case "ripper mode":
+			l.ReadMode = value
+		case "defeat audio cache", "disable audio cache":
+			l.DefeatCache = yes(value)
+		case "make use of c2 pointers":
+			l.C2Pointers = yes(value)
+		case "read offset correction":
+			l.ReadOffset, _ = strconv.Atoi(value)
+			l.HasReadOffset = true
+		case "null samples used in crc calculations":
+			l.NullSamplesCRC = yes(value)
+		case "gap handling", "gap status":
+			l.Gaps = value
+		case "crc32 hash (test run)":
+			if track != nil {
+				track.TestCRC = value
+			}
+		case "crc32 hash":
+			if track != nil {
+				track.CopyCRC = value
+			}
+		}
+	}
+
+	return l, nil
+}
+
+// Deductions returns the problems of a log and their point deductions, as
+// used by the Gazelle log checker.
+func (l *Log) Deductions() []Deduction {
+	d := make([]Deduction, 0)
+	add := func(points int, reason string) {
+		d = append(d, Deduction{reason, points})
+	}
+
+	mode := strings.ToLower(l.ReadMode)
+	switch {
+	case l.Ripper == EAC && !strings.HasPrefix(mode, "secure"):
+		add(40, "Read mode is not secure")
+	case l.Ripper == XLD && !(strings.Contains(mode, "secure") || strings.Contains(mode, "paranoia")):
+		add(40, "Ripper mode is not secure")
+	}
+	if !l.DefeatCache {
+		add(10, "Audio cache not defeated")
+	}
+	if l.C2Pointers {
+		add(10, "C2 pointers were used")
+	}
+	if !l.HasReadOffset {
+		add(5, "Read offset correction not found")
+	}
+	if l.Ripper == EAC && !l.NullSamplesCRC {
+		add(5, "Null samples not used in CRC calculations")
+	}
+	gaps := strings.ToLower(l.Gaps)
+	if l.Gaps == "" || strings.Contains(gaps, "not detected") || strings.Contains(gaps, "not analyzed") {
+		add(10, "Gap handling was not detected")
+	}
+	if l.RangeRip {
+		add(30, "Range rip detected")
+	}
+	if !l.Checksum {
+		add(15, "Log checksum missing")
+	}
+
+	testCopy := len(l.Tracks) > 0
+	var mismatch, suspicious bool
+	for _, t := range l.Tracks {
+		if t.TestCRC == "" {
+			testCopy = false
+		}
+		mismatch = mismatch || t.CRCMismatch()
+		suspicious = suspicious || t.Suspicious
+	}
+	if !testCopy && !l.RangeRip {
+		add(10, "Test and copy was not used")
+	}
+	if mismatch {
+		add(30, "CRC mismatch between test and copy")
+	}
+	if suspicious {
+		add(20, "Suspicious positions or read errors")
+	}
+	return d
+}
+
+// Score returns the log score, 100 minus all deductions but at least 0,
+// like on Gazelle.
+func (l *Log) Score() int {
+	score := 100
+	for _, d := range l.Deductions() {
+		score -= d.Points
+	}
+	if score < 0 {
+		score = 0
+	}
+	return score
+}
+
+// AccurateRip returns the number of accurately ripped tracks.
+func (l *Log) AccurateRip() int {
+	n := 0
+	for _, t := range l.Tracks {
+		if t.AccurateRip {
+			n++
+		}
+	}
+	return n
+}
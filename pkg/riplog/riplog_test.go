@@ -0,0 +1,72 @@
+package riplog
+
+import (
+	"io/ioutil"
+	"testing"
+)
+
+func parseFile(t *testing.T, path string) *Log {
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := Parse(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return l
+}
+
+func TestParseEAC(t *testing.T) {
+	l := parseFile(t, "testdata/eac.log")
+	if l.Ripper != EAC || l.ReadMode != "Secure" || l.ReadOffset != 667 || !l.Checksum {
+		t.Errorf("Unexpected log: %+v", l)
+	}
+	if len(l.Tracks) != 2 || l.AccurateRip() != 2 || l.Tracks[1].Confidence != 4 {
+		t.Errorf("Unexpected tracks: %+v", l.Tracks)
+	}
+	if score := l.Score(); score != 100 {
+		t.Errorf("Expected score 100, got %d: %v", score, l.Deductions())
+	}
+}
+
+func TestParseXLDUTF16(t *testing.T) {
+	l := parseFile(t, "testdata/xld-utf16.log")
+	if l.Ripper != XLD || l.ReadOffset != 48 || !l.DefeatCache || l.C2Pointers || l.Checksum {
+		t.Errorf("Unexpected log: %+v", l)
+	}
+	if len(l.Tracks) != 2 || !l.Tracks[1].CRCMismatch() || !l.Tracks[1].Suspicious || l.AccurateRip() != 1 {
+		t.Errorf("Unexpected tracks: %+v", l.Tracks)
+	}
+
+	// checksum missing (15), CRC mismatch (30), read errors (20)
+	if score := l.Score(); score != 35 {
+		t.Errorf("Expected score 35, got %d: %v", score, l.Deductions())
+	}
+}
+
+func TestParseUnknown(t *testing.T) {
+	if _, err := Parse([]byte("just some text\n")); err != ErrUnknownRipper {
+		t.Errorf("Expected ErrUnknownRipper, got %v", err)
+	}
+}
+
+func TestScoreMinimum(t *testing.T) {
+	l := &Log{
+		Ripper:     EAC,
+		ReadMode:   "Burst",
+		C2Pointers: true,
+		RangeRip:   true,
+		Tracks:     []Track{{Number: 1, TestCRC: "AAAAAAAA", CopyCRC: "BBBBBBBB", Suspicious: true}},
+	}
+	total := 0
+	for _, d := range l.Deductions() {
+		total += d.Points
+	}
+	if total <= 100 {
+		t.Fatalf("Expected deductions over 100, got %d", total)
+	}
+	if score := l.Score(); score != 0 {
+		t.Errorf("Expected score 0, got %d", score)
+	}
+}
@@ -0,0 +1,114 @@
+// Author: EmotionalDots @ PTH
+//
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+// Package secrets implements a small encrypted key/value file for tracker
+// credentials, unlocked by a passphrase.
+//
+// The secrets are stored as JSON, encrypted with AES-256-GCM. The key is
+// derived from the passphrase with scrypt and a random salt.
+package secrets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+const version = 1
+
+var ErrWrongPassphrase = errors.New("secrets: wrong passphrase or corrupted file")
+
+type file struct {
+	Version int    `json:"version"`
+	Salt    []byte `json:"salt"`
+	Nonce   []byte `json:"nonce"`
+	Data    []byte `json:"data"`
+}
+
+func deriveKey(passphrase string, salt []byte) (cipher.AEAD, error) {
+	key, err := scrypt.Key([]byte(passphrase), salt, 1<<15, 8, 1, 32)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// Load decrypts the secrets file at path. A missing file is returned as
+// empty set of secrets.
+func Load(path, passphrase string) (map[string]string, error) {
+	secrets := make(map[string]string)
+	raw, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return secrets, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var f file
+	if err := json.Unmarshal(raw, &f); err != nil {
+		return nil, fmt.Errorf("secrets: %s: %s", path, err)
+	}
+	if f.Version != version {
+		return nil, fmt.Errorf("secrets: %s: unsupported version %d", path, f.Version)
+	}
+
+	aead, err := deriveKey(passphrase, f.Salt)
+	if err != nil {
+		return nil, err
+	}
+	plain, err := aead.Open(nil, f.Nonce, f.Data, nil)
+	if err != nil {
+		return nil, ErrWrongPassphrase
+	}
+	err = json.Unmarshal(plain, &secrets)
+	return secrets, err
+}
+
+// Save encrypts the secrets with a fresh salt and writes them to path,
+// readable only by the current user.
+func Save(path, passphrase string, secrets map[string]string) error {
+	if passphrase == "" {
+		return errors.New("secrets: empty passphrase")
+	}
+	plain, err := json.Marshal(secrets)
+	if err != nil {
+		return err
+	}
+
+	f := file{Version: version, Salt: make([]byte, 16)}
+	if _, err := rand.Read(f.Salt); err != nil {
+		return err
+	}
+	aead, err := deriveKey(passphrase, f.Salt)
+	if err != nil {
+		return err
+	}
+	f.Nonce = make([]byte, aead.NonceSize())
+	if _, err := rand.Read(f.Nonce); err != nil {
+		return err
+	}
+	f.Data = aead.Seal(nil, f.Nonce, plain, nil)
+
+	raw, err := json.Marshal(f)
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(path, raw, 0600); err != nil {
+		return err
+	}
+	// WriteFile keeps the permissions of existing files
+	return os.Chmod(path, 0600)
+}
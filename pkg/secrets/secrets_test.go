@@ -0,0 +1,126 @@
+// Author: EmotionalDots @ PTH
+//
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package secrets
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func tempPath(t *testing.T) (string, func()) {
+	dir, err := ioutil.TempDir("", "secrets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return filepath.Join(dir, "secrets.json"), func() { os.RemoveAll(dir) }
+}
+
+func TestRoundTrip(t *testing.T) {
+	path, cleanup := tempPath(t)
+	defer cleanup()
+
+	s, err := Load(path, "correct horse")
+	if err != nil || len(s) != 0 {
+		t.Fatalf("expected empty secrets for missing file, got %v %v", s, err)
+	}
+
+	expected := map[string]string{"red": "hunter2", "apl": "pässwörd"}
+	if err := Save(path, "correct horse", expected); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("expected mode 0600, got %s", info.Mode())
+	}
+	raw, _ := ioutil.ReadFile(path)
+	if !json.Valid(raw) {
+		t.Fatalf("unexpected file: %s", raw)
+	}
+
+	s, err = Load(path, "correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(s, expected) {
+		t.Errorf("expected %v, got %v", expected, s)
+	}
+
+	if err := Save(path, "", expected); err == nil {
+		t.Error("expected error for empty passphrase")
+	}
+}
+
+func TestWrongPassphrase(t *testing.T) {
+	path, cleanup := tempPath(t)
+	defer cleanup()
+
+	if err := Save(path, "correct horse", map[string]string{"red": "hunter2"}); err != nil {
+		t.Fatal(err)
+	}
+	if s, err := Load(path, "battery staple"); err != ErrWrongPassphrase || s != nil {
+		t.Errorf("expected ErrWrongPassphrase, got %v %v", s, err)
+	}
+}
+
+func TestCorruptedFile(t *testing.T) {
+	path, cleanup := tempPath(t)
+	defer cleanup()
+
+	if err := Save(path, "correct horse", map[string]string{"red": "hunter2"}); err != nil {
+		t.Fatal(err)
+	}
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var f file
+	if err := json.Unmarshal(raw, &f); err != nil {
+		t.Fatal(err)
+	}
+
+	write := func(f file) {
+		raw, _ := json.Marshal(f)
+		if err := ioutil.WriteFile(path, raw, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	flipped := f
+	flipped.Data = append([]byte{}, f.Data...)
+	flipped.Data[0] ^= 0xff
+	write(flipped)
+	if _, err := Load(path, "correct horse"); err != ErrWrongPassphrase {
+		t.Errorf("flipped data: expected ErrWrongPassphrase, got %v", err)
+	}
+
+	truncated := f
+	truncated.Data = f.Data[:len(f.Data)-4]
+	write(truncated)
+	if _, err := Load(path, "correct horse"); err != ErrWrongPassphrase {
+		t.Errorf("truncated data: expected ErrWrongPassphrase, got %v", err)
+	}
+
+	future := f
+	future.Version = version + 1
+	write(future)
+	if _, err := Load(path, "correct horse"); err == nil {
+		t.Error("expected error for unsupported version")
+	}
+
+	if err := ioutil.WriteFile(path, raw[:len(raw)/2], 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(path, "correct horse"); err == nil {
+		t.Error("expected error for truncated file")
+	}
+}